@@ -0,0 +1,649 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"oracle/internal/oracle"
+)
+
+// BatchResultPayload is one series of a batch run. Failed series carry only
+// their ID and Error.
+type BatchResultPayload struct {
+	ID              string             `json:"id"`
+	DataPoints      int                `json:"data_points,omitempty"`
+	Lag             int                `json:"lag,omitempty"`
+	TrainingMSE     float64            `json:"training_mse,omitempty"`
+	ResidualStdDev  float64            `json:"residual_std_dev,omitempty"`
+	TrainingSeconds float64            `json:"training_seconds,omitempty"`
+	Validation      *ValidationPayload `json:"validation,omitempty"`
+	Forecast        []ForecastPoint    `json:"forecast,omitempty"`
+	Error           string             `json:"error,omitempty"`
+
+	// Set for hierarchical runs: Forecast is then reconciled and
+	// BaseForecast holds the series' own model output.
+	Level          *int            `json:"level,omitempty"`
+	Reconciliation string          `json:"reconciliation,omitempty"`
+	BaseForecast   []ForecastPoint `json:"base_forecast,omitempty"`
+}
+
+type batchOptions struct {
+	workers int
+	format  string
+	metrics *metrics
+
+	// global trains one shared model on all series instead of one model
+	// per series; its Train settings come from the forecast options and
+	// workers is unused.
+	global *oracle.GlobalConfig
+
+	// hierarchy reconciles the forecasts; the series must then be the
+	// hierarchy's nodes in Nodes order.
+	hierarchy *batchHierarchy
+}
+
+type batchHierarchy struct {
+	h      *oracle.Hierarchy
+	method string
+}
+
+var reconcileMethods = []string{oracle.ReconcileBottomUp, oracle.ReconcileTopDown, oracle.ReconcileOLS, oracle.ReconcileWLS, oracle.ReconcileStructural, oracle.ReconcileMinT}
+
+// batchOutcome is the record of one series together with the run behind
+// it, which is nil when the series failed.
+type batchOutcome struct {
+	payload BatchResultPayload
+	run     *forecastRun
+}
+
+// runBatch forecasts every series and writes one record per series to w in
+// input order. A failing series is reported in its record and does not stop
+// the others. Without a global model or hierarchy, records are written as
+// soon as each one and all before it are done. It returns the number of
+// failed series.
+func runBatch(ctx context.Context, series []oracle.NamedSeries, opts forecastOptions, bo batchOptions, w io.Writer) (int, error) {
+	out, err := newBatchWriter(w, bo.format, bo.hierarchy != nil, opts.Metrics)
+	if err != nil {
+		return 0, err
+	}
+	failed := 0
+	write := func(o batchOutcome) error {
+		if o.payload.Error != "" {
+			failed++
+		}
+		if err := out.write(o.payload); err != nil {
+			return fmt.Errorf("failed writing batch output: %w", err)
+		}
+		return nil
+	}
+
+	if bo.global == nil && bo.hierarchy == nil {
+		if err := forecastPool(ctx, series, opts, bo.workers, bo.metrics, write); err != nil {
+			return failed, err
+		}
+		return failed, out.flush()
+	}
+
+	var outcomes []batchOutcome
+	if bo.global != nil {
+		outcomes = globalBatchOutcomes(ctx, series, opts, *bo.global, bo.metrics)
+	} else {
+		forecastPool(ctx, series, opts, bo.workers, bo.metrics, func(o batchOutcome) error {
+			outcomes = append(outcomes, o)
+			return nil
+		})
+	}
+	var reconcileErr error
+	if bo.hierarchy != nil {
+		reconcileErr = reconcileOutcomes(outcomes, *bo.hierarchy)
+	}
+
+	for _, o := range outcomes {
+		if err := write(o); err != nil {
+			return failed, err
+		}
+	}
+	if err := out.flush(); err != nil {
+		return failed, fmt.Errorf("failed writing batch output: %w", err)
+	}
+	return failed, reconcileErr
+}
+
+// forecastPool runs forecastOne on a pool of workers and passes the
+// outcomes to emit in input order. After emit fails, the remaining outcomes
+// are drained but not emitted.
+func forecastPool(ctx context.Context, series []oracle.NamedSeries, opts forecastOptions, workers int, m *metrics, emit func(batchOutcome) error) error {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers > len(series) {
+		workers = len(series)
+	}
+
+	type indexed struct {
+		i       int
+		outcome batchOutcome
+	}
+	jobs := make(chan int)
+	results := make(chan indexed)
+	var wg sync.WaitGroup
+	for n := 0; n < workers; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results <- indexed{i, forecastOne(ctx, series[i], opts, m)}
+			}
+		}()
+	}
+	go func() {
+		for i := range series {
+			jobs <- i
+		}
+		close(jobs)
+		wg.Wait()
+		close(results)
+	}()
+
+	var (
+		emitErr error
+		next    int
+		pending = map[int]batchOutcome{}
+	)
+	for r := range results {
+		pending[r.i] = r.outcome
+		for {
+			o, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			if emitErr == nil {
+				emitErr = emit(o)
+			}
+		}
+	}
+	return emitErr
+}
+
+func forecastOne(ctx context.Context, s oracle.NamedSeries, opts forecastOptions, m *metrics) batchOutcome {
+	result := BatchResultPayload{ID: s.ID}
+	if s.Err != nil {
+		result.Error = s.Err.Error()
+		return batchOutcome{payload: result}
+	}
+
+	run, err := runForecast(ctx, s.Values, opts)
+	if err != nil {
+		result.Error = err.Error()
+		return batchOutcome{payload: result}
+	}
+	if m != nil {
+		m.observeRun(s.ID, run)
+	}
+
+	payload := run.payload()
+	result.DataPoints = payload.DataPoints
+	result.Lag = payload.Lag
+	result.TrainingMSE = payload.TrainingMSE
+	result.ResidualStdDev = payload.ResidualStdDev
+	result.TrainingSeconds = run.Training.Duration.Seconds()
+	result.Validation = payload.Validation
+	result.Forecast = payload.Forecast
+	return batchOutcome{payload: result, run: run}
+}
+
+// globalBatchOutcomes prepares every series, trains one global model on all
+// that are usable and forecasts each from it. With a holdout, a first model
+// trained without the tails provides the per-series validation before the
+// model is refit on the full series.
+func globalBatchOutcomes(ctx context.Context, series []oracle.NamedSeries, opts forecastOptions, cfg oracle.GlobalConfig, m *metrics) []batchOutcome {
+	cfg.Train = opts.Train
+	results := make([]batchOutcome, len(series))
+	var usable []oracle.NamedSeries
+	index := map[string]int{}
+	lag := cfg.Train.Lag
+	if lag <= 0 {
+		lag = 6
+	}
+	for i, s := range series {
+		results[i].payload.ID = s.ID
+		if s.Err != nil {
+			results[i].payload.Error = s.Err.Error()
+			continue
+		}
+		if _, dup := index[s.ID]; dup {
+			results[i].payload.Error = fmt.Sprintf("duplicate series ID %q", s.ID)
+			continue
+		}
+		prepared, _, _, err := prepareSeries(s.Values, opts)
+		if err != nil {
+			results[i].payload.Error = err.Error()
+			continue
+		}
+		if len(prepared) <= lag+opts.Holdout {
+			results[i].payload.Error = fmt.Sprintf("series too short: %d points for lag %d and holdout %d", len(prepared), lag, opts.Holdout)
+			continue
+		}
+		index[s.ID] = i
+		usable = append(usable, oracle.NamedSeries{ID: s.ID, Values: prepared})
+	}
+	if len(usable) == 0 {
+		return results
+	}
+
+	fail := func(err error) []batchOutcome {
+		for _, s := range usable {
+			results[index[s.ID]].payload.Error = err.Error()
+		}
+		return results
+	}
+	ctx, cancel := opts.trainingContext(ctx)
+	defer cancel()
+	var training trainingStats
+	train := func(data []oracle.NamedSeries) (*oracle.GlobalModel, error) {
+		start := time.Now()
+		g, err := oracle.TrainGlobalContext(ctx, data, cfg)
+		training.Duration += time.Since(start)
+		if g != nil {
+			training.Epochs += g.Epochs
+		}
+		return g, err
+	}
+
+	validations := map[string]*oracle.ValidationMetrics{}
+	if opts.Holdout > 0 {
+		heads := make([]oracle.NamedSeries, len(usable))
+		for i, s := range usable {
+			heads[i] = oracle.NamedSeries{ID: s.ID, Values: s.Values[:len(s.Values)-opts.Holdout]}
+		}
+		g, err := train(heads)
+		if err != nil {
+			return fail(fmt.Errorf("global training failed: %w", err))
+		}
+		for _, s := range usable {
+			result, err := g.Result(s.ID)
+			if err == nil {
+				var v oracle.ValidationMetrics
+				if v, err = oracle.ValidateContext(ctx, result, s.Values, opts.Holdout, opts.Season); err == nil {
+					validations[s.ID] = &v
+				}
+			}
+			if err != nil {
+				results[index[s.ID]].payload.Error = fmt.Sprintf("validation failed: %v", err)
+			}
+		}
+	}
+
+	g, err := train(usable)
+	if err != nil {
+		return fail(fmt.Errorf("global training failed: %w", err))
+	}
+	if m != nil {
+		m.observe("oracle_training_duration_seconds", trainingBuckets, training.Duration.Seconds(), "model", "global")
+		m.add("oracle_training_epochs_total", float64(training.Epochs), "model", "global")
+	}
+
+	for _, s := range usable {
+		i := index[s.ID]
+		if results[i].payload.Error != "" {
+			continue
+		}
+		result, err := g.Result(s.ID)
+		if err != nil {
+			results[i].payload.Error = err.Error()
+			continue
+		}
+		predictions, err := oracle.Forecast(result, s.Values, opts.Steps)
+		if err != nil {
+			results[i].payload.Error = fmt.Sprintf("forecast failed: %v", err)
+			continue
+		}
+		run := &forecastRun{
+			Series:     s.Values,
+			Result:     result,
+			Validation: validations[s.ID],
+			Points:     buildForecastPoints(predictions, result.ResidualStdDev, result.Constraints),
+			Metrics:    opts.Metrics,
+		}
+		if m != nil {
+			m.observeRun(s.ID, run)
+		}
+
+		payload := run.payload()
+		results[i].payload.DataPoints = payload.DataPoints
+		results[i].payload.Lag = payload.Lag
+		results[i].payload.TrainingMSE = payload.TrainingMSE
+		results[i].payload.ResidualStdDev = payload.ResidualStdDev
+		results[i].payload.Validation = payload.Validation
+		results[i].payload.Forecast = payload.Forecast
+		results[i].run = run
+	}
+	return results
+}
+
+// reconcileOutcomes replaces the forecasts of a complete hierarchy with
+// reconciled ones. Intervals keep their base width around the reconciled
+// point. Nothing changes when any series failed.
+func reconcileOutcomes(outcomes []batchOutcome, hb batchHierarchy) error {
+	var failed []string
+	for _, o := range outcomes {
+		if o.run == nil {
+			failed = append(failed, o.payload.ID)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("reconciliation skipped: %d series failed (%s)", len(failed), strings.Join(failed, ", "))
+	}
+
+	cfg := oracle.ReconcileConfig{Method: hb.method}
+	base := make([][]float64, len(outcomes))
+	for i, o := range outcomes {
+		for _, p := range o.payload.Forecast {
+			base[i] = append(base[i], p.Prediction)
+		}
+		cfg.History = append(cfg.History, o.run.Series)
+		if hb.method == oracle.ReconcileWLS || hb.method == oracle.ReconcileMinT {
+			residuals, err := oracle.InSampleResiduals(o.run.Result, o.run.Series)
+			if err != nil {
+				return fmt.Errorf("reconciliation failed: %s: %w", o.payload.ID, err)
+			}
+			cfg.Residuals = append(cfg.Residuals, residuals)
+		}
+	}
+	if len(base[0]) == 0 {
+		return nil
+	}
+
+	reconciled, err := hb.h.Reconcile(base, cfg)
+	if err != nil {
+		return fmt.Errorf("reconciliation failed: %w", err)
+	}
+	for i := range outcomes {
+		p := &outcomes[i].payload
+		level := hb.h.Depth(p.ID)
+		p.Level = &level
+		p.Reconciliation = hb.method
+		p.BaseForecast = append([]ForecastPoint(nil), p.Forecast...)
+		for k := range p.Forecast {
+			shift := reconciled[i][k] - p.Forecast[k].Prediction
+			p.Forecast[k].Prediction += shift
+			p.Forecast[k].Low95 += shift
+			p.Forecast[k].High95 += shift
+		}
+	}
+	return nil
+}
+
+type batchWriter struct {
+	ndjson       *json.Encoder
+	csv          *csv.Writer
+	hierarchical bool
+	columns      []validationMetric
+}
+
+// newBatchWriter writes NDJSON records or CSV rows; CSV output gets one
+// column per selected validation metric, and hierarchical CSV output level
+// and base_prediction columns.
+func newBatchWriter(w io.Writer, format string, hierarchical bool, metrics []string) (*batchWriter, error) {
+	switch format {
+	case "ndjson":
+		return &batchWriter{ndjson: json.NewEncoder(w)}, nil
+	case "csv":
+		cw := csv.NewWriter(w)
+		columns := selectMetrics(metrics)
+		header := []string{"id", "step", "prediction", "low_95", "high_95", "data_points", "training_mse", "residual_std_dev"}
+		for _, m := range columns {
+			header = append(header, m.name)
+		}
+		header = append(header, "error")
+		if hierarchical {
+			header = append(header, "level", "base_prediction")
+		}
+		if err := cw.Write(header); err != nil {
+			return nil, err
+		}
+		return &batchWriter{csv: cw, hierarchical: hierarchical, columns: columns}, nil
+	}
+	return nil, fmt.Errorf("invalid -format: %q (use csv or ndjson)", format)
+}
+
+// write emits one record, or for CSV one row per forecast step with the
+// series metrics repeated. A failed series gets a single row with its error.
+func (b *batchWriter) write(r BatchResultPayload) error {
+	if b.ndjson != nil {
+		return b.ndjson.Encode(r)
+	}
+
+	metrics := make([]string, len(b.columns))
+	if r.Error != "" {
+		row := append(append([]string{r.ID, "", "", "", "", "", "", ""}, metrics...), r.Error)
+		if b.hierarchical {
+			row = append(row, "", "")
+		}
+		return b.csv.Write(row)
+	}
+	if v := r.Validation; v != nil {
+		for i, m := range b.columns {
+			metrics[i] = m.format(*v)
+		}
+	}
+	for k, p := range r.Forecast {
+		row := []string{
+			r.ID,
+			strconv.Itoa(p.Step),
+			fmt.Sprintf("%.6f", p.Prediction),
+			fmt.Sprintf("%.6f", p.Low95),
+			fmt.Sprintf("%.6f", p.High95),
+			strconv.Itoa(r.DataPoints),
+			fmt.Sprintf("%.6f", r.TrainingMSE),
+			fmt.Sprintf("%.6f", r.ResidualStdDev),
+		}
+		row = append(append(row, metrics...), "")
+		if b.hierarchical {
+			level, basePrediction := "", ""
+			if r.Level != nil {
+				level = strconv.Itoa(*r.Level)
+			}
+			if k < len(r.BaseForecast) {
+				basePrediction = fmt.Sprintf("%.6f", r.BaseForecast[k].Prediction)
+			}
+			row = append(row, level, basePrediction)
+		}
+		if err := b.csv.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *batchWriter) flush() error {
+	if b.csv == nil {
+		return nil
+	}
+	b.csv.Flush()
+	return b.csv.Error()
+}
+
+func loadBatchSeries(path string, cfg oracle.SeriesCSVConfig) ([]oracle.NamedSeries, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load data: %w", err)
+	}
+	if info.IsDir() {
+		if cfg != (oracle.SeriesCSVConfig{}) {
+			return nil, fmt.Errorf("-id-column, -value-column and -time-column apply to CSV input, not directories")
+		}
+		series, err := oracle.LoadSeriesDir(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load data: %w", err)
+		}
+		return series, nil
+	}
+	series, err := oracle.LoadSeriesCSV(path, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load data: %w", err)
+	}
+	return series, nil
+}
+
+func runBatchCommand(ctx context.Context, args []string) error {
+	var (
+		train                     trainFlags
+		prep                      prepFlags
+		conf                      configFlags
+		csvCfg                    oracle.SeriesCSVConfig
+		dataPath, outPath, format string
+		metricsPath               string
+		steps, holdout, workers   int
+		global                    bool
+		gcfg                      oracle.GlobalConfig
+		hierarchyPath, reconcile  string
+		eval                      evalFlags
+	)
+	fs := newFlagSet("batch")
+	conf.register(fs)
+	fs.StringVar(&dataPath, "data", "", "directory of series files, or a wide or long CSV with a header (required)")
+	fs.StringVar(&csvCfg.IDColumn, "id-column", "", "series ID column of a long CSV (empty reads a wide CSV)")
+	fs.StringVar(&csvCfg.ValueColumn, "value-column", "", "value column of a long CSV (default \"value\")")
+	fs.StringVar(&csvCfg.TimeColumn, "time-column", "", "timestamp column: skipped in a wide CSV, sort key within each series of a long CSV")
+	fs.StringVar(&outPath, "out", "", "combined output path (default stdout)")
+	fs.StringVar(&format, "format", "csv", "output format: csv or ndjson")
+	fs.StringVar(&metricsPath, "metrics-file", "", "optional path to write Prometheus text metrics, labelled by series ID")
+	fs.IntVar(&steps, "steps", 5, "number of future points to predict per series")
+	fs.IntVar(&holdout, "holdout", 0, "number of tail points for one-step holdout validation (0 disables)")
+	fs.IntVar(&workers, "workers", runtime.NumCPU(), "number of series trained concurrently")
+	fs.BoolVar(&global, "global", false, "train one shared model on the windows of all series instead of one model each")
+	fs.StringVar(&gcfg.Features, "series-features", "", "series identity inputs of the global model: none, onehot or embedding")
+	fs.IntVar(&gcfg.EmbeddingDim, "embedding-dim", 3, "size of the learned series embedding")
+	fs.StringVar(&hierarchyPath, "hierarchy", "", "parent,child file; missing parent series are summed from the data and all forecasts reconciled")
+	fs.StringVar(&reconcile, "reconcile", oracle.ReconcileMinT, "reconciliation method: bottomup, topdown, ols, wls, structural or mint")
+	eval.register(fs)
+	train.register(fs)
+	prep.register(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := conf.apply(fs, "batch"); err != nil {
+		return err
+	}
+	set := flagsSet(fs)
+	if err := train.validate(set); err != nil {
+		return err
+	}
+	if err := prep.validate(set); err != nil {
+		return err
+	}
+	if err := eval.validate(set, holdout > 0); err != nil {
+		return err
+	}
+	if err := rejectWith(set, "hierarchy", "reconciled forecasts would no longer satisfy the constraints", constraintFlagNames...); err != nil {
+		return err
+	}
+	switch {
+	case global && strings.ToLower(train.model) != oracle.ModelMLP:
+		return fmt.Errorf("-global requires -model mlp: the shared model is a network")
+	case !global && (set["series-features"] || set["embedding-dim"]):
+		return fmt.Errorf("-series-features and -embedding-dim require -global")
+	case set["embedding-dim"] && strings.ToLower(gcfg.Features) != oracle.SeriesFeaturesEmbedding:
+		return fmt.Errorf("-embedding-dim requires -series-features embedding")
+	case global && !containsString([]string{"", oracle.SeriesFeaturesNone, oracle.SeriesFeaturesOneHot, oracle.SeriesFeaturesEmbedding}, strings.ToLower(gcfg.Features)):
+		return fmt.Errorf("invalid -series-features: %q (use none, onehot or embedding)", gcfg.Features)
+	case set["reconcile"] && hierarchyPath == "":
+		return fmt.Errorf("-reconcile requires -hierarchy")
+	case !containsString(reconcileMethods, strings.ToLower(reconcile)):
+		return fmt.Errorf("invalid -reconcile: %q (use bottomup, topdown, ols, wls, structural or mint)", reconcile)
+	case global && set["workers"]:
+		return fmt.Errorf("-workers cannot be combined with -global: the shared model is trained once")
+	}
+	if dataPath == "" {
+		return fmt.Errorf("batch requires -data")
+	}
+	if steps < 0 {
+		return fmt.Errorf("invalid -steps: %d", steps)
+	}
+	if workers <= 0 {
+		return fmt.Errorf("invalid -workers: %d (must be positive)", workers)
+	}
+	format = strings.ToLower(strings.TrimSpace(format))
+	if format != "csv" && format != "ndjson" {
+		return fmt.Errorf("invalid -format: %q (use csv or ndjson)", format)
+	}
+	if done, err := conf.dump(fs, "batch", nil); err != nil || done {
+		return err
+	}
+
+	series, err := loadBatchSeries(dataPath, csvCfg)
+	if err != nil {
+		return err
+	}
+	var hierarchy *batchHierarchy
+	if hierarchyPath != "" {
+		h, err := oracle.LoadHierarchy(hierarchyPath)
+		if err != nil {
+			return fmt.Errorf("failed to load hierarchy: %w", err)
+		}
+		if series, err = h.Aggregate(series); err != nil {
+			return err
+		}
+		hierarchy = &batchHierarchy{h: h, method: strings.ToLower(reconcile)}
+	}
+
+	var file *os.File
+	w := bufio.NewWriter(os.Stdout)
+	if outPath != "" {
+		if file, err = os.Create(outPath); err != nil {
+			return fmt.Errorf("failed creating output: %w", err)
+		}
+		defer file.Close()
+		w = bufio.NewWriter(file)
+	}
+
+	opts := forecastOptions{Steps: steps, Holdout: holdout, Train: train.config(), Timeout: train.timeout}
+	prep.apply(&opts)
+	eval.apply(&opts)
+	bo := batchOptions{workers: workers, format: format, hierarchy: hierarchy}
+	if metricsPath != "" {
+		bo.metrics = newMetrics()
+	}
+	if global {
+		bo.global = &gcfg
+	}
+
+	start := time.Now()
+	failed, batchErr := runBatch(ctx, series, opts, bo, w)
+	err = w.Flush()
+	if err == nil && file != nil {
+		err = file.Close()
+	}
+	if err != nil {
+		return fmt.Errorf("failed writing batch output: %w", err)
+	}
+	if bo.metrics != nil {
+		if err := bo.metrics.writeFile(metricsPath); err != nil {
+			return fmt.Errorf("failed writing metrics: %w", err)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "batch: %d series, %d failed, %.1fs\n", len(series), failed, time.Since(start).Seconds())
+	if batchErr != nil {
+		return batchErr
+	}
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("batch interrupted: %w", err)
+	}
+	if failed == len(series) {
+		return fmt.Errorf("all %d series failed", failed)
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"oracle/internal/oracle"
+)
+
+func TestRunBatchKeepsOrderAndIsolatesFailures(t *testing.T) {
+	var series []oracle.NamedSeries
+	for i := 0; i < 8; i++ {
+		s := oracle.NamedSeries{ID: fmt.Sprintf("s%d", i), Values: linearSeries(20 + i)}
+		switch i {
+		case 2:
+			s.Values = []float64{1, 2, 3}
+		case 5:
+			s.Values, s.Err = nil, fmt.Errorf("unreadable")
+		}
+		series = append(series, s)
+	}
+	opts := forecastOptions{Steps: 2, Holdout: 3, Train: oracle.TrainConfig{Lag: 4, Hidden: 4, Epochs: 50, Seed: 1}}
+
+	var out bytes.Buffer
+	failed, err := runBatch(context.Background(), series, opts, batchOptions{workers: 3, format: "ndjson"}, &out)
+	if err != nil {
+		t.Fatalf("runBatch failed: %v", err)
+	}
+	if failed != 2 {
+		t.Fatalf("failed = %d, want 2", failed)
+	}
+
+	scanner := bufio.NewScanner(&out)
+	i := 0
+	for ; scanner.Scan(); i++ {
+		var r BatchResultPayload
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatalf("line %d: %v", i, err)
+		}
+		if r.ID != series[i].ID {
+			t.Fatalf("line %d is %s, want %s", i, r.ID, series[i].ID)
+		}
+		wantErr := i == 2 || i == 5
+		if (r.Error != "") != wantErr {
+			t.Fatalf("%s: error = %q", r.ID, r.Error)
+		}
+		if !wantErr && (len(r.Forecast) != 2 || r.Validation == nil) {
+			t.Fatalf("%s: incomplete result %+v", r.ID, r)
+		}
+	}
+	if i != len(series) {
+		t.Fatalf("got %d records, want %d", i, len(series))
+	}
+
+	out.Reset()
+	if _, err := runBatch(context.Background(), series[:3], opts, batchOptions{workers: 1, format: "csv"}, &out); err != nil {
+		t.Fatalf("runBatch csv failed: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 1+2+2+1 {
+		t.Fatalf("unexpected csv output:\n%s", out.String())
+	}
+	if !strings.HasPrefix(lines[5], "s2,") || !strings.Contains(lines[5], "invalid holdout") {
+		t.Fatalf("failed series row = %q", lines[5])
+	}
+}
+
+func TestRunBatchGlobal(t *testing.T) {
+	series := []oracle.NamedSeries{
+		{ID: "a", Values: linearSeries(24)},
+		{ID: "b", Values: linearSeries(30)},
+		{ID: "short", Values: []float64{1, 2, 3, 4, 5}},
+		{ID: "c", Values: linearSeries(18)},
+	}
+	opts := forecastOptions{Steps: 3, Holdout: 4, Train: oracle.TrainConfig{Lag: 4, Hidden: 6, Epochs: 80, Seed: 1}}
+	global := &oracle.GlobalConfig{Features: oracle.SeriesFeaturesEmbedding, EmbeddingDim: 2}
+
+	var out bytes.Buffer
+	m := newMetrics()
+	failed, err := runBatch(context.Background(), series, opts, batchOptions{format: "ndjson", global: global, metrics: m}, &out)
+	if err != nil {
+		t.Fatalf("runBatch failed: %v", err)
+	}
+	if failed != 1 {
+		t.Fatalf("failed = %d, want 1", failed)
+	}
+
+	dec := json.NewDecoder(&out)
+	for _, s := range series {
+		var r BatchResultPayload
+		if err := dec.Decode(&r); err != nil {
+			t.Fatalf("decode failed: %v", err)
+		}
+		if r.ID != s.ID {
+			t.Fatalf("got %s, want %s", r.ID, s.ID)
+		}
+		if s.ID == "short" {
+			if !strings.Contains(r.Error, "too short") {
+				t.Fatalf("short series error = %q", r.Error)
+			}
+			continue
+		}
+		if r.Error != "" || len(r.Forecast) != 3 || r.Validation == nil || r.Validation.Count != 4 {
+			t.Fatalf("%s: unexpected result %+v", s.ID, r)
+		}
+	}
+
+	var text bytes.Buffer
+	if err := m.writeTo(&text); err != nil {
+		t.Fatalf("writeTo failed: %v", err)
+	}
+	if !strings.Contains(text.String(), `oracle_training_epochs_total{model="global"} 160`) {
+		t.Fatalf("expected shared training metrics:\n%s", text.String())
+	}
+}
+
+func TestRunBatchHierarchy(t *testing.T) {
+	h, err := oracle.NewHierarchy([][2]string{{"total", "a"}, {"total", "b"}})
+	if err != nil {
+		t.Fatalf("NewHierarchy failed: %v", err)
+	}
+	leaves := []oracle.NamedSeries{
+		{ID: "a", Values: linearSeries(24)},
+		{ID: "b", Values: linearSeries(24)[4:]},
+	}
+	leaves[1].Values = append(leaves[1].Values, 30, 31, 32, 33)
+	series, err := h.Aggregate(leaves)
+	if err != nil {
+		t.Fatalf("Aggregate failed: %v", err)
+	}
+	opts := forecastOptions{Steps: 2, Train: oracle.TrainConfig{Lag: 4, Hidden: 4, Epochs: 60, Seed: 1}}
+
+	var out bytes.Buffer
+	bo := batchOptions{workers: 2, format: "ndjson", hierarchy: &batchHierarchy{h: h, method: oracle.ReconcileMinT}}
+	if _, err := runBatch(context.Background(), series, opts, bo, &out); err != nil {
+		t.Fatalf("runBatch failed: %v", err)
+	}
+	records := map[string]BatchResultPayload{}
+	dec := json.NewDecoder(&out)
+	for dec.More() {
+		var r BatchResultPayload
+		if err := dec.Decode(&r); err != nil {
+			t.Fatalf("decode failed: %v", err)
+		}
+		records[r.ID] = r
+	}
+	total, a, b := records["total"], records["a"], records["b"]
+	if total.Level == nil || *total.Level != 0 || *a.Level != 1 || total.Reconciliation != "mint" || len(a.BaseForecast) != 2 {
+		t.Fatalf("unexpected hierarchy fields: %+v", total)
+	}
+	for k := range total.Forecast {
+		sum := a.Forecast[k].Prediction + b.Forecast[k].Prediction
+		if d := total.Forecast[k].Prediction - sum; d > 1e-9 || d < -1e-9 {
+			t.Fatalf("step %d: total %f != a+b %f", k+1, total.Forecast[k].Prediction, sum)
+		}
+	}
+
+	series[2] = oracle.NamedSeries{ID: "b", Err: fmt.Errorf("broken")}
+	out.Reset()
+	bo.format = "csv"
+	failed, err := runBatch(context.Background(), series, opts, bo, &out)
+	if err == nil || !strings.Contains(err.Error(), "reconciliation skipped") || failed != 1 {
+		t.Fatalf("runBatch error = %v, failed = %d", err, failed)
+	}
+	if lines := strings.Split(strings.TrimSpace(out.String()), "\n"); len(lines) != 1+2+2+1 || !strings.HasSuffix(lines[0], ",level,base_prediction") {
+		t.Fatalf("records should still be written unreconciled:\n%s", out.String())
+	}
+}
@@ -0,0 +1,374 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// chartLine is one polyline of a lineChart.
+type chartLine struct {
+	Name   string
+	Color  string
+	X, Y   []float64
+	Dashed bool
+}
+
+// chartBand is a shaded range between Low and High, e.g. an interval.
+type chartBand struct {
+	Name         string
+	Color        string
+	X, Low, High []float64
+}
+
+// lineChart renders lines and bands over shared axes as an SVG fragment.
+// Markers draw vertical rules at the given x values; LogY plots log10 of
+// the values, all of which must then be positive.
+type lineChart struct {
+	Title   string
+	XLabel  string
+	Width   int
+	Height  int
+	Lines   []chartLine
+	Bands   []chartBand
+	Markers []float64
+	LogY    bool
+}
+
+const (
+	chartLeft   = 64
+	chartRight  = 16
+	chartTop    = 36
+	chartBottom = 40
+)
+
+// chartFrame maps data coordinates to pixels inside the plot area.
+type chartFrame struct {
+	width, height          int
+	xMin, xMax, yMin, yMax float64
+}
+
+func (f chartFrame) px(x float64) float64 {
+	return chartLeft + (x-f.xMin)/(f.xMax-f.xMin)*float64(f.width-chartLeft-chartRight)
+}
+
+func (f chartFrame) py(y float64) float64 {
+	return float64(f.height-chartBottom) - (y-f.yMin)/(f.yMax-f.yMin)*float64(f.height-chartTop-chartBottom)
+}
+
+// layout fits the frame around all lines and bands; y maps a value to the
+// plotted scale.
+func (c lineChart) layout() (frame chartFrame, y func(float64) float64) {
+	y = func(v float64) float64 {
+		if c.LogY {
+			return math.Log10(v)
+		}
+		return v
+	}
+	frame = chartFrame{width: c.Width, height: c.Height, xMin: math.Inf(1), xMax: math.Inf(-1), yMin: math.Inf(1), yMax: math.Inf(-1)}
+	extend := func(xs []float64, ys ...[]float64) {
+		for i, x := range xs {
+			frame.xMin, frame.xMax = math.Min(frame.xMin, x), math.Max(frame.xMax, x)
+			for _, col := range ys {
+				frame.yMin, frame.yMax = math.Min(frame.yMin, y(col[i])), math.Max(frame.yMax, y(col[i]))
+			}
+		}
+	}
+	for _, l := range c.Lines {
+		extend(l.X, l.Y)
+	}
+	for _, b := range c.Bands {
+		extend(b.X, b.Low, b.High)
+	}
+	if math.IsInf(frame.xMin, 0) {
+		frame.xMin, frame.xMax, frame.yMin, frame.yMax = 0, 1, 0, 1
+	}
+	frame.xMin, frame.xMax = padRange(frame.xMin, frame.xMax, 0)
+	frame.yMin, frame.yMax = padRange(frame.yMin, frame.yMax, 0.05)
+	return frame, y
+}
+
+// yLabel formats a y tick, undoing the log scale.
+func (c lineChart) yLabel(t float64) string {
+	if c.LogY {
+		return formatTick(math.Pow(10, t))
+	}
+	return formatTick(t)
+}
+
+func (c lineChart) svg() string {
+	frame, y := c.layout()
+	var b strings.Builder
+	openSVG(&b, c.Title, c.Width, c.Height)
+	yTicks := niceTicks(frame.yMin, frame.yMax, 5)
+	for _, t := range yTicks {
+		label := c.yLabel(t)
+		fmt.Fprintf(&b, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="#e5e7eb"/>`, chartLeft, frame.py(t), c.Width-chartRight, frame.py(t))
+		fmt.Fprintf(&b, `<text x="%d" y="%.1f" text-anchor="end" font-size="11" fill="#6b7280">%s</text>`, chartLeft-6, frame.py(t)+4, label)
+	}
+	for _, t := range niceTicks(frame.xMin, frame.xMax, 8) {
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" text-anchor="middle" font-size="11" fill="#6b7280">%s</text>`, frame.px(t), c.Height-chartBottom+16, formatTick(t))
+	}
+	if c.XLabel != "" {
+		fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="middle" font-size="11" fill="#6b7280">%s</text>`, (chartLeft+c.Width-chartRight)/2, c.Height-6, html.EscapeString(c.XLabel))
+	}
+	fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d" fill="none" stroke="#9ca3af"/>`, chartLeft, chartTop, c.Width-chartLeft-chartRight, c.Height-chartTop-chartBottom)
+
+	for _, band := range c.Bands {
+		var pts []string
+		for i, x := range band.X {
+			pts = append(pts, fmt.Sprintf("%.1f,%.1f", frame.px(x), frame.py(y(band.High[i]))))
+		}
+		for i := len(band.X) - 1; i >= 0; i-- {
+			pts = append(pts, fmt.Sprintf("%.1f,%.1f", frame.px(band.X[i]), frame.py(y(band.Low[i]))))
+		}
+		fmt.Fprintf(&b, `<polygon points="%s" fill="%s" fill-opacity="0.2" stroke="none"/>`, strings.Join(pts, " "), band.Color)
+	}
+	for _, m := range c.Markers {
+		fmt.Fprintf(&b, `<line x1="%.1f" y1="%d" x2="%.1f" y2="%d" stroke="#9ca3af" stroke-dasharray="2 3"/>`, frame.px(m), chartTop, frame.px(m), c.Height-chartBottom)
+	}
+	for _, l := range c.Lines {
+		pts := make([]string, len(l.X))
+		for i, x := range l.X {
+			pts[i] = fmt.Sprintf("%.1f,%.1f", frame.px(x), frame.py(y(l.Y[i])))
+		}
+		dash := ""
+		if l.Dashed {
+			dash = ` stroke-dasharray="6 4"`
+		}
+		fmt.Fprintf(&b, `<polyline points="%s" fill="none" stroke="%s" stroke-width="1.5"%s/>`, strings.Join(pts, " "), l.Color, dash)
+		if len(l.X) == 1 {
+			fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="2.5" fill="%s"/>`, frame.px(l.X[0]), frame.py(y(l.Y[0])), l.Color)
+		}
+	}
+
+	for _, e := range c.legend() {
+		fmt.Fprintf(&b, `<rect x="%.1f" y="14" width="12" height="10" fill="%s" fill-opacity="%g"/>`, e.x, e.color, e.opacity)
+		fmt.Fprintf(&b, `<text x="%.1f" y="23" font-size="11" fill="#374151">%s</text>`, e.x+16, html.EscapeString(e.name))
+	}
+	b.WriteString("</svg>")
+	return b.String()
+}
+
+// histogramChart renders the distribution of Values in Bins equal bins.
+type histogramChart struct {
+	Title  string
+	XLabel string
+	Width  int
+	Height int
+	Values []float64
+	Bins   int
+	Color  string
+}
+
+func (h histogramChart) svg() string {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, v := range h.Values {
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+	if len(h.Values) == 0 {
+		lo, hi = 0, 1
+	}
+	lo, hi = padRange(lo, hi, 0)
+	bins := h.Bins
+	if bins <= 0 {
+		bins = 1
+	}
+	counts := make([]int, bins)
+	for _, v := range h.Values {
+		counts[min(bins-1, int((v-lo)/(hi-lo)*float64(bins)))]++
+	}
+	top := 1
+	for _, n := range counts {
+		top = max(top, n)
+	}
+
+	frame := chartFrame{width: h.Width, height: h.Height, xMin: lo, xMax: hi, yMin: 0, yMax: float64(top) * 1.05}
+	var b strings.Builder
+	openSVG(&b, h.Title, h.Width, h.Height)
+	for _, t := range niceTicks(0, frame.yMax, 5) {
+		fmt.Fprintf(&b, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" stroke="#e5e7eb"/>`, chartLeft, frame.py(t), h.Width-chartRight, frame.py(t))
+		fmt.Fprintf(&b, `<text x="%d" y="%.1f" text-anchor="end" font-size="11" fill="#6b7280">%s</text>`, chartLeft-6, frame.py(t)+4, formatTick(t))
+	}
+	width := (hi - lo) / float64(bins)
+	for i, n := range counts {
+		x0, x1 := frame.px(lo+float64(i)*width), frame.px(lo+float64(i+1)*width)
+		fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s" stroke="#ffffff"/>`, x0, frame.py(float64(n)), x1-x0, frame.py(0)-frame.py(float64(n)), h.Color)
+	}
+	for _, t := range niceTicks(lo, hi, 8) {
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" text-anchor="middle" font-size="11" fill="#6b7280">%s</text>`, frame.px(t), h.Height-chartBottom+16, formatTick(t))
+	}
+	if h.XLabel != "" {
+		fmt.Fprintf(&b, `<text x="%d" y="%d" text-anchor="middle" font-size="11" fill="#6b7280">%s</text>`, (chartLeft+h.Width-chartRight)/2, h.Height-6, html.EscapeString(h.XLabel))
+	}
+	fmt.Fprintf(&b, `<rect x="%d" y="%d" width="%d" height="%d" fill="none" stroke="#9ca3af"/>`, chartLeft, chartTop, h.Width-chartLeft-chartRight, h.Height-chartTop-chartBottom)
+	b.WriteString("</svg>")
+	return b.String()
+}
+
+// openSVG starts an SVG document with a white background and the title.
+func openSVG(b *strings.Builder, title string, width, height int) {
+	fmt.Fprintf(b, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" font-family="sans-serif">`, width, height, width, height)
+	fmt.Fprintf(b, `<rect width="%d" height="%d" fill="#ffffff"/>`, width, height)
+	fmt.Fprintf(b, `<text x="%d" y="22" font-size="14" font-weight="bold" fill="#111827">%s</text>`, chartLeft, html.EscapeString(title))
+}
+
+// legendEntry is a named swatch; x is where its swatch starts.
+type legendEntry struct {
+	name    string
+	color   string
+	opacity float64
+	x       float64
+}
+
+// legend lists the named lines and bands right-aligned above the plot area.
+func (c lineChart) legend() []legendEntry {
+	var entries []legendEntry
+	for _, l := range c.Lines {
+		entries = append(entries, legendEntry{name: l.Name, color: l.Color, opacity: 1})
+	}
+	for _, band := range c.Bands {
+		entries = append(entries, legendEntry{name: band.Name, color: band.Color, opacity: 0.2})
+	}
+	x := float64(c.Width - chartRight)
+	var placed []legendEntry
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].name == "" {
+			continue
+		}
+		// A rough text width keeps the entries apart without measuring fonts.
+		x -= float64(len(entries[i].name))*6.5 + 22
+		entries[i].x = x
+		placed = append([]legendEntry{entries[i]}, placed...)
+	}
+	return placed
+}
+
+// padRange widens [lo, hi] by frac of its span, or by one unit around a
+// single value.
+func padRange(lo, hi, frac float64) (float64, float64) {
+	if hi <= lo {
+		return lo - 1, hi + 1
+	}
+	pad := (hi - lo) * frac
+	return lo - pad, hi + pad
+}
+
+// niceTicks returns round tick values inside [lo, hi], about n of them.
+func niceTicks(lo, hi float64, n int) []float64 {
+	span := hi - lo
+	if span <= 0 || n <= 0 {
+		return []float64{lo}
+	}
+	raw := span / float64(n)
+	exp := math.Floor(math.Log10(raw))
+	magnitude := math.Pow(10, exp)
+	mult := 1.0
+	for _, m := range []float64{1, 2, 5, 10} {
+		mult = m
+		if m*magnitude >= raw {
+			break
+		}
+	}
+	step := mult * magnitude
+	// Ticks are whole multiples of mult scaled by a power of ten; dividing by
+	// an exact 10^k keeps labels like 0.6 free of rounding noise.
+	tick := func(k float64) float64 {
+		if exp < 0 {
+			return k * mult / math.Pow(10, -exp)
+		}
+		return k * mult * magnitude
+	}
+	var ticks []float64
+	for k := math.Ceil(lo/step - 1e-9); tick(k) <= hi+step*1e-9; k++ {
+		ticks = append(ticks, tick(k))
+	}
+	return ticks
+}
+
+func formatTick(v float64) string {
+	if math.Abs(v) < 1e-12 {
+		return "0"
+	}
+	return fmt.Sprintf("%.4g", v)
+}
+
+// forecastChart shows the history, the forecast joined to the last
+// observation and its 95% interval, with rules where the holdout and the
+// forecast start.
+func forecastChart(run *forecastRun) lineChart {
+	n := len(run.Series)
+	chart := lineChart{Title: "History and forecast", XLabel: "period", Width: chartWidth, Height: chartHeight}
+	chart.Lines = append(chart.Lines, chartLine{Name: "history", Color: "#1f2937", X: indexRange(0, n), Y: run.Series})
+	if len(run.Points) > 0 {
+		x := []float64{float64(n - 1)}
+		y := []float64{run.Series[n-1]}
+		band := chartBand{Name: "95% interval", Color: "#2563eb", X: x, Low: y, High: y}
+		for _, p := range run.Points {
+			x = append(x, float64(n-1+p.Step))
+			y = append(y, p.Prediction)
+			band.Low = append(band.Low, p.Low95)
+			band.High = append(band.High, p.High95)
+		}
+		band.X = x
+		chart.Lines = append(chart.Lines, chartLine{Name: "forecast", Color: "#2563eb", X: x, Y: y, Dashed: true})
+		chart.Bands = append(chart.Bands, band)
+		chart.Markers = append(chart.Markers, float64(n-1))
+	}
+	if run.Validation != nil {
+		chart.Markers = append(chart.Markers, float64(n-run.Validation.Count))
+	}
+	return chart
+}
+
+// foldColors cycle through the backtest folds.
+var foldColors = []string{"#dc2626", "#d97706", "#059669", "#7c3aed", "#db2777", "#0891b2"}
+
+// backtestChart shows the series with each fold's forecast joined to the
+// last training point, and a rule at every forecast origin.
+func backtestChart(series []float64, folds []BacktestFoldPayload) lineChart {
+	chart := lineChart{Title: "Backtest folds", XLabel: "period", Width: chartWidth, Height: chartHeight}
+	chart.Lines = append(chart.Lines, chartLine{Name: "actual", Color: "#1f2937", X: indexRange(0, len(series)), Y: series})
+	for i, fold := range folds {
+		x := indexRange(fold.TrainEnd-1, fold.TrainEnd+len(fold.Predicted))
+		y := append([]float64{series[fold.TrainEnd-1]}, fold.Predicted...)
+		name := fmt.Sprintf("fold %d", fold.Fold)
+		chart.Lines = append(chart.Lines, chartLine{Name: name, Color: foldColors[i%len(foldColors)], X: x, Y: y, Dashed: true})
+		chart.Markers = append(chart.Markers, float64(fold.TrainEnd-1))
+	}
+	return chart
+}
+
+// chartFormat picks the export format from the file extension.
+func chartFormat(path string) (string, error) {
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".svg", ".png":
+		return ext[1:], nil
+	default:
+		return "", fmt.Errorf("invalid -chart %q: use a .svg or .png file", path)
+	}
+}
+
+// writeChart saves chart as SVG or PNG depending on the extension of path.
+func writeChart(path string, chart lineChart) error {
+	format, err := chartFormat(path)
+	if err != nil {
+		return err
+	}
+	if format == "svg" {
+		return os.WriteFile(path, []byte(chart.svg()+"\n"), 0o644)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := png.Encode(file, chart.png()); err != nil {
+		return err
+	}
+	return file.Close()
+}
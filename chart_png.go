@@ -0,0 +1,343 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// raster draws the chart primitives on an RGBA image. Everything is plain
+// integer or float arithmetic, so the same chart always gives the same
+// pixels.
+type raster struct {
+	img *image.RGBA
+}
+
+func newRaster(width, height int) raster {
+	r := raster{img: image.NewRGBA(image.Rect(0, 0, width, height))}
+	r.fill(0, 0, width, height, color.RGBA{255, 255, 255, 255}, 1)
+	return r
+}
+
+// parseHexColor reads #rrggbb or #rgb; anything else is black.
+func parseHexColor(s string) color.RGBA {
+	c := color.RGBA{A: 255}
+	if len(s) == 4 {
+		s = string([]byte{'#', s[1], s[1], s[2], s[2], s[3], s[3]})
+	}
+	if len(s) != 7 || s[0] != '#' {
+		return c
+	}
+	v, err := strconv.ParseUint(s[1:], 16, 32)
+	if err != nil {
+		return c
+	}
+	c.R, c.G, c.B = uint8(v>>16), uint8(v>>8), uint8(v)
+	return c
+}
+
+// blend paints c over the pixel at (x, y) with the given opacity.
+func (r raster) blend(x, y int, c color.RGBA, opacity float64) {
+	if !(image.Point{x, y}.In(r.img.Rect)) {
+		return
+	}
+	if opacity >= 1 {
+		r.img.SetRGBA(x, y, c)
+		return
+	}
+	dst := r.img.RGBAAt(x, y)
+	mix := func(d, s uint8) uint8 {
+		return uint8(math.Round(float64(d)*(1-opacity) + float64(s)*opacity))
+	}
+	r.img.SetRGBA(x, y, color.RGBA{mix(dst.R, c.R), mix(dst.G, c.G), mix(dst.B, c.B), 255})
+}
+
+func (r raster) fill(x0, y0, x1, y1 int, c color.RGBA, opacity float64) {
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			r.blend(x, y, c, opacity)
+		}
+	}
+}
+
+// outline draws the one-pixel border of a rectangle.
+func (r raster) outline(x0, y0, x1, y1 int, c color.RGBA) {
+	r.fill(x0, y0, x1+1, y0+1, c, 1)
+	r.fill(x0, y1, x1+1, y1+1, c, 1)
+	r.fill(x0, y0, x0+1, y1+1, c, 1)
+	r.fill(x1, y0, x1+1, y1+1, c, 1)
+}
+
+// polyline strokes the points with the given width. A dash pattern of
+// on/off lengths in pixels continues across the segments; nil is solid.
+func (r raster) polyline(xs, ys []float64, c color.RGBA, width float64, dash []float64) {
+	travelled := 0.0
+	period := 0.0
+	for _, d := range dash {
+		period += d
+	}
+	on := func(at float64) bool {
+		if period == 0 {
+			return true
+		}
+		at = math.Mod(at, period)
+		for i, d := range dash {
+			if at < d {
+				return i%2 == 0
+			}
+			at -= d
+		}
+		return true
+	}
+	half := width / 2
+	dot := func(x, y float64) {
+		for py := int(math.Floor(y - half + 0.5)); py <= int(math.Floor(y+half-0.5)); py++ {
+			for px := int(math.Floor(x - half + 0.5)); px <= int(math.Floor(x+half-0.5)); px++ {
+				r.blend(px, py, c, 1)
+			}
+		}
+	}
+	if len(xs) == 1 {
+		dot(xs[0], ys[0])
+	}
+	for i := 1; i < len(xs); i++ {
+		dx, dy := xs[i]-xs[i-1], ys[i]-ys[i-1]
+		length := math.Hypot(dx, dy)
+		steps := max(1, int(math.Ceil(length*2)))
+		for s := 0; s <= steps; s++ {
+			t := float64(s) / float64(steps)
+			if on(travelled + t*length) {
+				dot(xs[i-1]+t*dx, ys[i-1]+t*dy)
+			}
+		}
+		travelled += length
+	}
+}
+
+// polygon fills the even-odd interior, sampling each pixel at its center.
+func (r raster) polygon(xs, ys []float64, c color.RGBA, opacity float64) {
+	if len(xs) < 3 {
+		return
+	}
+	top, bottom := math.Inf(1), math.Inf(-1)
+	for _, y := range ys {
+		top, bottom = math.Min(top, y), math.Max(bottom, y)
+	}
+	for py := int(math.Floor(top)); py <= int(math.Ceil(bottom)); py++ {
+		cy := float64(py) + 0.5
+		var cross []float64
+		for i := range xs {
+			j := (i + 1) % len(xs)
+			if (ys[i] <= cy) != (ys[j] <= cy) {
+				cross = append(cross, xs[i]+(cy-ys[i])/(ys[j]-ys[i])*(xs[j]-xs[i]))
+			}
+		}
+		sort.Float64s(cross)
+		for k := 0; k+1 < len(cross); k += 2 {
+			for px := int(math.Ceil(cross[k] - 0.5)); float64(px)+0.5 < cross[k+1]; px++ {
+				r.blend(px, py, c, opacity)
+			}
+		}
+	}
+}
+
+// Text anchors, as with SVG's text-anchor.
+const (
+	anchorStart = iota
+	anchorMiddle
+	anchorEnd
+)
+
+// text draws s with the 5x7 bitmap font magnified by scale; baseline is the
+// y of the glyphs' bottom row, as an SVG text y is.
+func (r raster) text(x, baseline float64, s string, c color.RGBA, scale, anchor int) {
+	width := float64(textWidth(s, scale))
+	switch anchor {
+	case anchorMiddle:
+		x -= width / 2
+	case anchorEnd:
+		x -= width
+	}
+	left, top := int(math.Round(x)), int(math.Round(baseline))-7*scale
+	for _, ch := range s {
+		if ch < ' ' || ch > '~' {
+			ch = '?'
+		}
+		for col, bits := range font5x7[ch-' '] {
+			for row := 0; row < 8; row++ {
+				if bits&(1<<row) != 0 {
+					r.fill(left+col*scale, top+row*scale, left+(col+1)*scale, top+(row+1)*scale, c, 1)
+				}
+			}
+		}
+		left += 6 * scale
+	}
+}
+
+func textWidth(s string, scale int) int {
+	n := len([]rune(s))
+	if n == 0 {
+		return 0
+	}
+	return (6*n - 1) * scale
+}
+
+// png renders the chart like svg does, for image/png.
+func (c lineChart) png() *image.RGBA {
+	frame, y := c.layout()
+	r := newRaster(c.Width, c.Height)
+	grid, label, frameColor := parseHexColor("#e5e7eb"), parseHexColor("#6b7280"), parseHexColor("#9ca3af")
+	r.text(chartLeft, 22, c.Title, parseHexColor("#111827"), 2, anchorStart)
+
+	for _, t := range niceTicks(frame.yMin, frame.yMax, 5) {
+		py := math.Round(frame.py(t))
+		r.fill(chartLeft, int(py), c.Width-chartRight, int(py)+1, grid, 1)
+		r.text(chartLeft-6, py+4, c.yLabel(t), label, 1, anchorEnd)
+	}
+	for _, t := range niceTicks(frame.xMin, frame.xMax, 8) {
+		r.text(frame.px(t), float64(c.Height-chartBottom+16), formatTick(t), label, 1, anchorMiddle)
+	}
+	if c.XLabel != "" {
+		r.text(float64(chartLeft+c.Width-chartRight)/2, float64(c.Height-6), c.XLabel, label, 1, anchorMiddle)
+	}
+	r.outline(chartLeft, chartTop, c.Width-chartRight, c.Height-chartBottom, frameColor)
+
+	for _, band := range c.Bands {
+		var xs, ys []float64
+		for i, x := range band.X {
+			xs, ys = append(xs, frame.px(x)), append(ys, frame.py(y(band.High[i])))
+		}
+		for i := len(band.X) - 1; i >= 0; i-- {
+			xs, ys = append(xs, frame.px(band.X[i])), append(ys, frame.py(y(band.Low[i])))
+		}
+		r.polygon(xs, ys, parseHexColor(band.Color), 0.2)
+	}
+	for _, m := range c.Markers {
+		x := frame.px(m)
+		r.polyline([]float64{x, x}, []float64{chartTop, float64(c.Height - chartBottom)}, frameColor, 1, []float64{2, 3})
+	}
+	for _, l := range c.Lines {
+		xs, ys := make([]float64, len(l.X)), make([]float64, len(l.X))
+		for i, x := range l.X {
+			xs[i], ys[i] = frame.px(x), frame.py(y(l.Y[i]))
+		}
+		var dash []float64
+		if l.Dashed {
+			dash = []float64{6, 4}
+		}
+		width := 1.5
+		if len(l.X) == 1 {
+			width = 5
+		}
+		r.polyline(xs, ys, parseHexColor(l.Color), width, dash)
+	}
+
+	for _, e := range c.legend() {
+		x := int(math.Round(e.x))
+		r.fill(x, 14, x+12, 24, parseHexColor(e.color), e.opacity)
+		r.text(e.x+16, 23, e.name, parseHexColor("#374151"), 1, anchorStart)
+	}
+	return r.img
+}
+
+// font5x7 holds printable ASCII from ' ' to '~', five columns per glyph with
+// the top row in the lowest bit; bit 7 is the descender row.
+var font5x7 = [...][5]byte{
+	{0x00, 0x00, 0x00, 0x00, 0x00}, // ' '
+	{0x00, 0x00, 0x5F, 0x00, 0x00}, // !
+	{0x00, 0x07, 0x00, 0x07, 0x00}, // "
+	{0x14, 0x7F, 0x14, 0x7F, 0x14}, // #
+	{0x24, 0x2A, 0x7F, 0x2A, 0x12}, // $
+	{0x23, 0x13, 0x08, 0x64, 0x62}, // %
+	{0x36, 0x49, 0x56, 0x20, 0x50}, // &
+	{0x00, 0x00, 0x07, 0x00, 0x00}, // '
+	{0x00, 0x1C, 0x22, 0x41, 0x00}, // (
+	{0x00, 0x41, 0x22, 0x1C, 0x00}, // )
+	{0x2A, 0x1C, 0x7F, 0x1C, 0x2A}, // *
+	{0x08, 0x08, 0x3E, 0x08, 0x08}, // +
+	{0x00, 0x80, 0x60, 0x00, 0x00}, // ,
+	{0x08, 0x08, 0x08, 0x08, 0x08}, // -
+	{0x00, 0x60, 0x60, 0x00, 0x00}, // .
+	{0x20, 0x10, 0x08, 0x04, 0x02}, // /
+	{0x3E, 0x51, 0x49, 0x45, 0x3E}, // 0
+	{0x00, 0x42, 0x7F, 0x40, 0x00}, // 1
+	{0x62, 0x51, 0x49, 0x49, 0x46}, // 2
+	{0x22, 0x41, 0x49, 0x49, 0x36}, // 3
+	{0x18, 0x14, 0x12, 0x7F, 0x10}, // 4
+	{0x27, 0x45, 0x45, 0x45, 0x39}, // 5
+	{0x3C, 0x4A, 0x49, 0x49, 0x30}, // 6
+	{0x01, 0x71, 0x09, 0x05, 0x03}, // 7
+	{0x36, 0x49, 0x49, 0x49, 0x36}, // 8
+	{0x06, 0x49, 0x49, 0x29, 0x1E}, // 9
+	{0x00, 0x36, 0x36, 0x00, 0x00}, // :
+	{0x00, 0x56, 0x36, 0x00, 0x00}, // ;
+	{0x08, 0x14, 0x22, 0x41, 0x00}, // <
+	{0x14, 0x14, 0x14, 0x14, 0x14}, // =
+	{0x00, 0x41, 0x22, 0x14, 0x08}, // >
+	{0x02, 0x01, 0x51, 0x09, 0x06}, // ?
+	{0x3E, 0x41, 0x5D, 0x55, 0x1E}, // @
+	{0x7E, 0x11, 0x11, 0x11, 0x7E}, // A
+	{0x7F, 0x49, 0x49, 0x49, 0x36}, // B
+	{0x3E, 0x41, 0x41, 0x41, 0x22}, // C
+	{0x7F, 0x41, 0x41, 0x22, 0x1C}, // D
+	{0x7F, 0x49, 0x49, 0x49, 0x41}, // E
+	{0x7F, 0x09, 0x09, 0x09, 0x01}, // F
+	{0x3E, 0x41, 0x49, 0x49, 0x7A}, // G
+	{0x7F, 0x08, 0x08, 0x08, 0x7F}, // H
+	{0x00, 0x41, 0x7F, 0x41, 0x00}, // I
+	{0x20, 0x40, 0x41, 0x3F, 0x01}, // J
+	{0x7F, 0x08, 0x14, 0x22, 0x41}, // K
+	{0x7F, 0x40, 0x40, 0x40, 0x40}, // L
+	{0x7F, 0x02, 0x0C, 0x02, 0x7F}, // M
+	{0x7F, 0x04, 0x08, 0x10, 0x7F}, // N
+	{0x3E, 0x41, 0x41, 0x41, 0x3E}, // O
+	{0x7F, 0x09, 0x09, 0x09, 0x06}, // P
+	{0x3E, 0x41, 0x51, 0x21, 0x5E}, // Q
+	{0x7F, 0x09, 0x19, 0x29, 0x46}, // R
+	{0x46, 0x49, 0x49, 0x49, 0x31}, // S
+	{0x01, 0x01, 0x7F, 0x01, 0x01}, // T
+	{0x3F, 0x40, 0x40, 0x40, 0x3F}, // U
+	{0x1F, 0x20, 0x40, 0x20, 0x1F}, // V
+	{0x3F, 0x40, 0x38, 0x40, 0x3F}, // W
+	{0x63, 0x14, 0x08, 0x14, 0x63}, // X
+	{0x07, 0x08, 0x70, 0x08, 0x07}, // Y
+	{0x61, 0x51, 0x49, 0x45, 0x43}, // Z
+	{0x00, 0x7F, 0x41, 0x41, 0x00}, // [
+	{0x02, 0x04, 0x08, 0x10, 0x20}, // \
+	{0x00, 0x41, 0x41, 0x7F, 0x00}, // ]
+	{0x04, 0x02, 0x01, 0x02, 0x04}, // ^
+	{0x40, 0x40, 0x40, 0x40, 0x40}, // _
+	{0x00, 0x01, 0x02, 0x04, 0x00}, // `
+	{0x20, 0x54, 0x54, 0x54, 0x78}, // a
+	{0x7F, 0x48, 0x44, 0x44, 0x38}, // b
+	{0x38, 0x44, 0x44, 0x44, 0x20}, // c
+	{0x38, 0x44, 0x44, 0x48, 0x7F}, // d
+	{0x38, 0x54, 0x54, 0x54, 0x18}, // e
+	{0x08, 0x7E, 0x09, 0x01, 0x02}, // f
+	{0x18, 0xA4, 0xA4, 0xA4, 0x7C}, // g
+	{0x7F, 0x08, 0x04, 0x04, 0x78}, // h
+	{0x00, 0x44, 0x7D, 0x40, 0x00}, // i
+	{0x40, 0x80, 0x84, 0x7D, 0x00}, // j
+	{0x7F, 0x10, 0x28, 0x44, 0x00}, // k
+	{0x00, 0x41, 0x7F, 0x40, 0x00}, // l
+	{0x7C, 0x04, 0x18, 0x04, 0x78}, // m
+	{0x7C, 0x08, 0x04, 0x04, 0x78}, // n
+	{0x38, 0x44, 0x44, 0x44, 0x38}, // o
+	{0xFC, 0x24, 0x24, 0x24, 0x18}, // p
+	{0x18, 0x24, 0x24, 0x24, 0xFC}, // q
+	{0x7C, 0x08, 0x04, 0x04, 0x08}, // r
+	{0x48, 0x54, 0x54, 0x54, 0x20}, // s
+	{0x04, 0x3F, 0x44, 0x40, 0x20}, // t
+	{0x3C, 0x40, 0x40, 0x20, 0x7C}, // u
+	{0x1C, 0x20, 0x40, 0x20, 0x1C}, // v
+	{0x3C, 0x40, 0x30, 0x40, 0x3C}, // w
+	{0x44, 0x28, 0x10, 0x28, 0x44}, // x
+	{0x1C, 0xA0, 0xA0, 0xA0, 0x7C}, // y
+	{0x44, 0x64, 0x54, 0x4C, 0x44}, // z
+	{0x00, 0x08, 0x36, 0x41, 0x00}, // {
+	{0x00, 0x00, 0x7F, 0x00, 0x00}, // |
+	{0x00, 0x41, 0x36, 0x08, 0x00}, // }
+	{0x08, 0x04, 0x08, 0x10, 0x08}, // ~
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden chart files in testdata")
+
+func TestNiceTicks(t *testing.T) {
+	cases := []struct {
+		lo, hi float64
+		n      int
+		want   []float64
+	}{
+		{0, 10, 5, []float64{0, 2, 4, 6, 8, 10}},
+		{0.13, 0.91, 4, []float64{0.2, 0.4, 0.6, 0.8}},
+		{-3, 47, 5, []float64{0, 10, 20, 30, 40}},
+		{5, 5, 5, []float64{5}},
+	}
+	for _, tc := range cases {
+		if got := niceTicks(tc.lo, tc.hi, tc.n); !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("niceTicks(%v, %v, %d) = %v, want %v", tc.lo, tc.hi, tc.n, got, tc.want)
+		}
+	}
+}
+
+func TestChartSVG(t *testing.T) {
+	line := lineChart{
+		Title:   "a < b",
+		Width:   400,
+		Height:  200,
+		Lines:   []chartLine{{Name: "history", Color: "#000", X: []float64{0, 1, 2}, Y: []float64{1, 3, 2}}},
+		Bands:   []chartBand{{Name: "interval", Color: "#00f", X: []float64{2, 3}, Low: []float64{2, 1}, High: []float64{2, 4}}},
+		Markers: []float64{2},
+	}.svg()
+	for _, want := range []string{"<svg", "<polyline", "<polygon", "a &lt; b", "stroke-dasharray", "</svg>"} {
+		if !strings.Contains(line, want) {
+			t.Fatalf("line chart missing %q:\n%s", want, line)
+		}
+	}
+
+	hist := histogramChart{Title: "residuals", Width: 400, Height: 200, Values: []float64{-1, 0, 0, 0.2, 1}, Bins: 4, Color: "#f00"}.svg()
+	if got := strings.Count(hist, `fill="#f00"`); got != 4 {
+		t.Fatalf("histogram has %d bars, want 4:\n%s", got, hist)
+	}
+}
+
+// goldenRun is a fixed forecast, so its charts can be compared with the
+// files in testdata.
+func goldenRun() *forecastRun {
+	series := []float64{12, 14, 13, 17, 19, 18, 22, 25, 24, 27, 30, 29, 33, 36, 35, 38}
+	run := &forecastRun{Series: series}
+	for k := 1; k <= 6; k++ {
+		p := 38 + 1.5*float64(k)
+		spread := 1.2 + 0.4*float64(k)
+		run.Points = append(run.Points, ForecastPoint{Step: k, Prediction: p, Low95: p - spread, High95: p + spread})
+	}
+	return run
+}
+
+func TestChartGolden(t *testing.T) {
+	run := goldenRun()
+	folds := []BacktestFoldPayload{
+		{Fold: 1, TrainEnd: 8, Predicted: []float64{25, 26.5, 28, 29.5}},
+		{Fold: 2, TrainEnd: 12, Predicted: []float64{31, 33.5, 34, 36}},
+	}
+	charts := map[string]lineChart{
+		"forecast_chart": forecastChart(run),
+		"backtest_chart": backtestChart(run.Series, folds),
+	}
+	dir := t.TempDir()
+	for name, chart := range charts {
+		for _, ext := range []string{".svg", ".png"} {
+			got := filepath.Join(dir, name+ext)
+			if err := writeChart(got, chart); err != nil {
+				t.Fatalf("writeChart(%s) failed: %v", got, err)
+			}
+			golden := filepath.Join("testdata", name+ext)
+			if *updateGolden {
+				body, _ := os.ReadFile(got)
+				if err := os.WriteFile(golden, body, 0o644); err != nil {
+					t.Fatalf("update %s: %v", golden, err)
+				}
+				continue
+			}
+			compareGolden(t, got, golden)
+		}
+	}
+}
+
+// compareGolden compares SVG files byte for byte and PNG files pixel for
+// pixel, so a different zlib does not fail the test.
+func compareGolden(t *testing.T, got, golden string) {
+	t.Helper()
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("read golden file (run go test -update to create it): %v", err)
+	}
+	body, err := os.ReadFile(got)
+	if err != nil {
+		t.Fatalf("read %s: %v", got, err)
+	}
+	if filepath.Ext(golden) == ".svg" {
+		if !bytes.Equal(body, want) {
+			t.Fatalf("%s differs from %s", got, golden)
+		}
+		return
+	}
+	decode := func(b []byte) *image.RGBA {
+		img, err := png.Decode(bytes.NewReader(b))
+		if err != nil {
+			t.Fatalf("decode png: %v", err)
+		}
+		rgba, ok := img.(*image.RGBA)
+		if !ok {
+			rgba = image.NewRGBA(img.Bounds())
+			for y := img.Bounds().Min.Y; y < img.Bounds().Max.Y; y++ {
+				for x := img.Bounds().Min.X; x < img.Bounds().Max.X; x++ {
+					rgba.Set(x, y, img.At(x, y))
+				}
+			}
+		}
+		return rgba
+	}
+	a, b := decode(body), decode(want)
+	if a.Rect != b.Rect || !bytes.Equal(a.Pix, b.Pix) {
+		t.Fatalf("%s differs from %s", got, golden)
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"oracle/internal/oracle"
+)
+
+var checkpointFlagNames = []string{"checkpoint", "checkpoint-every", "resume"}
+
+// checkpointFlags save the network training state while it runs, so an
+// interrupted run can be continued with -resume.
+type checkpointFlags struct {
+	path   string
+	every  int
+	resume bool
+}
+
+func (c *checkpointFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&c.path, "checkpoint", "", "optional path to save the training state every -checkpoint-every epochs and when interrupted")
+	fs.IntVar(&c.every, "checkpoint-every", 1000, "epochs between checkpoints")
+	fs.BoolVar(&c.resume, "resume", false, "continue training from the -checkpoint file")
+}
+
+func (c checkpointFlags) validate(set map[string]bool, model string) error {
+	if err := rejectWith(set, "load-model", "nothing is trained", checkpointFlagNames...); err != nil {
+		return err
+	}
+	if c.path == "" {
+		for _, name := range checkpointFlagNames[1:] {
+			if set[name] {
+				return fmt.Errorf("-%s requires -checkpoint", name)
+			}
+		}
+		return nil
+	}
+	if c.every <= 0 {
+		return fmt.Errorf("invalid -checkpoint-every: %d (must be positive)", c.every)
+	}
+	if model = strings.ToLower(strings.TrimSpace(model)); model != oracle.ModelMLP {
+		return fmt.Errorf("-checkpoint cannot be combined with -model %s: it only applies to the mlp", model)
+	}
+	return nil
+}
+
+// apply sets up checkpointing on opts and, with -resume, loads the
+// checkpoint to continue from.
+func (c checkpointFlags) apply(opts *forecastOptions) error {
+	if c.path == "" {
+		return nil
+	}
+	opts.Train.CheckpointPath = c.path
+	opts.Train.CheckpointEvery = c.every
+	if !c.resume {
+		return nil
+	}
+	checkpoint, err := oracle.LoadCheckpoint(c.path)
+	if err != nil {
+		return fmt.Errorf("loading checkpoint failed: %w", err)
+	}
+	opts.Resume = checkpoint
+	return nil
+}
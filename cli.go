@@ -0,0 +1,1440 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"oracle/internal/oracle"
+)
+
+type command struct {
+	name    string
+	summary string
+	usage   string
+	run     func(ctx context.Context, args []string) error
+}
+
+// commands lists the subcommands in the order shown by "oracle help".
+var commands []command
+
+func init() {
+	commands = []command{
+		{"forecast", "train (or load) a model and forecast future points", "Trains a model on -data, or loads one with -load-model, and forecasts -steps points ahead.\nThis is what running oracle without a subcommand does.", runForecastCommand},
+		{"train", "train a model and save it", "Trains a model on -data and reports its fit. Use -save-model to persist it and -holdout\nto validate on the tail before the final refit.", runTrainCommand},
+		{"predict", "forecast with a saved model, without training", "Loads -load-model and forecasts -steps points after the history in -data.", runPredictCommand},
+		{"update", "fine-tune a saved model on new observations", "Loads -load-model and fine-tunes it for -epochs on the last -new points of -data, which holds\nthe training data followed by the new observations. The result is saved as a new version:\nthe next number next to a numbered model (models/sales/3.json -> 4.json) or -save-model.", runUpdateCommand},
+		{"evaluate", "one-step-ahead holdout validation", "Scores one-step-ahead predictions on the last -holdout points of -data, using either\na saved model (-load-model) or a model trained on the points before the holdout.", runEvaluateCommand},
+		{"backtest", "rolling-origin multi-step evaluation", "Retrains for each of -folds origins and scores -horizon-step forecasts against the\npoints that follow each origin.", runBacktestCommand},
+		{"batch", "forecast many series from a directory or CSV", "Trains and forecasts every series of -data (a directory of series files, or a wide or\nlong CSV) on -workers goroutines and writes one combined CSV or NDJSON output. A failing\nseries is reported in its own record and does not stop the run.", runBatchCommand},
+		{"temporal", "forecast at several aggregation levels and reconcile them", "Forecasts -data at every aggregation factor in -levels (e.g. 7,28 for weekly and\n4-weekly totals of daily data) and reconciles the forecasts so each level sums to the\nones above it, as in temporal hierarchies (THieF).", runTemporalCommand},
+		{"detect", "flag anomalies from one-step-ahead residuals", "Scores every point of -data against the model's one-step-ahead prediction and flags\nresiduals outside the chosen interval. -stream continues with values from stdin.", runDetectCommand},
+		{"stream", "predict the next value after each value read from stdin", "Keeps the last lag values and, for each value read line by line from stdin, writes NDJSON with\nthe prediction that was made for it, the next-step prediction and interval, and running metrics.\n-data gives the history to start from; otherwise the first lag values on stdin are used.", runStreamCommand},
+		{"inspect", "show the contents of a saved model", "Prints the shape, scaler and fit statistics stored in a model file.", runInspectCommand},
+		{"serve", "run the HTTP forecasting service", "Serves the JSON API described in the README. Training flags set the defaults for\nrequests that omit them.", runServeCommand},
+	}
+}
+
+func findCommand(name string) (command, bool) {
+	for _, c := range commands {
+		if c.name == name {
+			return c, true
+		}
+	}
+	return command{}, false
+}
+
+func printCommands(w io.Writer) {
+	fmt.Fprintln(w, "Usage: oracle <command> [flags]")
+	fmt.Fprintln(w, "       oracle [flags]            (same as \"oracle forecast\", plus -mode)")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, c := range commands {
+		fmt.Fprintf(w, "  %-10s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run \"oracle <command> -h\" for the flags of one command.")
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	c, _ := findCommand(name)
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.Usage = func() {
+		out := fs.Output()
+		fmt.Fprintf(out, "Usage: oracle %s [flags]\n\n%s\n\nFlags:\n", name, c.usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// flagsSet returns the names of the flags given explicitly on the command
+// line, as opposed to those left at their defaults.
+func flagsSet(fs *flag.FlagSet) map[string]bool {
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	return set
+}
+
+// rejectWith reports the first of names that was set together with guard.
+func rejectWith(set map[string]bool, guard, reason string, names ...string) error {
+	if !set[guard] {
+		return nil
+	}
+	for _, name := range names {
+		if set[name] {
+			return fmt.Errorf("-%s cannot be combined with -%s: %s", name, guard, reason)
+		}
+	}
+	return nil
+}
+
+var (
+	networkFlagNames    = []string{"lag", "hidden", "epochs", "lr", "seed"}
+	constraintFlagNames = []string{"lower", "upper", "integer", "total"}
+	trainFlagNames      = append(append(append([]string{"model"}, networkFlagNames...), constraintFlagNames...), "timeout")
+)
+
+type trainFlags struct {
+	model   string
+	lag     int
+	hidden  int
+	epochs  int
+	lr      float64
+	seed    int64
+	lower   optionalFloat
+	upper   optionalFloat
+	integer bool
+	total   optionalFloat
+	timeout time.Duration
+}
+
+func (t *trainFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&t.model, "model", oracle.ModelMLP, "model type: mlp, or croston, sba or tsb for intermittent demand")
+	fs.IntVar(&t.lag, "lag", 6, "number of past points used for one prediction")
+	fs.IntVar(&t.hidden, "hidden", 12, "hidden layer size")
+	fs.IntVar(&t.epochs, "epochs", 1800, "training epochs")
+	fs.Float64Var(&t.lr, "lr", 0.008, "learning rate")
+	fs.Int64Var(&t.seed, "seed", 42, "random seed")
+	fs.Var(&t.lower, "lower", "lower bound for forecasts and intervals, e.g. 0 for counts")
+	fs.Var(&t.upper, "upper", "upper bound for forecasts and intervals")
+	fs.BoolVar(&t.integer, "integer", false, "round forecasts to whole numbers (intervals are widened outwards)")
+	fs.Var(&t.total, "total", "make the forecast steps sum to this value")
+	fs.DurationVar(&t.timeout, "timeout", 0, "stop training after this long, e.g. 30s or 5m (0 disables)")
+}
+
+func (t trainFlags) config() oracle.TrainConfig {
+	return oracle.TrainConfig{
+		Model:        strings.ToLower(strings.TrimSpace(t.model)),
+		Lag:          t.lag,
+		Hidden:       t.hidden,
+		Epochs:       t.epochs,
+		LearningRate: t.lr,
+		Seed:         t.seed,
+		Constraints:  t.constraints(),
+	}
+}
+
+func (t trainFlags) constraints() *oracle.Constraints {
+	if t.lower.v == nil && t.upper.v == nil && t.total.v == nil && !t.integer {
+		return nil
+	}
+	return &oracle.Constraints{Lower: t.lower.v, Upper: t.upper.v, Integer: t.integer, Total: t.total.v}
+}
+
+// validate checks -model and rejects the network settings for the
+// intermittent-demand models, which have no network.
+func (t trainFlags) validate(set map[string]bool) error {
+	model := strings.ToLower(strings.TrimSpace(t.model))
+	if !containsString(oracle.ModelTypes, model) {
+		return fmt.Errorf("invalid -model: %q (use %s)", t.model, strings.Join(oracle.ModelTypes, ", "))
+	}
+	if err := t.constraints().Validate(); err != nil {
+		return fmt.Errorf("invalid constraints: %w", err)
+	}
+	if t.timeout < 0 {
+		return fmt.Errorf("invalid -timeout: %s (must not be negative)", t.timeout)
+	}
+	if model == oracle.ModelMLP {
+		return nil
+	}
+	for _, name := range networkFlagNames {
+		if set[name] {
+			return fmt.Errorf("-%s cannot be combined with -model %s: it only applies to the mlp", name, model)
+		}
+	}
+	return nil
+}
+
+// optionalFloat is a float flag that stays nil unless it is given.
+type optionalFloat struct {
+	v *float64
+}
+
+func (f *optionalFloat) String() string {
+	if f == nil || f.v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*f.v, 'g', -1, 64)
+}
+
+func (f *optionalFloat) Set(s string) error {
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return err
+	}
+	f.v = &v
+	return nil
+}
+
+// Get returns an empty string when unset, which the run config treats as
+// absent.
+func (f *optionalFloat) Get() any {
+	if f.v == nil {
+		return ""
+	}
+	return *f.v
+}
+
+var prepFlagNames = []string{"outliers", "outlier-action", "outlier-window", "outlier-threshold", "changepoints", "changepoint-cost", "changepoint-penalty", "changepoint-min-segment", "train-after-break"}
+
+type prepFlags struct {
+	outlierMethod string
+	outlierAction string
+	outlierWindow int
+	outlierThresh float64
+	cpMethod      string
+	cpCost        string
+	cpPenalty     float64
+	cpMinSegment  int
+	afterBreak    bool
+}
+
+func (p *prepFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&p.outlierMethod, "outliers", "", "outlier detection before training: hampel, mad or iqr (empty disables)")
+	fs.StringVar(&p.outlierAction, "outlier-action", "flag", "what to do with outliers: flag, clip or replace")
+	fs.IntVar(&p.outlierWindow, "outlier-window", 5, "hampel half-window or number of preceding points for mad")
+	fs.Float64Var(&p.outlierThresh, "outlier-threshold", 0, "robust deviations (hampel, mad) or IQR multiplier; 0 uses the method default")
+	fs.StringVar(&p.cpMethod, "changepoints", "", "changepoint detection: pelt or binseg (empty disables)")
+	fs.StringVar(&p.cpCost, "changepoint-cost", "mean", "changepoint segment cost: mean or meanvar")
+	fs.Float64Var(&p.cpPenalty, "changepoint-penalty", 0, "penalty per changepoint (0 uses a BIC-style default)")
+	fs.IntVar(&p.cpMinSegment, "changepoint-min-segment", 3, "minimum number of points between changepoints")
+	fs.BoolVar(&p.afterBreak, "train-after-break", false, "use only the data after the last detected changepoint")
+}
+
+// validate rejects tuning flags whose stage is not enabled.
+func (p prepFlags) validate(set map[string]bool) error {
+	if p.outlierMethod == "" {
+		for _, name := range []string{"outlier-action", "outlier-window", "outlier-threshold"} {
+			if set[name] {
+				return fmt.Errorf("-%s requires -outliers", name)
+			}
+		}
+	}
+	if p.cpMethod == "" {
+		for _, name := range []string{"changepoint-cost", "changepoint-penalty", "changepoint-min-segment", "train-after-break"} {
+			if set[name] {
+				return fmt.Errorf("-%s requires -changepoints", name)
+			}
+		}
+	}
+	return nil
+}
+
+func (p prepFlags) apply(opts *forecastOptions) {
+	opts.TrainAfterBreak = p.afterBreak
+	if p.outlierMethod != "" {
+		opts.Outliers = &oracle.OutlierConfig{
+			Method:    p.outlierMethod,
+			Action:    p.outlierAction,
+			Window:    p.outlierWindow,
+			Threshold: p.outlierThresh,
+		}
+	}
+	if p.cpMethod != "" {
+		opts.Changepoints = &oracle.ChangepointConfig{
+			Method:     p.cpMethod,
+			Cost:       p.cpCost,
+			Penalty:    p.cpPenalty,
+			MinSegment: p.cpMinSegment,
+		}
+	}
+}
+
+var evalFlagNames = []string{"season", "metrics"}
+
+// evalFlags choose the reported validation metrics and the seasonal naive
+// forecast that scales MASE and RMSSE.
+type evalFlags struct {
+	season  int
+	metrics string
+}
+
+func (e *evalFlags) register(fs *flag.FlagSet) {
+	fs.IntVar(&e.season, "season", 1, "season length of the naive forecast scaling MASE and RMSSE (1 for the plain naive forecast)")
+	fs.StringVar(&e.metrics, "metrics", "", "comma-separated validation metrics to report (default all): "+strings.Join(validationMetricNames(), ", "))
+}
+
+// validate rejects the flags when nothing is validated, a non-positive
+// -season and unknown metric names.
+func (e evalFlags) validate(set map[string]bool, validating bool) error {
+	if !validating {
+		for _, name := range evalFlagNames {
+			if set[name] {
+				return fmt.Errorf("-%s requires -holdout", name)
+			}
+		}
+	}
+	if e.season <= 0 {
+		return fmt.Errorf("invalid -season: %d (must be positive)", e.season)
+	}
+	if _, err := parseMetrics(e.metrics); err != nil {
+		return fmt.Errorf("invalid -metrics: %w", err)
+	}
+	return nil
+}
+
+// apply expects validate to have passed.
+func (e evalFlags) apply(opts *forecastOptions) {
+	opts.Season = e.season
+	opts.Metrics, _ = parseMetrics(e.metrics)
+}
+
+type detectFlags struct {
+	z      float64
+	level  float64
+	stream bool
+}
+
+func (d *detectFlags) register(fs *flag.FlagSet) {
+	fs.Float64Var(&d.z, "detect-z", 0, "absolute residual z-score that flags an anomaly (overrides -detect-interval)")
+	fs.Float64Var(&d.level, "detect-interval", 0.99, "coverage of the normal prediction interval outside which points are anomalies")
+	fs.BoolVar(&d.stream, "stream", false, "after the data file, score values read from stdin and write NDJSON")
+}
+
+var serveFlagNames = []string{"addr", "serve-model", "model-dir", "model-poll", "max-body", "max-points", "max-steps", "max-epochs", "max-hidden", "request-timeout", "shutdown-timeout"}
+
+func (c *serveConfig) register(fs *flag.FlagSet) {
+	fs.StringVar(&c.addr, "addr", ":8080", "listen address")
+	fs.Var(&c.models, "serve-model", "model to load as name=path (repeatable)")
+	fs.StringVar(&c.modelDir, "model-dir", "", "registry directory of <name>/<version>.json models, reloaded on change")
+	fs.DurationVar(&c.modelPoll, "model-poll", 5*time.Second, "how often the registry directory is rescanned")
+	fs.Int64Var(&c.maxBodyBytes, "max-body", 1<<20, "maximum request body size in bytes")
+	fs.IntVar(&c.maxPoints, "max-points", 100000, "maximum series length per request")
+	fs.IntVar(&c.maxSteps, "max-steps", 1000, "maximum forecast steps per request")
+	fs.IntVar(&c.maxEpochs, "max-epochs", 20000, "maximum training epochs per request")
+	fs.IntVar(&c.maxHidden, "max-hidden", 256, "maximum hidden units per request")
+	fs.DurationVar(&c.requestTimeout, "request-timeout", 30*time.Second, "per-request timeout")
+	fs.DurationVar(&c.shutdownTimeout, "shutdown-timeout", 10*time.Second, "grace period for in-flight requests on shutdown")
+}
+
+func (c serveConfig) validate() error {
+	switch {
+	case c.maxBodyBytes <= 0 || c.maxPoints <= 0 || c.maxSteps <= 0 || c.maxEpochs <= 0 || c.maxHidden <= 0:
+		return fmt.Errorf("-max-body, -max-points, -max-steps, -max-epochs and -max-hidden must be positive")
+	case c.requestTimeout <= 0 || c.modelPoll <= 0:
+		return fmt.Errorf("-request-timeout and -model-poll must be positive")
+	}
+	return nil
+}
+
+func parseFormat(format string) (string, error) {
+	format = strings.ToLower(strings.TrimSpace(format))
+	if format != "text" && format != "json" {
+		return "", fmt.Errorf("invalid -format: %q (use text or json)", format)
+	}
+	return format, nil
+}
+
+// usageError marks a flag parsing failure the flag package has already
+// reported together with the usage text.
+type usageError struct {
+	err error
+}
+
+func (e usageError) Error() string { return e.err.Error() }
+
+func (e usageError) Unwrap() error { return e.err }
+
+// parseFlags parses args and rejects positional arguments.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return usageError{err}
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+	return nil
+}
+
+func printJSON(v any) error {
+	body, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode json output: %w", err)
+	}
+	fmt.Println(string(body))
+	return nil
+}
+
+func loadModelIfRequested(path string) (*oracle.TrainResult, error) {
+	if path == "" {
+		return nil, nil
+	}
+	result, err := oracle.LoadModel(path)
+	if err != nil {
+		return nil, fmt.Errorf("loading model failed: %w", err)
+	}
+	return result, nil
+}
+
+func writeRunMetrics(path, model string, run *forecastRun) error {
+	if path == "" {
+		return nil
+	}
+	m := newMetrics()
+	m.observeRun(model, run)
+	if err := m.writeFile(path); err != nil {
+		return fmt.Errorf("failed writing metrics: %w", err)
+	}
+	return nil
+}
+
+func runForecastCommand(ctx context.Context, args []string) error {
+	var (
+		train      trainFlags
+		prep       prepFlags
+		f          forecastFlags
+		eval       evalFlags
+		progress   progressFlags
+		checkpoint checkpointFlags
+		conf       configFlags
+		holdout    int
+	)
+	fs := newFlagSet("forecast")
+	conf.register(fs)
+	f.register(fs)
+	fs.IntVar(&f.steps, "steps", 5, "number of future points to predict")
+	fs.IntVar(&holdout, "holdout", 0, "number of tail points for one-step holdout validation (0 disables)")
+	fs.StringVar(&f.saveModelPath, "save-model", "", "optional path to save trained model JSON")
+	fs.StringVar(&f.loadModelPath, "load-model", "", "optional path to load model JSON and skip training")
+	eval.register(fs)
+	train.register(fs)
+	progress.register(fs)
+	checkpoint.register(fs)
+	prep.register(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := conf.apply(fs, "forecast"); err != nil {
+		return err
+	}
+
+	set := flagsSet(fs)
+	if err := rejectWith(set, "load-model", "the loaded model's own settings are used", trainFlagNames...); err != nil {
+		return err
+	}
+	if err := train.validate(set); err != nil {
+		return err
+	}
+	if err := prep.validate(set); err != nil {
+		return err
+	}
+	if err := eval.validate(set, holdout > 0); err != nil {
+		return err
+	}
+	if err := progress.validate(); err != nil {
+		return err
+	}
+	if err := checkpoint.validate(set, train.model); err != nil {
+		return err
+	}
+	if err := f.drift.validate(set); err != nil {
+		return err
+	}
+	if done, err := conf.dump(fs, "forecast", nil); err != nil || done {
+		return err
+	}
+	f.config = resolvedConfig(fs, "forecast", nil)
+
+	opts := forecastOptions{Steps: f.steps, Holdout: holdout, Train: train.config(), Timeout: train.timeout}
+	prep.apply(&opts)
+	eval.apply(&opts)
+	if err := checkpoint.apply(&opts); err != nil {
+		return err
+	}
+	return progress.run(&opts.Train, func() error { return f.run(ctx, opts) })
+}
+
+// forecastFlags are the input and output flags shared by the forecast and
+// predict commands and the flat invocation.
+type forecastFlags struct {
+	dataPath      string
+	outPath       string
+	format        string
+	metricsPath   string
+	saveModelPath string
+	loadModelPath string
+	reportPath    string
+	chartPath     string
+	steps         int
+	plot          bool
+	plotTail      int
+	noColor       bool
+	drift         driftFlags
+	// config is the resolved run config shown in the -report file.
+	config map[string]any
+}
+
+func (f *forecastFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&f.dataPath, "data", "data/sample.csv", "path to time-series data file")
+	fs.StringVar(&f.outPath, "out", "", "optional CSV output path for forecasts")
+	fs.StringVar(&f.format, "format", "text", "output format: text or json")
+	fs.StringVar(&f.metricsPath, "metrics-file", "", "optional path to write Prometheus text metrics for this run")
+	fs.StringVar(&f.reportPath, "report", "", "optional path to write a self-contained HTML report with charts")
+	fs.StringVar(&f.chartPath, "chart", "", "optional .svg or .png path for a chart of the history, forecast and 95% band")
+	fs.BoolVar(&f.plot, "plot", false, "draw the series tail, forecast and 95% band as a terminal chart (text format)")
+	fs.IntVar(&f.plotTail, "plot-tail", 60, "number of last observations shown by -plot (0 shows all)")
+	fs.BoolVar(&f.noColor, "no-color", false, "draw -plot without ANSI colors (also when NO_COLOR is set)")
+	f.drift.register(fs)
+}
+
+func (f forecastFlags) run(ctx context.Context, opts forecastOptions) error {
+	format, err := parseFormat(f.format)
+	if err != nil {
+		return err
+	}
+	if f.steps < 0 {
+		return fmt.Errorf("invalid -steps: %d", f.steps)
+	}
+	if f.plot && format != "text" {
+		return fmt.Errorf("-plot requires -format text")
+	}
+	if f.plotTail < 0 {
+		return fmt.Errorf("invalid -plot-tail: %d", f.plotTail)
+	}
+	if f.chartPath != "" {
+		if _, err := chartFormat(f.chartPath); err != nil {
+			return err
+		}
+	}
+
+	series, err := oracle.LoadSeriesFromFile(f.dataPath)
+	if err != nil {
+		return fmt.Errorf("failed to load data: %w", err)
+	}
+	if opts.Model, err = loadModelIfRequested(f.loadModelPath); err != nil {
+		return err
+	}
+
+	run, err := runForecast(ctx, series, opts)
+	if err != nil {
+		return err
+	}
+	// The training statistics describe the preprocessed series, so drift is
+	// checked on the series the model saw rather than on the raw -data.
+	drift, err := f.drift.check(opts.Model, f.loadModelPath, run.Series)
+	if err != nil {
+		return err
+	}
+	if err := saveModelIfRequested(f.saveModelPath, run.Result); err != nil {
+		return err
+	}
+	if err := writeRunMetrics(f.metricsPath, cliModelName(f.loadModelPath, f.saveModelPath), run); err != nil {
+		return err
+	}
+	if f.outPath != "" {
+		if err := writeForecastCSV(f.outPath, run.Points); err != nil {
+			return fmt.Errorf("failed writing forecast CSV: %w", err)
+		}
+	}
+
+	payload := run.payload()
+	payload.ModelLoadedFrom = f.loadModelPath
+	payload.Drift = drift
+	payload.ModelSavedTo = f.saveModelPath
+	payload.ForecastCSVPath = f.outPath
+	if f.reportPath != "" {
+		if err := writeReport(f.reportPath, run, payload, f.config); err != nil {
+			return fmt.Errorf("failed writing report: %w", err)
+		}
+		payload.ReportPath = f.reportPath
+	}
+	if f.chartPath != "" {
+		if err := writeChart(f.chartPath, forecastChart(run)); err != nil {
+			return fmt.Errorf("failed writing chart: %w", err)
+		}
+		payload.ChartPath = f.chartPath
+	}
+
+	if format == "json" {
+		return printJSON(payload)
+	}
+	printForecastText(os.Stdout, payload)
+	if f.plot {
+		fmt.Println()
+		printPlot(os.Stdout, run.Series, run.Points, plotOptions{Tail: f.plotTail, Color: colorEnabled(f.noColor)})
+	}
+	return nil
+}
+
+func runPredictCommand(ctx context.Context, args []string) error {
+	var (
+		prep prepFlags
+		f    forecastFlags
+		conf configFlags
+	)
+	fs := newFlagSet("predict")
+	conf.register(fs)
+	f.register(fs)
+	fs.IntVar(&f.steps, "steps", 5, "number of future points to predict")
+	fs.StringVar(&f.loadModelPath, "load-model", "", "path of the model JSON to use (required)")
+	prep.register(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := conf.apply(fs, "predict"); err != nil {
+		return err
+	}
+	if f.loadModelPath == "" {
+		return fmt.Errorf("predict requires -load-model")
+	}
+	set := flagsSet(fs)
+	if err := prep.validate(set); err != nil {
+		return err
+	}
+	if err := f.drift.validate(set); err != nil {
+		return err
+	}
+	if done, err := conf.dump(fs, "predict", nil); err != nil || done {
+		return err
+	}
+	f.config = resolvedConfig(fs, "predict", nil)
+
+	opts := forecastOptions{Steps: f.steps}
+	prep.apply(&opts)
+	return f.run(ctx, opts)
+}
+
+type TrainOutputPayload struct {
+	DataPoints      int                 `json:"data_points"`
+	Model           string              `json:"model"`
+	Lag             int                 `json:"lag"`
+	Hidden          int                 `json:"hidden,omitempty"`
+	Epochs          int                 `json:"epochs"`
+	TrainingMSE     float64             `json:"training_mse"`
+	ResidualStdDev  float64             `json:"residual_std_dev"`
+	TrainingSeconds float64             `json:"training_seconds"`
+	ModelSavedTo    string              `json:"model_saved_to,omitempty"`
+	Outliers        *OutlierPayload     `json:"outliers,omitempty"`
+	Changepoints    *ChangepointPayload `json:"changepoints,omitempty"`
+	Validation      *ValidationPayload  `json:"validation,omitempty"`
+}
+
+func runTrainCommand(ctx context.Context, args []string) error {
+	var (
+		train                      trainFlags
+		prep                       prepFlags
+		dataPath, format, savePath string
+		metricsPath                string
+		holdout                    int
+		eval                       evalFlags
+		progress                   progressFlags
+		checkpoint                 checkpointFlags
+		conf                       configFlags
+	)
+	fs := newFlagSet("train")
+	conf.register(fs)
+	fs.StringVar(&dataPath, "data", "data/sample.csv", "path to time-series data file")
+	fs.StringVar(&format, "format", "text", "output format: text or json")
+	fs.StringVar(&savePath, "save-model", "", "path to save the trained model JSON")
+	fs.StringVar(&metricsPath, "metrics-file", "", "optional path to write Prometheus text metrics for this run")
+	fs.IntVar(&holdout, "holdout", 0, "number of tail points for one-step holdout validation (0 disables)")
+	eval.register(fs)
+	train.register(fs)
+	progress.register(fs)
+	checkpoint.register(fs)
+	prep.register(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := conf.apply(fs, "train"); err != nil {
+		return err
+	}
+	set := flagsSet(fs)
+	if err := train.validate(set); err != nil {
+		return err
+	}
+	if err := prep.validate(set); err != nil {
+		return err
+	}
+	if err := eval.validate(set, holdout > 0); err != nil {
+		return err
+	}
+	if err := progress.validate(); err != nil {
+		return err
+	}
+	if err := checkpoint.validate(set, train.model); err != nil {
+		return err
+	}
+	format, err := parseFormat(format)
+	if err != nil {
+		return err
+	}
+	if done, err := conf.dump(fs, "train", nil); err != nil || done {
+		return err
+	}
+
+	series, err := oracle.LoadSeriesFromFile(dataPath)
+	if err != nil {
+		return fmt.Errorf("failed to load data: %w", err)
+	}
+
+	opts := forecastOptions{Holdout: holdout, Train: train.config(), Timeout: train.timeout}
+	prep.apply(&opts)
+	eval.apply(&opts)
+	if err := checkpoint.apply(&opts); err != nil {
+		return err
+	}
+	var run *forecastRun
+	err = progress.run(&opts.Train, func() (err error) {
+		run, err = runForecast(ctx, series, opts)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if err := saveModelIfRequested(savePath, run.Result); err != nil {
+		return err
+	}
+	if err := writeRunMetrics(metricsPath, cliModelName("", savePath), run); err != nil {
+		return err
+	}
+
+	base := run.payload()
+	payload := TrainOutputPayload{
+		DataPoints:      base.DataPoints,
+		Model:           run.Result.ModelType(),
+		Lag:             run.Result.Lag,
+		Hidden:          hiddenSize(run.Result),
+		Epochs:          run.Result.Epochs,
+		TrainingMSE:     run.Result.MSE,
+		ResidualStdDev:  run.Result.ResidualStdDev,
+		TrainingSeconds: run.Training.Duration.Seconds(),
+		ModelSavedTo:    savePath,
+		Outliers:        base.Outliers,
+		Changepoints:    base.Changepoints,
+		Validation:      base.Validation,
+	}
+	if format == "json" {
+		return printJSON(payload)
+	}
+
+	fmt.Println("Oracle - Training")
+	fmt.Printf("Data points      : %d\n", payload.DataPoints)
+	fmt.Printf("Model            : %s\n", payload.Model)
+	fmt.Printf("Lag              : %d\n", payload.Lag)
+	if payload.Hidden > 0 {
+		fmt.Printf("Hidden           : %d\n", payload.Hidden)
+		fmt.Printf("Epochs           : %d\n", payload.Epochs)
+	}
+	fmt.Printf("Training MSE     : %.6f\n", payload.TrainingMSE)
+	fmt.Printf("Residual Std Dev : %.6f\n", payload.ResidualStdDev)
+	fmt.Printf("Training time    : %.3fs\n", payload.TrainingSeconds)
+	if payload.ModelSavedTo != "" {
+		fmt.Printf("Model saved      : %s\n", payload.ModelSavedTo)
+	}
+	printValidationText(os.Stdout, payload.Validation)
+	return nil
+}
+
+type EvaluateOutputPayload struct {
+	DataPoints      int                 `json:"data_points"`
+	Lag             int                 `json:"lag"`
+	ModelLoadedFrom string              `json:"model_loaded_from,omitempty"`
+	Validation      *ValidationPayload  `json:"validation"`
+	Diagnostics     *DiagnosticsPayload `json:"diagnostics,omitempty"`
+}
+
+func runEvaluateCommand(ctx context.Context, args []string) error {
+	var (
+		train                      trainFlags
+		prep                       prepFlags
+		dataPath, format, loadPath string
+		holdout, diagnosticLags    int
+		diagnostics                bool
+		eval                       evalFlags
+		progress                   progressFlags
+		checkpoint                 checkpointFlags
+		conf                       configFlags
+	)
+	fs := newFlagSet("evaluate")
+	conf.register(fs)
+	fs.StringVar(&dataPath, "data", "data/sample.csv", "path to time-series data file")
+	fs.StringVar(&format, "format", "text", "output format: text or json")
+	fs.StringVar(&loadPath, "load-model", "", "evaluate this saved model instead of training one")
+	fs.IntVar(&holdout, "holdout", 6, "number of tail points to validate on")
+	fs.BoolVar(&diagnostics, "diagnostics", false, "also check the in-sample residuals for autocorrelation, non-normality and changing variance")
+	fs.IntVar(&diagnosticLags, "diagnostic-lags", 0, "autocorrelation lags for -diagnostics (0 uses min(10, residuals/5))")
+	eval.register(fs)
+	train.register(fs)
+	progress.register(fs)
+	checkpoint.register(fs)
+	prep.register(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := conf.apply(fs, "evaluate"); err != nil {
+		return err
+	}
+	set := flagsSet(fs)
+	if err := rejectWith(set, "load-model", "the saved model is evaluated as is", trainFlagNames...); err != nil {
+		return err
+	}
+	if err := rejectWith(set, "load-model", "the residuals are only in-sample for a model trained on this data", "diagnostics", "diagnostic-lags"); err != nil {
+		return err
+	}
+	if err := train.validate(set); err != nil {
+		return err
+	}
+	if err := prep.validate(set); err != nil {
+		return err
+	}
+	if holdout <= 0 {
+		return fmt.Errorf("invalid -holdout: %d (must be positive)", holdout)
+	}
+	if err := eval.validate(set, true); err != nil {
+		return err
+	}
+	if set["diagnostic-lags"] && !diagnostics {
+		return fmt.Errorf("-diagnostic-lags requires -diagnostics")
+	}
+	if diagnosticLags < 0 {
+		return fmt.Errorf("invalid -diagnostic-lags: %d (must not be negative)", diagnosticLags)
+	}
+	if err := progress.validate(); err != nil {
+		return err
+	}
+	if err := checkpoint.validate(set, train.model); err != nil {
+		return err
+	}
+	format, err := parseFormat(format)
+	if err != nil {
+		return err
+	}
+	if done, err := conf.dump(fs, "evaluate", nil); err != nil || done {
+		return err
+	}
+
+	series, err := oracle.LoadSeriesFromFile(dataPath)
+	if err != nil {
+		return fmt.Errorf("failed to load data: %w", err)
+	}
+
+	opts := forecastOptions{Holdout: holdout, Train: train.config(), Timeout: train.timeout, SkipRefit: true}
+	prep.apply(&opts)
+	eval.apply(&opts)
+	if err := checkpoint.apply(&opts); err != nil {
+		return err
+	}
+	if opts.Model, err = loadModelIfRequested(loadPath); err != nil {
+		return err
+	}
+	prepared, _, _, err := prepareSeries(series, opts)
+	if err != nil {
+		return err
+	}
+	var (
+		result     *oracle.TrainResult
+		validation *oracle.ValidationMetrics
+	)
+	err = progress.run(&opts.Train, func() (err error) {
+		result, validation, _, err = fitModel(ctx, prepared, opts)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	run := forecastRun{Series: prepared, Result: result, Validation: validation, Metrics: opts.Metrics}
+	payload := EvaluateOutputPayload{
+		DataPoints:      len(prepared),
+		Lag:             result.Lag,
+		ModelLoadedFrom: loadPath,
+		Validation:      run.payload().Validation,
+	}
+	if diagnostics {
+		// -diagnostics rejects -load-model, so the model was trained here
+		// without the holdout tail and the residuals on the head are its
+		// in-sample ones.
+		residuals, err := oracle.InSampleResiduals(result, prepared[:len(prepared)-holdout])
+		if err != nil {
+			return fmt.Errorf("residual diagnostics failed: %w", err)
+		}
+		d, err := oracle.DiagnoseResiduals(residuals, diagnosticLags)
+		if err != nil {
+			return fmt.Errorf("residual diagnostics failed: %w", err)
+		}
+		payload.Diagnostics = toDiagnosticsPayload(d)
+	}
+	if format == "json" {
+		return printJSON(payload)
+	}
+
+	fmt.Println("Oracle - Evaluation")
+	fmt.Printf("Data points      : %d\n", payload.DataPoints)
+	fmt.Printf("Lag              : %d\n", payload.Lag)
+	if payload.ModelLoadedFrom != "" {
+		fmt.Printf("Model loaded     : %s\n", payload.ModelLoadedFrom)
+	}
+	printValidationText(os.Stdout, payload.Validation)
+	printDiagnosticsText(os.Stdout, payload.Diagnostics)
+	return nil
+}
+
+type BacktestFoldPayload struct {
+	Fold      int       `json:"fold"`
+	TrainEnd  int       `json:"train_end"`
+	Actual    []float64 `json:"actual"`
+	Predicted []float64 `json:"predicted"`
+	MAE       float64   `json:"mae"`
+	RMSE      float64   `json:"rmse"`
+	MAPE      float64   `json:"mape"`
+}
+
+type BacktestOutputPayload struct {
+	DataPoints int                   `json:"data_points"`
+	Horizon    int                   `json:"horizon"`
+	Overall    ValidationPayload     `json:"overall"`
+	Folds      []BacktestFoldPayload `json:"folds"`
+	CSVPath    string                `json:"csv_path,omitempty"`
+	ChartPath  string                `json:"chart_path,omitempty"`
+}
+
+func runBacktestCommand(ctx context.Context, args []string) error {
+	var (
+		train                     trainFlags
+		prep                      prepFlags
+		dataPath, format, outPath string
+		chartPath                 string
+		cfg                       oracle.BacktestConfig
+		eval                      evalFlags
+		progress                  progressFlags
+		checkpoint                checkpointFlags
+		conf                      configFlags
+	)
+	fs := newFlagSet("backtest")
+	conf.register(fs)
+	fs.StringVar(&dataPath, "data", "data/sample.csv", "path to time-series data file")
+	fs.StringVar(&format, "format", "text", "output format: text or json")
+	fs.StringVar(&outPath, "out", "", "optional CSV output path for per-point fold results")
+	fs.StringVar(&chartPath, "chart", "", "optional .svg or .png path for a chart of the series and each fold's forecast")
+	fs.IntVar(&cfg.Folds, "folds", 3, "number of forecast origins")
+	fs.IntVar(&cfg.Horizon, "horizon", 5, "forecast steps scored at each origin")
+	fs.IntVar(&cfg.Step, "step", 0, "distance between origins (0 uses -horizon)")
+	eval.register(fs)
+	train.register(fs)
+	progress.register(fs)
+	checkpoint.register(fs)
+	prep.register(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := conf.apply(fs, "backtest"); err != nil {
+		return err
+	}
+	set := flagsSet(fs)
+	if err := train.validate(set); err != nil {
+		return err
+	}
+	if err := prep.validate(set); err != nil {
+		return err
+	}
+	if err := eval.validate(set, true); err != nil {
+		return err
+	}
+	format, err := parseFormat(format)
+	if err != nil {
+		return err
+	}
+	if chartPath != "" {
+		if _, err := chartFormat(chartPath); err != nil {
+			return err
+		}
+	}
+	if err := progress.validate(); err != nil {
+		return err
+	}
+	if err := checkpoint.validate(set, train.model); err != nil {
+		return err
+	}
+	if done, err := conf.dump(fs, "backtest", nil); err != nil || done {
+		return err
+	}
+
+	series, err := oracle.LoadSeriesFromFile(dataPath)
+	if err != nil {
+		return fmt.Errorf("failed to load data: %w", err)
+	}
+	opts := forecastOptions{Train: train.config(), Timeout: train.timeout}
+	prep.apply(&opts)
+	eval.apply(&opts)
+	if err := checkpoint.apply(&opts); err != nil {
+		return err
+	}
+	prepared, _, _, err := prepareSeries(series, opts)
+	if err != nil {
+		return err
+	}
+
+	cfg.Season = opts.Season
+	opts.Train.Resume = opts.Resume
+	ctx, cancel := opts.trainingContext(ctx)
+	defer cancel()
+	var result *oracle.BacktestResult
+	err = progress.run(&opts.Train, func() (err error) {
+		result, err = oracle.BacktestContext(ctx, prepared, opts.Train, cfg)
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("backtest failed: %w", err)
+	}
+
+	payload := BacktestOutputPayload{
+		DataPoints: len(prepared),
+		Horizon:    cfg.Horizon,
+		Overall:    toValidationPayload(result.Overall),
+		CSVPath:    outPath,
+	}
+	payload.Overall.metrics = opts.Metrics
+	for _, fold := range result.Folds {
+		payload.Folds = append(payload.Folds, BacktestFoldPayload{
+			Fold:      fold.Fold,
+			TrainEnd:  fold.TrainEnd,
+			Actual:    fold.Actual,
+			Predicted: fold.Predicted,
+			MAE:       fold.Metrics.MAE,
+			RMSE:      fold.Metrics.RMSE,
+			MAPE:      fold.Metrics.MAPE,
+		})
+	}
+	if outPath != "" {
+		if err := writeBacktestCSV(outPath, payload.Folds); err != nil {
+			return fmt.Errorf("failed writing backtest CSV: %w", err)
+		}
+	}
+	if chartPath != "" {
+		if err := writeChart(chartPath, backtestChart(prepared, payload.Folds)); err != nil {
+			return fmt.Errorf("failed writing chart: %w", err)
+		}
+		payload.ChartPath = chartPath
+	}
+	if format == "json" {
+		return printJSON(payload)
+	}
+
+	fmt.Println("Oracle - Backtest")
+	fmt.Printf("Data points      : %d\n", payload.DataPoints)
+	fmt.Printf("Folds            : %d\n", len(payload.Folds))
+	fmt.Printf("Horizon          : %d\n", payload.Horizon)
+	fmt.Println()
+	for _, fold := range payload.Folds {
+		fmt.Printf("fold %d (train on %d points) -> MAE %.6f  RMSE %.6f  MAPE %.4f%%\n", fold.Fold, fold.TrainEnd, fold.MAE, fold.RMSE, fold.MAPE)
+	}
+	fmt.Println()
+	printMetricsText(os.Stdout, "Overall", payload.Overall)
+	if outPath != "" {
+		fmt.Printf("\nSaved backtest CSV: %s\n", outPath)
+	}
+	if chartPath != "" {
+		fmt.Printf("Saved chart: %s\n", chartPath)
+	}
+	return nil
+}
+
+func writeBacktestCSV(path string, folds []BacktestFoldPayload) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	w := csv.NewWriter(file)
+	if err := w.Write([]string{"fold", "train_end", "step", "actual", "predicted"}); err != nil {
+		return err
+	}
+	for _, fold := range folds {
+		for i := range fold.Actual {
+			row := []string{
+				strconv.Itoa(fold.Fold),
+				strconv.Itoa(fold.TrainEnd),
+				strconv.Itoa(i + 1),
+				fmt.Sprintf("%.6f", fold.Actual[i]),
+				fmt.Sprintf("%.6f", fold.Predicted[i]),
+			}
+			if err := w.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+
+	w.Flush()
+	return w.Error()
+}
+
+func runDetectCommand(ctx context.Context, args []string) error {
+	var (
+		train                     trainFlags
+		prep                      prepFlags
+		detect                    detectFlags
+		dataPath, format, outPath string
+		loadPath, savePath        string
+		conf                      configFlags
+	)
+	fs := newFlagSet("detect")
+	conf.register(fs)
+	fs.StringVar(&dataPath, "data", "data/sample.csv", "path to time-series data file")
+	fs.StringVar(&format, "format", "text", "output format: text or json")
+	fs.StringVar(&outPath, "out", "", "optional CSV output path for per-point anomaly scores")
+	fs.StringVar(&loadPath, "load-model", "", "optional path to load model JSON and skip training")
+	fs.StringVar(&savePath, "save-model", "", "optional path to save trained model JSON")
+	train.register(fs)
+	prep.register(fs)
+	detect.register(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := conf.apply(fs, "detect"); err != nil {
+		return err
+	}
+	set := flagsSet(fs)
+	if err := rejectWith(set, "load-model", "the loaded model's own settings are used", trainFlagNames...); err != nil {
+		return err
+	}
+	if err := train.validate(set); err != nil {
+		return err
+	}
+	if err := prep.validate(set); err != nil {
+		return err
+	}
+	if done, err := conf.dump(fs, "detect", nil); err != nil || done {
+		return err
+	}
+
+	opts := forecastOptions{Train: train.config(), Timeout: train.timeout}
+	prep.apply(&opts)
+	return runDetectFromFile(ctx, dataPath, loadPath, savePath, opts, detect, detectOptions{format: format, outPath: outPath})
+}
+
+func runDetectFromFile(ctx context.Context, dataPath, loadPath, savePath string, opts forecastOptions, detect detectFlags, out detectOptions) error {
+	format, err := parseFormat(out.format)
+	if err != nil {
+		return err
+	}
+	series, err := oracle.LoadSeriesFromFile(dataPath)
+	if err != nil {
+		return fmt.Errorf("failed to load data: %w", err)
+	}
+	if opts.Model, err = loadModelIfRequested(loadPath); err != nil {
+		return err
+	}
+
+	prepared, _, _, err := prepareSeries(series, opts)
+	if err != nil {
+		return err
+	}
+	result, _, _, err := fitModel(ctx, prepared, opts)
+	if err != nil {
+		return err
+	}
+	if err := saveModelIfRequested(savePath, result); err != nil {
+		return err
+	}
+
+	out.format = format
+	out.modelLoaded = loadPath
+	out.stream = detect.stream
+	return runDetect(ctx, result, prepared, oracle.DetectConfig{ZThreshold: detect.z, Interval: detect.level}, out)
+}
+
+type InspectOutputPayload struct {
+	Path           string  `json:"path"`
+	Model          string  `json:"model"`
+	Lag            int     `json:"lag"`
+	Hidden         int     `json:"hidden,omitempty"`
+	Parameters     int     `json:"parameters,omitempty"`
+	Alpha          float64 `json:"alpha,omitempty"`
+	Beta           float64 `json:"beta,omitempty"`
+	TrainingMSE    float64 `json:"training_mse"`
+	ResidualStdDev float64 `json:"residual_std_dev"`
+	ScalerMean     float64 `json:"scaler_mean"`
+	ScalerStd      float64 `json:"scaler_std"`
+
+	TrainingStats *oracle.TrainingStats `json:"training_stats,omitempty"`
+}
+
+func runInspectCommand(ctx context.Context, args []string) error {
+	var loadPath, format string
+	fs := newFlagSet("inspect")
+	fs.StringVar(&loadPath, "load-model", "", "path of the model JSON to inspect (or pass it as an argument)")
+	fs.StringVar(&format, "format", "text", "output format: text or json")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return usageError{err}
+	}
+	switch {
+	case loadPath == "" && fs.NArg() == 1:
+		loadPath = fs.Arg(0)
+	case loadPath != "" && fs.NArg() > 0, fs.NArg() > 1:
+		return fmt.Errorf("inspect takes exactly one model path")
+	case loadPath == "":
+		return fmt.Errorf("inspect requires a model path")
+	}
+	format, err := parseFormat(format)
+	if err != nil {
+		return err
+	}
+
+	result, err := loadModelIfRequested(loadPath)
+	if err != nil {
+		return err
+	}
+	payload := InspectOutputPayload{
+		Path:           loadPath,
+		Model:          result.ModelType(),
+		Lag:            result.Lag,
+		TrainingMSE:    result.MSE,
+		ResidualStdDev: result.ResidualStdDev,
+		ScalerMean:     result.Scaler.Mean,
+		ScalerStd:      result.Scaler.Std,
+		TrainingStats:  result.Stats,
+	}
+	if m := result.Model; m != nil {
+		payload.Hidden = m.HiddenSize
+		payload.Parameters = m.HiddenSize*m.InputSize + 2*m.HiddenSize + 1
+	}
+	if im := result.Intermittent; im != nil {
+		payload.Alpha = im.Alpha
+		payload.Beta = im.Beta
+	}
+	if format == "json" {
+		return printJSON(payload)
+	}
+
+	fmt.Println("Oracle - Model")
+	fmt.Printf("Path             : %s\n", payload.Path)
+	fmt.Printf("Model            : %s\n", payload.Model)
+	fmt.Printf("Lag              : %d\n", payload.Lag)
+	if payload.Hidden > 0 {
+		fmt.Printf("Hidden           : %d\n", payload.Hidden)
+		fmt.Printf("Parameters       : %d\n", payload.Parameters)
+	} else {
+		fmt.Printf("Alpha            : %.2f\n", payload.Alpha)
+		if payload.Beta > 0 {
+			fmt.Printf("Beta             : %.2f\n", payload.Beta)
+		}
+	}
+	fmt.Printf("Training MSE     : %.6f\n", payload.TrainingMSE)
+	fmt.Printf("Residual Std Dev : %.6f\n", payload.ResidualStdDev)
+	fmt.Printf("Scaler mean      : %.6f\n", payload.ScalerMean)
+	fmt.Printf("Scaler std       : %.6f\n", payload.ScalerStd)
+	if st := payload.TrainingStats; st != nil {
+		q := st.Quantiles
+		fmt.Printf("Training data    : %d points, mean %.4f, std %.4f, min %.4f, max %.4f\n", st.Points, st.Mean, st.StdDev, st.Min, st.Max)
+		fmt.Printf("Quartiles        : %.4f / %.4f / %.4f\n", q[len(q)/4], q[len(q)/2], q[3*len(q)/4])
+		fmt.Printf("Residuals        : %d, mean %.6f, std %.6f\n", st.Residuals, st.ResidualMean, st.ResidualStdDev)
+	}
+	return nil
+}
+
+func runServeCommand(ctx context.Context, args []string) error {
+	var (
+		train trainFlags
+		cfg   serveConfig
+		steps int
+	)
+	fs := newFlagSet("serve")
+	cfg.register(fs)
+	fs.IntVar(&steps, "steps", 5, "default number of future points per request")
+	train.register(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := train.validate(flagsSet(fs)); err != nil {
+		return err
+	}
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+	if err := runServe(ctx, cfg, forecastOptions{Steps: steps, Train: train.config(), Timeout: train.timeout}); err != nil {
+		return fmt.Errorf("server failed: %w", err)
+	}
+	return nil
+}
+
+// runFlat keeps the original single flag set working. -mode selects what
+// the subcommands now do separately, and flags that belong to another mode
+// are rejected instead of being ignored.
+func runFlat(ctx context.Context, args []string) error {
+	var (
+		mode       string
+		holdout    int
+		train      trainFlags
+		prep       prepFlags
+		detect     detectFlags
+		serve      serveConfig
+		f          forecastFlags
+		eval       evalFlags
+		progress   progressFlags
+		checkpoint checkpointFlags
+		conf       configFlags
+	)
+	fs := flag.NewFlagSet("oracle", flag.ContinueOnError)
+	fs.Usage = func() {
+		printCommands(fs.Output())
+		fmt.Fprintln(fs.Output(), "\nFlags without a command:")
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&mode, "mode", "forecast", "run mode: forecast, detect or serve")
+	conf.register(fs)
+	f.register(fs)
+	fs.IntVar(&f.steps, "steps", 5, "number of future points to predict")
+	fs.IntVar(&holdout, "holdout", 0, "number of tail points for one-step holdout validation (0 disables)")
+	fs.StringVar(&f.saveModelPath, "save-model", "", "optional path to save trained model JSON")
+	fs.StringVar(&f.loadModelPath, "load-model", "", "optional path to load model JSON and skip training")
+	eval.register(fs)
+	train.register(fs)
+	progress.register(fs)
+	checkpoint.register(fs)
+	prep.register(fs)
+	detect.register(fs)
+	serve.register(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := conf.apply(fs, ""); err != nil {
+		return err
+	}
+
+	mode = strings.ToLower(strings.TrimSpace(mode))
+	detectOnly := []string{"detect-z", "detect-interval", "stream"}
+	disallowed := map[string][]string{
+		"forecast": append(append([]string{}, detectOnly...), serveFlagNames...),
+		"detect":   append(append(append([]string{"steps", "holdout", "metrics-file", "report", "chart", "plot", "plot-tail", "no-color"}, evalFlagNames...), serveFlagNames...), driftFlagNames...),
+		"serve":    append(append(append(append([]string{"data", "out", "format", "metrics-file", "report", "chart", "plot", "plot-tail", "no-color", "progress", "epoch-log", "checkpoint", "checkpoint-every", "resume", "save-model", "load-model", "holdout"}, evalFlagNames...), detectOnly...), prepFlagNames...), driftFlagNames...),
+	}
+	if _, ok := disallowed[mode]; !ok {
+		return fmt.Errorf("invalid -mode: %q (use forecast, detect or serve)", mode)
+	}
+	set := flagsSet(fs)
+	var rejected []string
+	for _, name := range disallowed[mode] {
+		if set[name] {
+			rejected = append(rejected, "-"+name)
+		}
+	}
+	if len(rejected) > 0 {
+		sort.Strings(rejected)
+		return fmt.Errorf("%s cannot be used with -mode %s", strings.Join(rejected, ", "), mode)
+	}
+	if err := rejectWith(set, "load-model", "the loaded model's own settings are used", trainFlagNames...); err != nil {
+		return err
+	}
+	if err := train.validate(set); err != nil {
+		return err
+	}
+	if err := prep.validate(set); err != nil {
+		return err
+	}
+	if err := eval.validate(set, holdout > 0); err != nil {
+		return err
+	}
+	if err := progress.validate(); err != nil {
+		return err
+	}
+	if err := checkpoint.validate(set, train.model); err != nil {
+		return err
+	}
+	if err := f.drift.validate(set); err != nil {
+		return err
+	}
+	if done, err := conf.dump(fs, "", disallowed[mode]); err != nil || done {
+		return err
+	}
+	f.config = resolvedConfig(fs, "", disallowed[mode])
+
+	opts := forecastOptions{Steps: f.steps, Holdout: holdout, Train: train.config(), Timeout: train.timeout}
+	prep.apply(&opts)
+	eval.apply(&opts)
+	if err := checkpoint.apply(&opts); err != nil {
+		return err
+	}
+
+	switch mode {
+	case "serve":
+		if err := serve.validate(); err != nil {
+			return err
+		}
+		if err := runServe(ctx, serve, opts); err != nil {
+			return fmt.Errorf("server failed: %w", err)
+		}
+		return nil
+	case "detect":
+		return progress.run(&opts.Train, func() error {
+			return runDetectFromFile(ctx, f.dataPath, f.loadModelPath, f.saveModelPath, opts, detect, detectOptions{format: f.format, outPath: f.outPath})
+		})
+	}
+	return progress.run(&opts.Train, func() error { return f.run(ctx, opts) })
+}
+
+// runCLI dispatches to a subcommand, or to the flat invocation when the
+// first argument is a flag (or there are none).
+func runCLI(ctx context.Context, args []string) error {
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name := args[0]
+		if name == "help" {
+			if len(args) > 1 {
+				if c, ok := findCommand(args[1]); ok {
+					return c.run(ctx, []string{"-h"})
+				}
+			}
+			printCommands(os.Stdout)
+			return nil
+		}
+		c, ok := findCommand(name)
+		if !ok {
+			return fmt.Errorf("unknown command %q (see \"oracle help\")", name)
+		}
+		return c.run(ctx, args[1:])
+	}
+	return runFlat(ctx, args)
+}
+
+// exitCode maps CLI errors to process exit codes: -h is not a failure,
+// flag errors use the conventional status 2 and an interrupted run 130, as
+// shells report SIGINT.
+func exitCode(err error) int {
+	var usage usageError
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp):
+		return 0
+	case errors.As(err, &usage):
+		return 2
+	case errors.Is(err, context.Canceled):
+		return 130
+	default:
+		return 1
+	}
+}
@@ -0,0 +1,472 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"oracle/internal/oracle"
+)
+
+// silenceStdout discards what commands print while the test runs.
+func silenceStdout(t *testing.T) {
+	t.Helper()
+	devNull, err := os.Open(os.DevNull)
+	if err != nil {
+		t.Fatalf("open %s: %v", os.DevNull, err)
+	}
+	stdout := os.Stdout
+	os.Stdout = devNull
+	t.Cleanup(func() {
+		os.Stdout = stdout
+		devNull.Close()
+	})
+}
+
+func TestRunCLIRejectsConflictingFlags(t *testing.T) {
+	dir := t.TempDir()
+	model := filepath.Join(dir, "model.json")
+	result, err := oracle.Train(linearSeries(30), oracle.TrainConfig{Lag: 4, Hidden: 4, Epochs: 50, Seed: 1})
+	if err != nil {
+		t.Fatalf("train failed: %v", err)
+	}
+	if err := oracle.SaveModel(model, result); err != nil {
+		t.Fatalf("SaveModel failed: %v", err)
+	}
+
+	cases := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"-load-model", model, "-lag", "3"}, "-lag cannot be combined with -load-model"},
+		{[]string{"forecast", "-load-model", model, "-epochs", "10"}, "-epochs cannot be combined with -load-model"},
+		{[]string{"evaluate", "-load-model", model, "-seed", "1"}, "-seed cannot be combined with -load-model"},
+		{[]string{"-stream"}, "-stream cannot be used with -mode forecast"},
+		{[]string{"-mode", "serve", "-data", "x.csv"}, "-data cannot be used with -mode serve"},
+		{[]string{"-mode", "detect", "-steps", "3"}, "-steps cannot be used with -mode detect"},
+		{[]string{"-train-after-break"}, "-train-after-break requires -changepoints"},
+		{[]string{"predict"}, "predict requires -load-model"},
+		{[]string{"evaluate", "-holdout", "0"}, "must be positive"},
+		{[]string{"inspect"}, "requires a model path"},
+		{[]string{"forecast", "-model", "croston", "-lag", "3"}, "-lag cannot be combined with -model croston"},
+		{[]string{"forecast", "-model", "arima"}, "invalid -model"},
+		{[]string{"forecast", "-load-model", model, "-model", "sba"}, "-model cannot be combined with -load-model"},
+		{[]string{"batch", "-data", "x.csv", "-global", "-model", "tsb"}, "-global requires -model mlp"},
+		{[]string{"temporal"}, "temporal requires -levels"},
+		{[]string{"temporal", "-levels", "7,x"}, "invalid -levels entry"},
+		{[]string{"temporal", "-levels", "7", "-reconcile", "median"}, "invalid -reconcile"},
+		{[]string{"forecast", "-lower", "5", "-upper", "1"}, "invalid constraints"},
+		{[]string{"forecast", "-load-model", model, "-lower", "0"}, "-lower cannot be combined with -load-model"},
+		{[]string{"batch", "-data", "x.csv", "-hierarchy", "h.csv", "-integer"}, "-integer cannot be combined with -hierarchy"},
+		{[]string{"temporal", "-levels", "7", "-total", "10"}, "-total cannot be combined with -levels"},
+		{[]string{"train", "-metrics", "mae"}, "-metrics requires -holdout"},
+		{[]string{"evaluate", "-season", "0"}, "invalid -season"},
+		{[]string{"backtest", "-metrics", "mae,wape"}, "invalid -metrics"},
+		{[]string{"-mode", "detect", "-season", "7"}, "-season cannot be used with -mode detect"},
+		{[]string{"evaluate", "-diagnostic-lags", "4"}, "-diagnostic-lags requires -diagnostics"},
+		{[]string{"-mode", "detect", "-report", "r.html"}, "-report cannot be used with -mode detect"},
+		{[]string{"forecast", "-plot", "-format", "json"}, "-plot requires -format text"},
+		{[]string{"backtest", "-chart", "folds.jpg"}, "use a .svg or .png file"},
+		{[]string{"train", "-epoch-log", "epochs.txt"}, "invalid -epoch-log"},
+		{[]string{"-mode", "serve", "-progress"}, "-progress cannot be used with -mode serve"},
+		{[]string{"train", "-timeout", "-1s"}, "invalid -timeout"},
+		{[]string{"train", "-resume"}, "-resume requires -checkpoint"},
+		{[]string{"forecast", "-model", "croston", "-checkpoint", "c.json"}, "-checkpoint cannot be combined with -model croston"},
+		{[]string{"evaluate", "-load-model", model, "-checkpoint", "c.json"}, "-checkpoint cannot be combined with -load-model"},
+		{[]string{"forecast", "-load-model", model, "-timeout", "1m"}, "-timeout cannot be combined with -load-model"},
+		{[]string{"update"}, "update requires -load-model"},
+		{[]string{"update", "-load-model", model}, "update requires -new"},
+		{[]string{"update", "-load-model", model, "-new", "3"}, "update requires -save-model"},
+		{[]string{"update", "-load-model", model, "-new", "3", "-save-model", model}, "-save-model must differ from -load-model"},
+		{[]string{"stream"}, "stream requires -load-model"},
+		{[]string{"stream", "-load-model", model, "-interval", "1"}, "invalid -interval"},
+		{[]string{"forecast", "-drift", "fail"}, "-drift requires -load-model"},
+		{[]string{"predict", "-load-model", model, "-drift", "loud"}, "invalid -drift"},
+		{[]string{"predict", "-load-model", model, "-drift-ks", "1.5"}, "invalid -drift-ks"},
+		{[]string{"-mode", "detect", "-drift-psi", "0.1"}, "-drift-psi cannot be used with -mode detect"},
+		{[]string{"evaluate", "-load-model", model, "-diagnostics"}, "-diagnostics cannot be combined with -load-model"},
+		{[]string{"nope"}, "unknown command"},
+	}
+	for _, tc := range cases {
+		err := runCLI(context.Background(), tc.args)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("runCLI(%q) error = %v, want %q", tc.args, err, tc.want)
+		}
+	}
+}
+
+func TestRunCLIFlatAndSubcommands(t *testing.T) {
+	silenceStdout(t)
+	dir := t.TempDir()
+	data := filepath.Join(dir, "series.csv")
+	var b strings.Builder
+	for _, v := range linearSeries(30) {
+		b.WriteString(formatValue(v) + "\n")
+	}
+	if err := os.WriteFile(data, []byte(b.String()), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	model := filepath.Join(dir, "model.json")
+	report := filepath.Join(dir, "report.html")
+	epochLog := filepath.Join(dir, "epochs.csv")
+
+	runs := [][]string{
+		{"-data", data, "-epochs", "50", "-steps", "2"},
+		{"train", "-data", data, "-epochs", "50", "-lag", "4", "-save-model", model},
+		{"predict", "-data", data, "-load-model", model, "-format", "json"},
+		{"evaluate", "-data", data, "-load-model", model, "-holdout", "4"},
+		{"backtest", "-data", data, "-epochs", "50", "-folds", "2", "-horizon", "3", "-chart", filepath.Join(dir, "folds.png")},
+		{"inspect", model},
+		{"evaluate", "-data", data, "-epochs", "50", "-holdout", "6", "-season", "3", "-metrics", "mase,rmsse,r2", "-format", "json"},
+		{"evaluate", "-data", data, "-epochs", "50", "-holdout", "6", "-diagnostics", "-diagnostic-lags", "3"},
+		{"train", "-data", data, "-model", "tsb", "-holdout", "4", "-format", "json"},
+		{"forecast", "-data", data, "-epochs", "50", "-steps", "3", "-lower", "0", "-integer", "-total", "90"},
+		{"temporal", "-data", data, "-epochs", "50", "-levels", "2,4", "-reconcile", "wls", "-out", filepath.Join(dir, "temporal.csv")},
+		{"forecast", "-data", data, "-epochs", "50", "-holdout", "5", "-report", report},
+		{"train", "-data", data, "-epochs", "20", "-holdout", "4", "-epoch-log", epochLog},
+	}
+	for _, args := range runs {
+		if err := runCLI(context.Background(), args); err != nil {
+			t.Fatalf("runCLI(%q) failed: %v", args, err)
+		}
+	}
+
+	// The holdout fit and the final refit are logged as fits 1 and 2.
+	logged, err := os.ReadFile(epochLog)
+	if err != nil {
+		t.Fatalf("epoch log not written: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(logged)), "\n")
+	if len(lines) != 41 || lines[0] != "fit,epoch,epochs,loss,learning_rate,elapsed_seconds" || !strings.HasPrefix(lines[40], "2,20,20,") {
+		t.Fatalf("unexpected epoch log:\n%s", logged)
+	}
+
+	// An interrupted run and one past its -timeout stop training early.
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = runCLI(canceled, []string{"train", "-data", data, "-epochs", "50"})
+	if !errors.Is(err, context.Canceled) || exitCode(err) != 130 {
+		t.Fatalf("interrupted train error = %v, want context.Canceled and exit code 130", err)
+	}
+	err = runCLI(context.Background(), []string{"train", "-data", data, "-epochs", "50", "-timeout", "1ns"})
+	if !errors.Is(err, context.DeadlineExceeded) || exitCode(err) != 1 {
+		t.Fatalf("timed out train error = %v, want context.DeadlineExceeded and exit code 1", err)
+	}
+
+	body, err := os.ReadFile(report)
+	if err != nil {
+		t.Fatalf("report not written: %v", err)
+	}
+	html := string(body)
+	if strings.Count(html, "<svg") != 4 || strings.Contains(html, "<script") || !strings.Contains(html, "validation.holdout") {
+		t.Fatalf("report should hold four inline charts, the config and no scripts:\n%s", html)
+	}
+}
+
+func TestTrainResumesFromCheckpoint(t *testing.T) {
+	silenceStdout(t)
+	dir := t.TempDir()
+	data := filepath.Join(dir, "series.csv")
+	var b strings.Builder
+	for _, v := range linearSeries(30) {
+		b.WriteString(formatValue(v) + "\n")
+	}
+	if err := os.WriteFile(data, []byte(b.String()), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	// The first run leaves a checkpoint of the full-data refit at epoch 30;
+	// resuming it for 60 epochs must match a run that trained 60 at once.
+	runs := [][]string{
+		{"train", "-data", data, "-epochs", "60", "-holdout", "4", "-save-model", path("straight.json")},
+		{"train", "-data", data, "-epochs", "30", "-holdout", "4", "-checkpoint", path("ckpt.json"), "-checkpoint-every", "7"},
+		{"train", "-data", data, "-epochs", "60", "-holdout", "4", "-checkpoint", path("ckpt.json"), "-resume", "-save-model", path("resumed.json")},
+	}
+	for _, args := range runs {
+		if err := runCLI(context.Background(), args); err != nil {
+			t.Fatalf("runCLI(%q) failed: %v", args, err)
+		}
+	}
+	straight, err := os.ReadFile(path("straight.json"))
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	resumed, err := os.ReadFile(path("resumed.json"))
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if string(straight) != string(resumed) {
+		t.Fatalf("resumed model differs from the uninterrupted one")
+	}
+
+	err = runCLI(context.Background(), []string{"train", "-data", data, "-epochs", "60", "-seed", "7", "-checkpoint", path("ckpt.json"), "-resume"})
+	if err == nil || !strings.Contains(err.Error(), "cannot resume") {
+		t.Fatalf("resuming with another seed error = %v, want a mismatch", err)
+	}
+}
+
+func TestUpdateSavesNextVersion(t *testing.T) {
+	silenceStdout(t)
+	dir := filepath.Join(t.TempDir(), "models")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	series := linearSeries(40)
+	result, err := oracle.Train(series[:30], oracle.TrainConfig{Lag: 4, Hidden: 4, Epochs: 50, Seed: 1})
+	if err != nil {
+		t.Fatalf("train failed: %v", err)
+	}
+	if err := oracle.SaveModel(filepath.Join(dir, "3.json"), result); err != nil {
+		t.Fatalf("SaveModel failed: %v", err)
+	}
+	data := filepath.Join(dir, "..", "series.csv")
+	var b strings.Builder
+	for _, v := range series {
+		b.WriteString(formatValue(v) + "\n")
+	}
+	if err := os.WriteFile(data, []byte(b.String()), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	args := []string{"update", "-data", data, "-load-model", filepath.Join(dir, "3.json"), "-new", "10", "-epochs", "20"}
+	if err := runCLI(context.Background(), args); err != nil {
+		t.Fatalf("runCLI(%q) failed: %v", args, err)
+	}
+	updated, err := oracle.LoadModel(filepath.Join(dir, "4.json"))
+	if err != nil {
+		t.Fatalf("LoadModel failed: %v", err)
+	}
+	if updated.Scaler != result.Scaler || updated.ResidualStdDev == result.ResidualStdDev {
+		t.Fatalf("updated model has scaler %+v and residual std dev %v", updated.Scaler, updated.ResidualStdDev)
+	}
+}
+
+func TestStreamPredictWritesNDJSON(t *testing.T) {
+	series := linearSeries(30)
+	result, err := oracle.Train(series[:20], oracle.TrainConfig{Lag: 4, Hidden: 4, Epochs: 200, Seed: 1})
+	if err != nil {
+		t.Fatalf("train failed: %v", err)
+	}
+	// The first lag values only fill the window; "x" is reported and skipped,
+	// the blank line is ignored.
+	var in strings.Builder
+	for i, v := range series[10:] {
+		if i == 6 {
+			in.WriteString("x\n\n")
+		}
+		in.WriteString(formatValue(v) + "\n")
+	}
+	var out, log bytes.Buffer
+	if err := streamPredict(context.Background(), result, nil, oracle.StreamConfig{}, strings.NewReader(in.String()), &out, &log); err != nil {
+		t.Fatalf("streamPredict failed: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 16 {
+		t.Fatalf("got %d NDJSON lines, want 16:\n%s", len(lines), out.String())
+	}
+	var last StreamStepPayload
+	if err := json.Unmarshal([]byte(lines[len(lines)-1]), &last); err != nil {
+		t.Fatalf("invalid NDJSON line %q: %v", lines[len(lines)-1], err)
+	}
+	if last.Index != 19 || last.Next.Index != 20 || last.Metrics.Count != 16 || last.Low > last.Predicted || last.High < last.Predicted {
+		t.Fatalf("last line = %+v", last)
+	}
+	if !strings.Contains(log.String(), "Scored values    : 16") || !strings.Contains(log.String(), `Skipped line 7: "x" is not a finite number`) {
+		t.Fatalf("log = %q", log.String())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r, w := io.Pipe()
+	defer w.Close()
+	err = streamPredict(ctx, result, series, oracle.StreamConfig{}, r, io.Discard, io.Discard)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("canceled stream error = %v", err)
+	}
+}
+
+func TestStreamDetectSkipsInvalidLines(t *testing.T) {
+	series := linearSeries(30)
+	result, err := oracle.Train(series, oracle.TrainConfig{Lag: 4, Hidden: 4, Epochs: 200, Seed: 1})
+	if err != nil {
+		t.Fatalf("train failed: %v", err)
+	}
+	newDetector := func() *oracle.Detector {
+		detector, err := oracle.NewDetector(result, series, oracle.DetectConfig{})
+		if err != nil {
+			t.Fatalf("NewDetector failed: %v", err)
+		}
+		return detector
+	}
+
+	var out, log bytes.Buffer
+	in := "10\nNaN\n\nInf\nx\n11\n"
+	if err := streamDetect(context.Background(), newDetector(), strings.NewReader(in), &out, &log); err != nil {
+		t.Fatalf("streamDetect failed: %v", err)
+	}
+	if lines := strings.Split(strings.TrimSpace(out.String()), "\n"); len(lines) != 2 {
+		t.Fatalf("got %d NDJSON lines, want 2:\n%s", len(lines), out.String())
+	}
+	for _, want := range []string{`Skipped line 2: "NaN"`, `Skipped line 4: "Inf"`, `Skipped line 5: "x"`} {
+		if !strings.Contains(log.String(), want) {
+			t.Fatalf("log = %q, want %q", log.String(), want)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	r, w := io.Pipe()
+	defer w.Close()
+	err = streamDetect(ctx, newDetector(), r, io.Discard, io.Discard)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("canceled stream error = %v", err)
+	}
+}
+
+func TestPredictChecksDrift(t *testing.T) {
+	silenceStdout(t)
+	dir := t.TempDir()
+	path := func(name string) string { return filepath.Join(dir, name) }
+	writeSeries := func(name string, series []float64) {
+		var b strings.Builder
+		for _, v := range series {
+			b.WriteString(formatValue(v) + "\n")
+		}
+		if err := os.WriteFile(path(name), []byte(b.String()), 0o644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+	series := make([]float64, 60)
+	for i := range series {
+		series[i] = 20 + 3*float64(i%6)
+	}
+	writeSeries("train.csv", series)
+	shifted := append(append([]float64(nil), series...), 80, 85, 90, 95, 100, 105)
+	writeSeries("shifted.csv", shifted)
+
+	if err := runCLI(context.Background(), []string{"train", "-data", path("train.csv"), "-lag", "6", "-epochs", "300", "-save-model", path("model.json")}); err != nil {
+		t.Fatalf("train failed: %v", err)
+	}
+	if err := runCLI(context.Background(), []string{"predict", "-data", path("train.csv"), "-load-model", path("model.json"), "-drift", "fail"}); err != nil {
+		t.Fatalf("predict on the training data failed: %v", err)
+	}
+	err := runCLI(context.Background(), []string{"predict", "-data", path("shifted.csv"), "-load-model", path("model.json"), "-drift", "fail", "-drift-window", "6"})
+	if err == nil || !strings.Contains(err.Error(), "data drift detected") {
+		t.Fatalf("predict on shifted data error = %v, want drift", err)
+	}
+	if err := runCLI(context.Background(), []string{"predict", "-data", path("shifted.csv"), "-load-model", path("model.json"), "-drift-window", "6"}); err != nil {
+		t.Fatalf("-drift warn should not fail: %v", err)
+	}
+
+	legacy, err := oracle.LoadModel(path("model.json"))
+	if err != nil {
+		t.Fatalf("LoadModel failed: %v", err)
+	}
+	legacy.Stats = nil
+	if err := oracle.SaveModel(path("legacy.json"), legacy); err != nil {
+		t.Fatalf("SaveModel failed: %v", err)
+	}
+	if err := runCLI(context.Background(), []string{"predict", "-data", path("train.csv"), "-load-model", path("legacy.json")}); err != nil {
+		t.Fatalf("predict with a model without stats failed: %v", err)
+	}
+	err = runCLI(context.Background(), []string{"predict", "-data", path("train.csv"), "-load-model", path("legacy.json"), "-drift", "fail"})
+	if err == nil || !strings.Contains(err.Error(), "no training statistics") {
+		t.Fatalf("-drift fail without stats error = %v", err)
+	}
+}
+
+func TestPrepareSeriesDetectsChangepointsBeforeHoldout(t *testing.T) {
+	series := make([]float64, 80)
+	for i := range series {
+		series[i] = 10 + 0.5*float64(i*7%5-2)
+		if i >= 40 {
+			series[i] += 20
+		}
+		if i >= 76 {
+			series[i] += 20
+		}
+	}
+	opts := forecastOptions{
+		Train:           oracle.TrainConfig{Lag: 4},
+		Changepoints:    &oracle.ChangepointConfig{Method: "pelt"},
+		TrainAfterBreak: true,
+	}
+	_, _, all, err := prepareSeries(series, opts)
+	if err == nil {
+		t.Fatalf("expected the break at 76 to leave too few points, got %+v", all)
+	}
+
+	opts.Holdout = 6
+	prepared, _, cps, err := prepareSeries(series, opts)
+	if err != nil {
+		t.Fatalf("prepareSeries failed: %v", err)
+	}
+	if cps.TrainedFrom != 40 || len(prepared) != 40 {
+		t.Fatalf("trained from %d (%d points), want the break at 40 before the holdout; changepoints %v", cps.TrainedFrom, len(prepared), cps.Indices)
+	}
+}
+
+func TestPredictChecksDriftOnPreparedSeries(t *testing.T) {
+	silenceStdout(t)
+	dir := t.TempDir()
+	dataPath := filepath.Join(dir, "shift.csv")
+	modelPath := filepath.Join(dir, "model.json")
+	// A level shift at index 60 and one spike: the raw series looks nothing
+	// like the cleaned tail the model is trained on.
+	var b strings.Builder
+	for i := 0; i < 120; i++ {
+		v := 10 + 0.5*float64(i*7%5-2)
+		if i >= 60 {
+			v += 40
+		}
+		if i == 90 {
+			v += 30
+		}
+		b.WriteString(formatValue(v) + "\n")
+	}
+	if err := os.WriteFile(dataPath, []byte(b.String()), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	prep := []string{"-data", dataPath, "-changepoints", "pelt", "-train-after-break", "-outliers", "hampel", "-outlier-action", "replace"}
+	if err := runCLI(context.Background(), append([]string{"train", "-lag", "4", "-epochs", "300", "-save-model", modelPath}, prep...)); err != nil {
+		t.Fatalf("train failed: %v", err)
+	}
+	if err := runCLI(context.Background(), append([]string{"predict", "-load-model", modelPath, "-drift", "fail"}, prep...)); err != nil {
+		t.Fatalf("predict with the training preprocessing reported drift: %v", err)
+	}
+	err := runCLI(context.Background(), []string{"predict", "-data", dataPath, "-load-model", modelPath, "-drift", "fail"})
+	if err == nil || !strings.Contains(err.Error(), "data drift detected") {
+		t.Fatalf("predict on the raw series error = %v, want drift", err)
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	fs := flag.NewFlagSet("x", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	parseErr := parseFlags(fs, []string{"-missing"})
+
+	cases := []struct {
+		err  error
+		want int
+	}{
+		{nil, 0},
+		{flag.ErrHelp, 0},
+		{parseErr, 2},
+		{errors.New("boom"), 1},
+	}
+	for _, tc := range cases {
+		if got := exitCode(tc.err); got != tc.want {
+			t.Fatalf("exitCode(%v) = %d, want %d", tc.err, got, tc.want)
+		}
+	}
+}
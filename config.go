@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// configField maps a dotted key of the JSON run config to the flag it sets.
+// A key is only written out by -dump-config when the flag named by requires
+// is enabled (or has the value given as name=value) and the one named by
+// excludedBy is not, so a dumped config never trips the conflict checks
+// when it is read back.
+type configField struct {
+	key        string
+	flag       string
+	requires   string
+	excludedBy string
+}
+
+var configFields = []configField{
+	{key: "data.path", flag: "data"},
+	{key: "preprocess.outliers.method", flag: "outliers"},
+	{key: "preprocess.outliers.action", flag: "outlier-action", requires: "outliers"},
+	{key: "preprocess.outliers.window", flag: "outlier-window", requires: "outliers"},
+	{key: "preprocess.outliers.threshold", flag: "outlier-threshold", requires: "outliers"},
+	{key: "preprocess.changepoints.method", flag: "changepoints"},
+	{key: "preprocess.changepoints.cost", flag: "changepoint-cost", requires: "changepoints"},
+	{key: "preprocess.changepoints.penalty", flag: "changepoint-penalty", requires: "changepoints"},
+	{key: "preprocess.changepoints.min_segment", flag: "changepoint-min-segment", requires: "changepoints"},
+	{key: "preprocess.changepoints.train_after_break", flag: "train-after-break", requires: "changepoints"},
+	{key: "model.load", flag: "load-model"},
+	{key: "model.type", flag: "model", excludedBy: "load-model"},
+	{key: "model.lag", flag: "lag", requires: "model=mlp", excludedBy: "load-model"},
+	{key: "model.hidden", flag: "hidden", requires: "model=mlp", excludedBy: "load-model"},
+	{key: "model.epochs", flag: "epochs", requires: "model=mlp", excludedBy: "load-model"},
+	{key: "model.learning_rate", flag: "lr", requires: "model=mlp", excludedBy: "load-model"},
+	{key: "model.seed", flag: "seed", requires: "model=mlp", excludedBy: "load-model"},
+	{key: "model.timeout", flag: "timeout", excludedBy: "load-model"},
+	{key: "constraints.lower", flag: "lower", excludedBy: "load-model"},
+	{key: "constraints.upper", flag: "upper", excludedBy: "load-model"},
+	{key: "constraints.integer", flag: "integer", excludedBy: "load-model"},
+	{key: "constraints.total", flag: "total", excludedBy: "load-model"},
+	{key: "validation.holdout", flag: "holdout"},
+	{key: "validation.folds", flag: "folds"},
+	{key: "validation.horizon", flag: "horizon"},
+	{key: "validation.step", flag: "step"},
+	{key: "validation.season", flag: "season"},
+	{key: "validation.metrics", flag: "metrics"},
+	{key: "forecast.steps", flag: "steps"},
+	{key: "diagnostics.enabled", flag: "diagnostics", excludedBy: "load-model"},
+	{key: "diagnostics.lags", flag: "diagnostic-lags", requires: "diagnostics", excludedBy: "load-model"},
+	{key: "detect.z", flag: "detect-z"},
+	{key: "detect.interval", flag: "detect-interval"},
+	{key: "detect.stream", flag: "stream"},
+	{key: "batch.id_column", flag: "id-column"},
+	{key: "batch.value_column", flag: "value-column"},
+	{key: "batch.time_column", flag: "time-column"},
+	{key: "batch.workers", flag: "workers", excludedBy: "global"},
+	{key: "batch.global", flag: "global"},
+	{key: "batch.series_features", flag: "series-features", requires: "global"},
+	{key: "batch.embedding_dim", flag: "embedding-dim", requires: "series-features=embedding"},
+	{key: "batch.hierarchy", flag: "hierarchy"},
+	{key: "batch.reconcile", flag: "reconcile", requires: "hierarchy"},
+	{key: "temporal.levels", flag: "levels"},
+	{key: "temporal.reconcile", flag: "reconcile", requires: "levels"},
+	{key: "drift.mode", flag: "drift", requires: "load-model"},
+	{key: "drift.window", flag: "drift-window", requires: "load-model"},
+	{key: "drift.psi", flag: "drift-psi", requires: "load-model"},
+	{key: "drift.ks_p_value", flag: "drift-ks", requires: "load-model"},
+	{key: "drift.residual_shift", flag: "drift-residual", requires: "load-model"},
+	{key: "output.format", flag: "format"},
+	{key: "output.csv", flag: "out"},
+	{key: "output.metrics", flag: "metrics-file"},
+	{key: "output.report", flag: "report"},
+	{key: "output.chart", flag: "chart"},
+	{key: "output.epoch_log", flag: "epoch-log"},
+	{key: "output.checkpoint", flag: "checkpoint", excludedBy: "load-model"},
+	{key: "output.checkpoint_every", flag: "checkpoint-every", requires: "checkpoint"},
+	{key: "output.resume", flag: "resume", requires: "checkpoint"},
+	{key: "output.save_model", flag: "save-model"},
+}
+
+type configFlags struct {
+	path     string
+	dumpPath string
+}
+
+func (c *configFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&c.path, "config", "", "JSON run config; flags given on the command line override its values")
+	fs.StringVar(&c.dumpPath, "dump-config", "", "write the resolved run config to this path (- prints it and exits without running)")
+}
+
+// apply reads the config file and sets every flag it mentions that was not
+// given on the command line. command is the subcommand being run, or empty
+// for the flat invocation, where the file's command selects -mode.
+func (c configFlags) apply(fs *flag.FlagSet, command string) error {
+	if c.path == "" {
+		return nil
+	}
+	body, err := os.ReadFile(c.path)
+	if err != nil {
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+	values, err := parseConfig(body)
+	if err != nil {
+		return fmt.Errorf("invalid config %s: %w", c.path, err)
+	}
+
+	explicit := flagsSet(fs)
+	set := func(name, key, value string) error {
+		if explicit[name] {
+			return nil
+		}
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("invalid config %s: %s: %w", c.path, key, err)
+		}
+		return nil
+	}
+
+	if v, ok := values["command"]; ok {
+		switch {
+		case command == "":
+			if err := set("mode", "command", v); err != nil {
+				return err
+			}
+		case v != command:
+			return fmt.Errorf("config %s is for the %s command, not %s", c.path, v, command)
+		}
+	}
+
+	for _, field := range configFields {
+		v, ok := values[field.key]
+		if !ok {
+			continue
+		}
+		required, _, _ := strings.Cut(field.requires, "=")
+		if fs.Lookup(field.flag) == nil || (required != "" && fs.Lookup(required) == nil) {
+			return fmt.Errorf("invalid config %s: %s does not apply here", c.path, field.key)
+		}
+		if err := set(field.flag, field.key, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dump writes the resolved config if -dump-config was given. It reports
+// whether the command should stop instead of running. Flags in skip are left
+// out, for those the current mode rejects.
+func (c configFlags) dump(fs *flag.FlagSet, command string, skip []string) (bool, error) {
+	if c.dumpPath == "" {
+		return false, nil
+	}
+	resolved := resolvedConfig(fs, command, skip)
+	if c.dumpPath == "-" {
+		return true, printJSON(resolved)
+	}
+
+	body, err := json.MarshalIndent(resolved, "", "  ")
+	if err != nil {
+		return false, fmt.Errorf("failed to encode config: %w", err)
+	}
+	if err := os.WriteFile(c.dumpPath, append(body, '\n'), 0o644); err != nil {
+		return false, fmt.Errorf("failed writing config: %w", err)
+	}
+	return false, nil
+}
+
+// parseConfig flattens the JSON document into dotted keys with the values in
+// the form flag.Value.Set expects. Empty strings and nulls count as absent;
+// unknown keys are errors so typos do not go unnoticed.
+func parseConfig(body []byte) (map[string]string, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+	var doc map[string]any
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+
+	known := map[string]bool{"command": true}
+	for _, field := range configFields {
+		known[field.key] = true
+	}
+
+	values := map[string]string{}
+	var walk func(prefix string, node map[string]any) error
+	walk = func(prefix string, node map[string]any) error {
+		keys := make([]string, 0, len(node))
+		for k := range node {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			key := prefix + k
+			switch v := node[k].(type) {
+			case map[string]any:
+				if err := walk(key+".", v); err != nil {
+					return err
+				}
+				continue
+			case nil:
+				continue
+			case string:
+				if v == "" {
+					continue
+				}
+				values[key] = v
+			case json.Number:
+				values[key] = v.String()
+			case bool:
+				values[key] = fmt.Sprint(v)
+			default:
+				return fmt.Errorf("%s: unsupported value %v", key, v)
+			}
+			if !known[key] {
+				return fmt.Errorf("unknown key %q", key)
+			}
+		}
+		return nil
+	}
+	if err := walk("", doc); err != nil {
+		return nil, err
+	}
+	return values, nil
+}
+
+// resolvedConfig builds the config document that reproduces the current
+// flag values. Empty strings are left out, as they are on input.
+func resolvedConfig(fs *flag.FlagSet, command string, skip []string) map[string]any {
+	doc := map[string]any{"command": command}
+	if command == "" {
+		doc["command"] = flagValue(fs, "mode")
+	}
+
+	for _, field := range configFields {
+		if fs.Lookup(field.flag) == nil || containsString(skip, field.flag) {
+			continue
+		}
+		if field.requires != "" && !flagEnabled(fs, field.requires) {
+			continue
+		}
+		if field.excludedBy != "" && flagEnabled(fs, field.excludedBy) {
+			continue
+		}
+		if v := flagValue(fs, field.flag); v != "" {
+			setConfigKey(doc, field.key, v)
+		}
+	}
+	return doc
+}
+
+func setConfigKey(doc map[string]any, key string, value any) {
+	parts := strings.Split(key, ".")
+	node := doc
+	for _, part := range parts[:len(parts)-1] {
+		child, ok := node[part].(map[string]any)
+		if !ok {
+			child = map[string]any{}
+			node[part] = child
+		}
+		node = child
+	}
+	node[parts[len(parts)-1]] = value
+}
+
+func flagValue(fs *flag.FlagSet, name string) any {
+	f := fs.Lookup(name)
+	if getter, ok := f.Value.(flag.Getter); ok {
+		// Durations are written the way they are parsed, e.g. "1m30s".
+		if d, ok := getter.Get().(time.Duration); ok {
+			return d.String()
+		}
+		return getter.Get()
+	}
+	return f.Value.String()
+}
+
+// flagEnabled reports whether a string flag is non-empty or a bool flag is
+// true, or for name=value whether the flag has that value; missing flags
+// count as disabled.
+func flagEnabled(fs *flag.FlagSet, name string) bool {
+	if name, value, ok := strings.Cut(name, "="); ok {
+		return fs.Lookup(name) != nil && strings.EqualFold(fs.Lookup(name).Value.String(), value)
+	}
+	if fs.Lookup(name) == nil {
+		return false
+	}
+	switch v := flagValue(fs, name).(type) {
+	case string:
+		return v != ""
+	case bool:
+		return v
+	}
+	return true
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
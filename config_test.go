@@ -0,0 +1,134 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfig(t *testing.T, body string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "run.json")
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	return path
+}
+
+func TestConfigFlagsOverrideFile(t *testing.T) {
+	var (
+		conf  configFlags
+		train trainFlags
+		prep  prepFlags
+	)
+	fs := newFlagSet("train")
+	conf.register(fs)
+	train.register(fs)
+	prep.register(fs)
+
+	path := writeConfig(t, `{
+		"command": "train",
+		"model": {"type": "mlp", "lag": 4, "epochs": 300, "seed": 9007199254740993},
+		"preprocess": {"outliers": {"method": "iqr", "threshold": 2}}
+	}`)
+	if err := parseFlags(fs, []string{"-config", path, "-epochs", "50"}); err != nil {
+		t.Fatalf("parseFlags failed: %v", err)
+	}
+	if err := conf.apply(fs, "train"); err != nil {
+		t.Fatalf("apply failed: %v", err)
+	}
+
+	if train.lag != 4 || train.epochs != 50 || train.seed != 9007199254740993 {
+		t.Fatalf("unexpected train flags: %+v", train)
+	}
+	if prep.outlierMethod != "iqr" || prep.outlierThresh != 2 || prep.outlierAction != "flag" {
+		t.Fatalf("unexpected prep flags: %+v", prep)
+	}
+	if !flagsSet(fs)["lag"] {
+		t.Fatalf("config values should count as set for conflict checks")
+	}
+}
+
+func TestConfigRejectsBadFiles(t *testing.T) {
+	cases := []struct {
+		body string
+		want string
+	}{
+		{`{"model": {"lagg": 3}}`, `unknown key "model.lagg"`},
+		{`{"model": {"type": "arima"}}`, `invalid -model: "arima"`},
+		{`{"model": {"type": "tsb", "hidden": 4}}`, "-hidden cannot be combined with -model tsb"},
+		{`{"model": {"lag": "six"}}`, "model.lag"},
+		{`{"command": "forecast"}`, "is for the forecast command, not train"},
+		{`{"detect": {"z": 3}}`, "detect.z does not apply here"},
+		{`{"data": ["a.csv"]}`, "unsupported value"},
+	}
+	for _, tc := range cases {
+		var (
+			conf  configFlags
+			train trainFlags
+		)
+		fs := newFlagSet("train")
+		conf.register(fs)
+		train.register(fs)
+		if err := parseFlags(fs, []string{"-config", writeConfig(t, tc.body)}); err != nil {
+			t.Fatalf("parseFlags failed: %v", err)
+		}
+		err := conf.apply(fs, "train")
+		if err == nil {
+			err = train.validate(flagsSet(fs))
+		}
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("apply(%s) error = %v, want %q", tc.body, err, tc.want)
+		}
+	}
+}
+
+func TestDumpConfigRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.json")
+	second := filepath.Join(dir, "second.json")
+	silenceStdout(t)
+
+	args := []string{"-dump-config", first, "-outliers", "hampel", "-changepoints", "pelt", "-lag", "5", "-steps", "3", "-holdout", "2"}
+	if err := runCLI(context.Background(), append([]string{"forecast"}, append(args, "-dump-config", "-", "-data", "missing.csv")...)); err != nil {
+		t.Fatalf("dump to stdout should not run the forecast: %v", err)
+	}
+
+	if err := runCLI(context.Background(), append([]string{"forecast"}, append(args, "-epochs", "30")...)); err != nil {
+		t.Fatalf("forecast failed: %v", err)
+	}
+	if err := runCLI(context.Background(), []string{"forecast", "-config", first, "-dump-config", second}); err != nil {
+		t.Fatalf("forecast from config failed: %v", err)
+	}
+
+	a, err := os.ReadFile(first)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	b, err := os.ReadFile(second)
+	if err != nil {
+		t.Fatalf("ReadFile failed: %v", err)
+	}
+	if string(a) != string(b) {
+		t.Fatalf("config changed after a round trip:\n%s\nvs\n%s", a, b)
+	}
+
+	var doc map[string]any
+	if err := json.Unmarshal(a, &doc); err != nil {
+		t.Fatalf("dumped config is not JSON: %v", err)
+	}
+	prep := doc["preprocess"].(map[string]any)
+	if _, ok := prep["outliers"].(map[string]any)["action"]; !ok {
+		t.Fatalf("enabled stage should list its settings: %s", a)
+	}
+	model := doc["model"].(map[string]any)
+	if model["lag"] != float64(5) || model["epochs"] != float64(30) || model["type"] != "mlp" {
+		t.Fatalf("unexpected model section: %v", model)
+	}
+	if _, ok := model["load"]; ok {
+		t.Fatalf("empty values should be left out: %s", a)
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"oracle/internal/oracle"
+)
+
+type AnomalyPayload struct {
+	Index     int     `json:"index"`
+	Actual    float64 `json:"actual"`
+	Predicted float64 `json:"predicted"`
+	Residual  float64 `json:"residual"`
+	Score     float64 `json:"score"`
+	Low       float64 `json:"low"`
+	High      float64 `json:"high"`
+	Anomaly   bool    `json:"anomaly"`
+}
+
+type DetectOutputPayload struct {
+	DataPoints      int              `json:"data_points"`
+	Lag             int              `json:"lag"`
+	ResidualStdDev  float64          `json:"residual_std_dev"`
+	Threshold       float64          `json:"threshold"`
+	Anomalies       int              `json:"anomalies"`
+	ModelLoadedFrom string           `json:"model_loaded_from,omitempty"`
+	Points          []AnomalyPayload `json:"points"`
+	ScoresCSVPath   string           `json:"scores_csv_path,omitempty"`
+}
+
+func toAnomalyPayload(p oracle.AnomalyPoint) AnomalyPayload {
+	return AnomalyPayload{
+		Index:     p.Index,
+		Actual:    p.Actual,
+		Predicted: p.Predicted,
+		Residual:  p.Residual,
+		Score:     p.Score,
+		Low:       p.Low,
+		High:      p.High,
+		Anomaly:   p.Anomaly,
+	}
+}
+
+func writeAnomalyCSV(path string, points []AnomalyPayload) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	w := csv.NewWriter(file)
+	if err := w.Write([]string{"index", "actual", "predicted", "residual", "score", "low", "high", "anomaly"}); err != nil {
+		return err
+	}
+
+	for _, p := range points {
+		row := []string{
+			strconv.Itoa(p.Index),
+			fmt.Sprintf("%.6f", p.Actual),
+			fmt.Sprintf("%.6f", p.Predicted),
+			fmt.Sprintf("%.6f", p.Residual),
+			fmt.Sprintf("%.6f", p.Score),
+			fmt.Sprintf("%.6f", p.Low),
+			fmt.Sprintf("%.6f", p.High),
+			strconv.FormatBool(p.Anomaly),
+		}
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+
+	w.Flush()
+	return w.Error()
+}
+
+func printDetectText(w io.Writer, payload DetectOutputPayload) {
+	fmt.Fprintln(w, "Oracle - Anomaly Detection")
+	fmt.Fprintf(w, "Data points      : %d\n", payload.DataPoints)
+	fmt.Fprintf(w, "Lag              : %d\n", payload.Lag)
+	fmt.Fprintf(w, "Residual Std Dev : %.6f\n", payload.ResidualStdDev)
+	fmt.Fprintf(w, "Z threshold      : %.4f\n", payload.Threshold)
+	if payload.ModelLoadedFrom != "" {
+		fmt.Fprintf(w, "Model loaded     : %s\n", payload.ModelLoadedFrom)
+	}
+	fmt.Fprintf(w, "Anomalies        : %d of %d scored points\n", payload.Anomalies, len(payload.Points))
+	fmt.Fprintln(w)
+
+	for _, p := range payload.Points {
+		if !p.Anomaly {
+			continue
+		}
+		fmt.Fprintf(w, "#%d -> actual %.4f, predicted %.4f (score %+.2f)\n", p.Index, p.Actual, p.Predicted, p.Score)
+	}
+	if payload.ScoresCSVPath != "" {
+		fmt.Fprintf(w, "\nSaved anomaly scores CSV: %s\n", payload.ScoresCSVPath)
+	}
+}
+
+// streamDetect scores values read line by line from r by readValues and
+// writes one JSON object per scored point to w.
+func streamDetect(ctx context.Context, detector *oracle.Detector, r io.Reader, w, log io.Writer) error {
+	enc := json.NewEncoder(w)
+	return readValues(ctx, r, log, func(v float64) error {
+		point, err := detector.Observe(v)
+		if err != nil {
+			return err
+		}
+		return enc.Encode(toAnomalyPayload(point))
+	})
+}
+
+type detectOptions struct {
+	format      string
+	outPath     string
+	modelLoaded string
+	stream      bool
+}
+
+func runDetect(ctx context.Context, result *oracle.TrainResult, series []float64, cfg oracle.DetectConfig, opts detectOptions) error {
+	points, err := oracle.Detect(result, series, cfg)
+	if err != nil {
+		return fmt.Errorf("detection failed: %w", err)
+	}
+
+	// The streaming detector continues from the end of the file so scores
+	// for stdin values use the same history the batch pass ended with.
+	detector, err := oracle.NewDetector(result, series, cfg)
+	if err != nil {
+		return fmt.Errorf("detection failed: %w", err)
+	}
+
+	payload := DetectOutputPayload{
+		DataPoints:      len(series),
+		Lag:             result.Lag,
+		ResidualStdDev:  result.ResidualStdDev,
+		Threshold:       detector.Threshold(),
+		ModelLoadedFrom: opts.modelLoaded,
+		Points:          make([]AnomalyPayload, 0, len(points)),
+		ScoresCSVPath:   opts.outPath,
+	}
+	for _, p := range points {
+		payload.Points = append(payload.Points, toAnomalyPayload(p))
+		if p.Anomaly {
+			payload.Anomalies++
+		}
+	}
+
+	if opts.outPath != "" {
+		if err := writeAnomalyCSV(opts.outPath, payload.Points); err != nil {
+			return fmt.Errorf("failed writing anomaly CSV: %w", err)
+		}
+	}
+
+	if opts.stream {
+		// stdout carries NDJSON only, so the summary goes to stderr.
+		printDetectText(os.Stderr, payload)
+		if err := streamDetect(ctx, detector, os.Stdin, os.Stdout, os.Stderr); err != nil {
+			return fmt.Errorf("streaming detection failed: %w", err)
+		}
+		return nil
+	}
+
+	if opts.format == "json" {
+		return printJSON(payload)
+	}
+	printDetectText(os.Stdout, payload)
+	return nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"oracle/internal/oracle"
+)
+
+type DiagnosticTestPayload struct {
+	Statistic float64 `json:"statistic"`
+	DF        int     `json:"df"`
+	PValue    float64 `json:"p_value"`
+}
+
+type DiagnosticsPayload struct {
+	Residuals  int                   `json:"residuals"`
+	Mean       float64               `json:"mean"`
+	StdDev     float64               `json:"std_dev"`
+	Skewness   float64               `json:"skewness"`
+	Kurtosis   float64               `json:"excess_kurtosis"`
+	ACF        []float64             `json:"acf"`
+	PACF       []float64             `json:"pacf"`
+	Bound      float64               `json:"acf_bound"`
+	LjungBox   DiagnosticTestPayload `json:"ljung_box"`
+	JarqueBera DiagnosticTestPayload `json:"jarque_bera"`
+	ARCH       DiagnosticTestPayload `json:"arch"`
+	Warnings   []string              `json:"warnings"`
+}
+
+func toDiagnosticsPayload(d *oracle.ResidualDiagnostics) *DiagnosticsPayload {
+	test := func(t oracle.DiagnosticTest) DiagnosticTestPayload {
+		return DiagnosticTestPayload{Statistic: t.Statistic, DF: t.DF, PValue: t.PValue}
+	}
+	warnings := d.Warnings
+	if warnings == nil {
+		warnings = []string{}
+	}
+	return &DiagnosticsPayload{
+		Residuals:  d.Count,
+		Mean:       d.Mean,
+		StdDev:     d.StdDev,
+		Skewness:   d.Skewness,
+		Kurtosis:   d.Kurtosis,
+		ACF:        d.ACF,
+		PACF:       d.PACF,
+		Bound:      d.Bound,
+		LjungBox:   test(d.LjungBox),
+		JarqueBera: test(d.JarqueBera),
+		ARCH:       test(d.ARCH),
+		Warnings:   warnings,
+	}
+}
+
+// printDiagnosticsText marks autocorrelations outside the white-noise band
+// with an asterisk.
+func printDiagnosticsText(w io.Writer, d *DiagnosticsPayload) {
+	if d == nil {
+		return
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Residual diagnostics (%d in-sample residuals)\n", d.Residuals)
+	fmt.Fprintf(w, "Mean / Std Dev   : %.6f / %.6f\n", d.Mean, d.StdDev)
+	fmt.Fprintf(w, "Skewness         : %.4f\n", d.Skewness)
+	fmt.Fprintf(w, "Excess kurtosis  : %.4f\n", d.Kurtosis)
+	fmt.Fprintf(w, "lag      ACF     PACF   (band ±%.4f)\n", d.Bound)
+	mark := func(v float64) string {
+		if v > d.Bound || v < -d.Bound {
+			return "*"
+		}
+		return " "
+	}
+	for k := range d.ACF {
+		line := fmt.Sprintf("%3d  %7.4f%s %7.4f%s", k+1, d.ACF[k], mark(d.ACF[k]), d.PACF[k], mark(d.PACF[k]))
+		fmt.Fprintln(w, strings.TrimRight(line, " "))
+	}
+	fmt.Fprintf(w, "Ljung-Box        : Q = %.4f (df %d), p = %.4f\n", d.LjungBox.Statistic, d.LjungBox.DF, d.LjungBox.PValue)
+	fmt.Fprintf(w, "Jarque-Bera      : JB = %.4f (df %d), p = %.4f\n", d.JarqueBera.Statistic, d.JarqueBera.DF, d.JarqueBera.PValue)
+	fmt.Fprintf(w, "ARCH LM          : LM = %.4f (df %d), p = %.4f\n", d.ARCH.Statistic, d.ARCH.DF, d.ARCH.PValue)
+	if len(d.Warnings) == 0 {
+		fmt.Fprintln(w, "No warnings: the residuals look like white noise.")
+		return
+	}
+	fmt.Fprintln(w, "Warnings:")
+	for _, warning := range d.Warnings {
+		fmt.Fprintf(w, "- %s\n", warning)
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"oracle/internal/oracle"
+)
+
+var driftFlagNames = []string{"drift", "drift-window", "drift-psi", "drift-ks", "drift-residual"}
+
+// driftFlags compare the data given to a loaded model with the statistics
+// of the data it was trained on.
+type driftFlags struct {
+	mode string
+	cfg  oracle.DriftConfig
+}
+
+func (d *driftFlags) register(fs *flag.FlagSet) {
+	fs.StringVar(&d.mode, "drift", "warn", "with -load-model, check the data for drift from the training data: off, warn or fail")
+	fs.IntVar(&d.cfg.Window, "drift-window", 0, "number of last data points checked for drift (0 checks all)")
+	fs.Float64Var(&d.cfg.PSI, "drift-psi", 0.25, "population stability index above which the data has drifted")
+	fs.Float64Var(&d.cfg.KSPValue, "drift-ks", 0.01, "Kolmogorov-Smirnov p-value below which the data has drifted")
+	fs.Float64Var(&d.cfg.ResidualShift, "drift-residual", 0.5, "shift of the mean residual, in training residual std devs, above which the model is stale")
+}
+
+func (d *driftFlags) validate(set map[string]bool) error {
+	if !set["load-model"] {
+		for _, name := range driftFlagNames {
+			if set[name] {
+				return fmt.Errorf("-%s requires -load-model", name)
+			}
+		}
+	}
+	switch d.mode = strings.ToLower(strings.TrimSpace(d.mode)); {
+	case d.mode != "off" && d.mode != "warn" && d.mode != "fail":
+		return fmt.Errorf("invalid -drift: %q (use off, warn or fail)", d.mode)
+	case d.cfg.Window < 0:
+		return fmt.Errorf("invalid -drift-window: %d", d.cfg.Window)
+	case d.cfg.PSI <= 0:
+		return fmt.Errorf("invalid -drift-psi: %v (must be positive)", d.cfg.PSI)
+	case d.cfg.KSPValue <= 0 || d.cfg.KSPValue >= 1:
+		return fmt.Errorf("invalid -drift-ks: %v (must be between 0 and 1)", d.cfg.KSPValue)
+	case d.cfg.ResidualShift <= 0:
+		return fmt.Errorf("invalid -drift-residual: %v (must be positive)", d.cfg.ResidualShift)
+	}
+	return nil
+}
+
+type DriftPayload struct {
+	Points         int      `json:"points"`
+	Mean           float64  `json:"mean"`
+	StdDev         float64  `json:"std_dev"`
+	TrainingMean   float64  `json:"training_mean"`
+	TrainingStdDev float64  `json:"training_std_dev"`
+	PSI            float64  `json:"psi"`
+	KSStatistic    float64  `json:"ks_statistic"`
+	KSPValue       float64  `json:"ks_p_value"`
+	Residuals      int      `json:"residuals"`
+	ResidualMean   float64  `json:"residual_mean"`
+	ResidualShift  float64  `json:"residual_shift"`
+	ResidualScale  float64  `json:"residual_scale"`
+	Drift          bool     `json:"drift"`
+	Warnings       []string `json:"warnings"`
+}
+
+// check compares series with the training statistics of a loaded model.
+// Models saved before the statistics were recorded are skipped unless
+// -drift is fail. With fail, drift is returned as an error.
+func (d driftFlags) check(result *oracle.TrainResult, loadPath string, series []float64) (*DriftPayload, error) {
+	if d.mode == "off" || loadPath == "" {
+		return nil, nil
+	}
+	if result.Stats == nil {
+		if d.mode == "fail" {
+			return nil, fmt.Errorf("-drift fail: %s has no training statistics (retrain it to record them)", loadPath)
+		}
+		return nil, nil
+	}
+	report, err := oracle.CheckDrift(result, series, d.cfg)
+	if err != nil {
+		return nil, fmt.Errorf("drift check failed: %w", err)
+	}
+	if report.Drift && d.mode == "fail" {
+		return nil, fmt.Errorf("data drift detected: %s", strings.Join(report.Warnings, "; "))
+	}
+
+	warnings := report.Warnings
+	if warnings == nil {
+		warnings = []string{}
+	}
+	return &DriftPayload{
+		Points:         report.Points,
+		Mean:           report.Mean,
+		StdDev:         report.StdDev,
+		TrainingMean:   result.Stats.Mean,
+		TrainingStdDev: result.Stats.StdDev,
+		PSI:            report.PSI,
+		KSStatistic:    report.KSStatistic,
+		KSPValue:       report.KSPValue,
+		Residuals:      report.Residuals,
+		ResidualMean:   report.ResidualMean,
+		ResidualShift:  report.ResidualShift,
+		ResidualScale:  report.ResidualScale,
+		Drift:          report.Drift,
+		Warnings:       warnings,
+	}, nil
+}
+
+func printDriftText(w io.Writer, d *DriftPayload) {
+	if d == nil {
+		return
+	}
+	verdict := "no drift"
+	if d.Drift {
+		verdict = "DRIFT"
+	}
+	fmt.Fprintf(w, "Drift check      : %s in the last %d points (PSI %.4f, KS p %.4f, residual shift %+.2f)\n", verdict, d.Points, d.PSI, d.KSPValue, d.ResidualShift)
+	for _, warning := range d.Warnings {
+		fmt.Fprintf(w, "- %s\n", warning)
+	}
+}
@@ -0,0 +1,214 @@
+package oracle
+
+import (
+	"context"
+	"fmt"
+	"math"
+)
+
+// BacktestConfig describes a rolling-origin evaluation. The last fold ends at
+// the final observation; earlier folds move the origin back by Step points
+// (Horizon when unset). Season is the lag of the naive forecast that scales
+// MASE, RMSSE and SPL (1 when unset).
+type BacktestConfig struct {
+	Folds   int
+	Horizon int
+	Step    int
+	Season  int
+}
+
+type BacktestFold struct {
+	Fold      int
+	TrainEnd  int
+	Actual    []float64
+	Predicted []float64
+	Metrics   ValidationMetrics
+}
+
+type BacktestResult struct {
+	Folds   []BacktestFold
+	Overall ValidationMetrics
+}
+
+// Backtest retrains on series[:TrainEnd] for every fold and scores a
+// Horizon-step recursive forecast against the points that follow.
+func Backtest(series []float64, train TrainConfig, cfg BacktestConfig) (*BacktestResult, error) {
+	return BacktestContext(context.Background(), series, train, cfg)
+}
+
+// BacktestContext is Backtest with ctx passed to every fold's training and
+// forecast. train.Resume continues the fold its checkpoint was taken from;
+// the other folds train from scratch.
+func BacktestContext(ctx context.Context, series []float64, train TrainConfig, cfg BacktestConfig) (*BacktestResult, error) {
+	if cfg.Folds <= 0 {
+		return nil, fmt.Errorf("folds must be positive")
+	}
+	if cfg.Horizon <= 0 {
+		return nil, fmt.Errorf("horizon must be positive")
+	}
+	if cfg.Step <= 0 {
+		cfg.Step = cfg.Horizon
+	}
+
+	firstEnd := len(series) - cfg.Horizon - (cfg.Folds-1)*cfg.Step
+	if firstEnd < 6 || firstEnd <= train.Lag {
+		return nil, fmt.Errorf("series too short for %d folds of horizon %d: first fold would train on %d points", cfg.Folds, cfg.Horizon, firstEnd)
+	}
+
+	if train.Resume != nil {
+		matched := false
+		for k := 0; k < cfg.Folds && !matched; k++ {
+			matched = train.resumeFor(series[:firstEnd+k*cfg.Step]).Resume != nil
+		}
+		if !matched {
+			return nil, fmt.Errorf("cannot resume: the checkpoint matches none of the %d folds", cfg.Folds)
+		}
+	}
+
+	result := &BacktestResult{Folds: make([]BacktestFold, 0, cfg.Folds)}
+	var allActual, allPredicted []float64
+	for k := 0; k < cfg.Folds; k++ {
+		end := firstEnd + k*cfg.Step
+		model, err := TrainContext(ctx, series[:end], train.resumeFor(series[:end]))
+		if err != nil {
+			return nil, fmt.Errorf("fold %d: %w", k+1, err)
+		}
+		predicted, err := ForecastContext(ctx, model, series[:end], cfg.Horizon)
+		if err != nil {
+			return nil, fmt.Errorf("fold %d: %w", k+1, err)
+		}
+
+		actual := append([]float64(nil), series[end:end+cfg.Horizon]...)
+		result.Folds = append(result.Folds, BacktestFold{
+			Fold:      k + 1,
+			TrainEnd:  end,
+			Actual:    actual,
+			Predicted: predicted,
+			Metrics:   pointMetrics(actual, predicted, naiveScale(series[:end], cfg.Season), model.ResidualStdDev),
+		})
+		allActual = append(allActual, actual...)
+		allPredicted = append(allPredicted, predicted...)
+	}
+
+	// Every fold has its own scale, so the scaled errors are averaged over
+	// folds (all of the same horizon) instead of recomputed.
+	result.Overall = pointMetrics(allActual, allPredicted, errorScale{}, 0)
+	for _, fold := range result.Folds {
+		result.Overall.MASE += fold.Metrics.MASE / float64(len(result.Folds))
+		result.Overall.RMSSE += fold.Metrics.RMSSE / float64(len(result.Folds))
+		result.Overall.SPL += fold.Metrics.SPL / float64(len(result.Folds))
+	}
+	return result, nil
+}
+
+// pointMetrics computes the same error measures as Validate for paired
+// actual and predicted values. scale is the naive in-sample error for MASE,
+// RMSSE and SPL, and stdDev the residual standard deviation behind the SPL
+// quantiles.
+func pointMetrics(actual, predicted []float64, scale errorScale, stdDev float64) ValidationMetrics {
+	metrics := ValidationMetrics{Count: len(actual)}
+	if len(actual) == 0 {
+		return metrics
+	}
+
+	sumAbs := 0.0
+	sumSq := 0.0
+	sumPct := 0.0
+	pctCount := 0
+	sumSym := 0.0
+	sumZero := 0.0
+	sumPinball := 0.0
+	sumActual := 0.0
+	sumBias := 0.0
+	for i := range actual {
+		diff := actual[i] - predicted[i]
+		absDiff := math.Abs(diff)
+		sumAbs += absDiff
+		sumSq += diff * diff
+		sumActual += actual[i]
+		sumBias -= diff
+		if math.Abs(actual[i]) > 1e-9 {
+			sumPct += absDiff / math.Abs(actual[i])
+			pctCount++
+		} else {
+			sumZero += absDiff
+			metrics.ZeroCount++
+		}
+		// Both zero counts as a perfect forecast rather than 0/0.
+		if denom := math.Abs(actual[i]) + math.Abs(predicted[i]); denom > 0 {
+			sumSym += 2 * absDiff / denom
+		}
+		for _, q := range splQuantiles {
+			sumPinball += pinball(actual[i], predicted[i]+normalQuantile(q)*stdDev, q)
+		}
+	}
+
+	n := float64(len(actual))
+	metrics.MAE = sumAbs / n
+	metrics.RMSE = math.Sqrt(sumSq / n)
+	metrics.SMAPE = 100 * sumSym / n
+	metrics.Bias = sumBias / n
+	metrics.MAPEExcluded = metrics.ZeroCount
+	if pctCount > 0 {
+		metrics.MAPE = 100 * (sumPct / float64(pctCount))
+		metrics.NonzeroMAE = (sumAbs - sumZero) / float64(pctCount)
+	}
+	if metrics.ZeroCount > 0 {
+		metrics.ZeroMAE = sumZero / float64(metrics.ZeroCount)
+	}
+	mean := sumActual / n
+	sumTotal := 0.0
+	for _, v := range actual {
+		sumTotal += (v - mean) * (v - mean)
+	}
+	// R² is left at zero for constant actuals, where it is undefined.
+	if sumTotal > 0 {
+		metrics.R2 = 1 - sumSq/sumTotal
+	}
+	if scale.MAE > 0 {
+		metrics.MASE = metrics.MAE / scale.MAE
+		metrics.SPL = sumPinball / (n * float64(len(splQuantiles)) * scale.MAE)
+	}
+	if scale.MSE > 0 {
+		metrics.RMSSE = math.Sqrt(sumSq / n / scale.MSE)
+	}
+	return metrics
+}
+
+var splQuantiles = []float64{0.025, 0.5, 0.975}
+
+// pinball is the quantile loss of predicting value as the q-th quantile.
+func pinball(actual, value, q float64) float64 {
+	if actual >= value {
+		return q * (actual - value)
+	}
+	return (1 - q) * (value - actual)
+}
+
+// errorScale holds the in-sample errors of the naive forecast.
+type errorScale struct {
+	MAE float64
+	MSE float64
+}
+
+// naiveScale returns the mean absolute and squared change between points
+// `season` apart, the in-sample errors of the seasonal naive forecast (the
+// plain naive forecast for a season of 1 or less).
+func naiveScale(history []float64, season int) errorScale {
+	if season < 1 {
+		season = 1
+	}
+	if len(history) <= season {
+		return errorScale{}
+	}
+	var scale errorScale
+	for i := season; i < len(history); i++ {
+		diff := history[i] - history[i-season]
+		scale.MAE += math.Abs(diff)
+		scale.MSE += diff * diff
+	}
+	n := float64(len(history) - season)
+	scale.MAE /= n
+	scale.MSE /= n
+	return scale
+}
@@ -0,0 +1,72 @@
+package oracle
+
+import (
+	"math"
+	"testing"
+)
+
+func TestBacktestFolds(t *testing.T) {
+	series := make([]float64, 0, 60)
+	for i := 0; i < 60; i++ {
+		series = append(series, 4+0.5*float64(i))
+	}
+
+	result, err := Backtest(series, TrainConfig{Lag: 5, Hidden: 6, Epochs: 300, LearningRate: 0.008, Seed: 1}, BacktestConfig{Folds: 3, Horizon: 4})
+	if err != nil {
+		t.Fatalf("Backtest failed: %v", err)
+	}
+	if len(result.Folds) != 3 {
+		t.Fatalf("folds = %d, want 3", len(result.Folds))
+	}
+
+	wantEnds := []int{48, 52, 56}
+	for i, fold := range result.Folds {
+		if fold.TrainEnd != wantEnds[i] || len(fold.Predicted) != 4 || fold.Actual[0] != series[fold.TrainEnd] {
+			t.Fatalf("fold %d unexpected: end=%d predicted=%d", i+1, fold.TrainEnd, len(fold.Predicted))
+		}
+	}
+	if result.Overall.Count != 12 {
+		t.Fatalf("overall count = %d, want 12", result.Overall.Count)
+	}
+	if result.Overall.MAE > 5 {
+		t.Fatalf("overall MAE too large: %.4f", result.Overall.MAE)
+	}
+
+	if _, err := Backtest(series[:20], TrainConfig{Lag: 5}, BacktestConfig{Folds: 5, Horizon: 4}); err == nil {
+		t.Fatalf("expected error for too many folds")
+	}
+}
+
+func TestPointMetricsScaledAndZeroAware(t *testing.T) {
+	actual := []float64{0, 2, 0, 4}
+	predicted := []float64{1, 1, 1, 1}
+	m := pointMetrics(actual, predicted, errorScale{MAE: 2, MSE: 4}, 0)
+	if m.MAE != 1.5 || m.ZeroCount != 2 || m.ZeroMAE != 1 || m.NonzeroMAE != 2 {
+		t.Fatalf("unexpected metrics %+v", m)
+	}
+	if m.MASE != 0.75 || math.Abs(m.RMSSE-math.Sqrt(0.75)) > 1e-12 {
+		t.Fatalf("MASE, RMSSE = %v, %v; want 0.75, %v", m.MASE, m.RMSSE, math.Sqrt(0.75))
+	}
+	// sMAPE terms are 2, 2/3, 2 and 6/5; the squared errors sum to 12
+	// against 11 around the actual mean of 1.5.
+	if math.Abs(m.SMAPE-100*(2+2.0/3+2+1.2)/4) > 1e-9 || m.Bias != -0.5 || math.Abs(m.R2+1.0/11) > 1e-12 || m.MAPEExcluded != 2 {
+		t.Fatalf("unexpected sMAPE, bias, R2 or MAPE exclusions: %+v", m)
+	}
+	// With no spread every quantile equals the point forecast: the losses
+	// at q = 0.025, 0.5 and 0.975 sum to 2.05, 3 and 3.95 over the points.
+	if math.Abs(m.SPL-9.0/(4*3*2)) > 1e-12 {
+		t.Fatalf("SPL = %v, want %v", m.SPL, 9.0/(4*3*2))
+	}
+	if m := pointMetrics(actual, predicted, errorScale{}, 1); m.MASE != 0 || m.RMSSE != 0 || m.SPL != 0 {
+		t.Fatalf("scaled metrics should be zero without a scale: %+v", m)
+	}
+	if got := naiveScale([]float64{1, 3, 2}, 1); got != (errorScale{MAE: 1.5, MSE: 2.5}) {
+		t.Fatalf("naiveScale = %+v, want MAE 1.5 and MSE 2.5", got)
+	}
+	if got := naiveScale([]float64{1, 3, 2, 5}, 2); got != (errorScale{MAE: 1.5, MSE: 2.5}) {
+		t.Fatalf("seasonal naiveScale = %+v, want MAE 1.5 and MSE 2.5", got)
+	}
+	if got := naiveScale([]float64{1, 3}, 2); got != (errorScale{}) {
+		t.Fatalf("naiveScale without a full season = %+v, want zero", got)
+	}
+}
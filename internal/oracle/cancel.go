@@ -0,0 +1,67 @@
+package oracle
+
+import (
+	"context"
+	"fmt"
+)
+
+// CanceledError is returned by the Context variants when their context ends
+// before they finish. It unwraps to the context's error, so errors.Is works
+// with context.Canceled and context.DeadlineExceeded. For training, Epoch is
+// the number of finished epochs out of Epochs, and Partial holds the weights
+// at the end of the epoch whose running loss was lowest when
+// TrainConfig.KeepPartial is set and at least one epoch finished.
+type CanceledError struct {
+	Op      string
+	Epoch   int
+	Epochs  int
+	Partial *TrainResult
+	Err     error
+}
+
+func (e *CanceledError) Error() string {
+	if e.Epochs > 0 {
+		return fmt.Sprintf("%s canceled after %d of %d epochs: %v", e.Op, e.Epoch, e.Epochs, e.Err)
+	}
+	return fmt.Sprintf("%s canceled: %v", e.Op, e.Err)
+}
+
+func (e *CanceledError) Unwrap() error {
+	return e.Err
+}
+
+// checkContext returns a CanceledError for op once ctx is done.
+func checkContext(ctx context.Context, op string) error {
+	if err := ctx.Err(); err != nil {
+		return &CanceledError{Op: op, Err: err}
+	}
+	return nil
+}
+
+// bestEpoch keeps a copy of the weights at the end of the epoch whose
+// running loss, averaged over its steps while the weights were updated, was
+// lowest for TrainConfig.KeepPartial. The copy is not scored again, so its
+// loss on the final weights may differ.
+type bestEpoch struct {
+	model *MLP
+	epoch int
+	loss  float64
+}
+
+func (b *bestEpoch) observe(model *MLP, epoch int, loss float64) {
+	if b.model != nil && loss >= b.loss {
+		return
+	}
+	b.model, b.epoch, b.loss = model.clone(), epoch, loss
+}
+
+func (m *MLP) clone() *MLP {
+	c := *m
+	c.W1 = make([][]float64, len(m.W1))
+	for j, row := range m.W1 {
+		c.W1[j] = append([]float64(nil), row...)
+	}
+	c.B1 = append([]float64(nil), m.B1...)
+	c.W2 = append([]float64(nil), m.W2...)
+	return &c
+}
@@ -0,0 +1,74 @@
+package oracle
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestTrainContextCanceled(t *testing.T) {
+	series := make([]float64, 40)
+	for i := range series {
+		series[i] = math.Sin(float64(i) / 4)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cfg := TrainConfig{Lag: 4, Hidden: 6, Epochs: 500, Seed: 1, KeepPartial: true}
+	cfg.OnEpoch = func(s EpochStats) bool {
+		if s.Epoch == 10 {
+			cancel()
+		}
+		return false
+	}
+	_, err := TrainContext(ctx, series, cfg)
+	var canceled *CanceledError
+	if !errors.As(err, &canceled) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("TrainContext error = %v, want a *CanceledError wrapping context.Canceled", err)
+	}
+	if canceled.Epoch != 10 || canceled.Epochs != 500 || canceled.Partial == nil {
+		t.Fatalf("canceled = %+v, want 10 of 500 epochs and a partial model", canceled)
+	}
+	partial := canceled.Partial
+	if partial.Epochs < 1 || partial.Epochs > 10 || len(partial.LossHistory) != partial.Epochs {
+		t.Fatalf("partial model ran %d epochs with %d losses", partial.Epochs, len(partial.LossHistory))
+	}
+	for _, loss := range partial.LossHistory {
+		if loss < partial.LossHistory[partial.Epochs-1] {
+			t.Fatalf("partial model is not from the best epoch: %v", partial.LossHistory)
+		}
+	}
+	if _, err := Forecast(partial, series, 3); err != nil {
+		t.Fatalf("forecast with partial model failed: %v", err)
+	}
+
+	cfg.KeepPartial = false
+	cfg.OnEpoch = nil
+	_, err = TrainContext(ctx, series, cfg)
+	if !errors.As(err, &canceled) || canceled.Partial != nil {
+		t.Fatalf("training on a done context = %v, want a *CanceledError without a partial model", err)
+	}
+}
+
+func TestForecastAndValidateContext(t *testing.T) {
+	series := make([]float64, 30)
+	for i := range series {
+		series[i] = float64(i)
+	}
+	result, err := Train(series, TrainConfig{Lag: 3, Hidden: 4, Epochs: 20, Seed: 1})
+	if err != nil {
+		t.Fatalf("train failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	if _, err := ForecastContext(ctx, result, series, 3); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("ForecastContext error = %v, want deadline exceeded", err)
+	}
+	if _, err := ValidateContext(ctx, result, series, 5, 1); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("ValidateContext error = %v, want deadline exceeded", err)
+	}
+	if _, err := BacktestContext(ctx, series, TrainConfig{Lag: 3, Epochs: 20}, BacktestConfig{Folds: 2, Horizon: 3}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("BacktestContext error = %v, want deadline exceeded", err)
+	}
+}
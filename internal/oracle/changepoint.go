@@ -0,0 +1,181 @@
+package oracle
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	ChangepointPELT   = "pelt"
+	ChangepointBinSeg = "binseg"
+
+	ChangeCostMean    = "mean"
+	ChangeCostMeanVar = "meanvar"
+)
+
+// ChangepointConfig selects the search method and the segment cost.
+//
+// The mean cost assumes a normal likelihood with a common variance that is
+// estimated robustly from first differences; meanvar lets every segment have
+// its own mean and variance. A non-positive Penalty uses a BIC-style
+// (params+1)*log(n) penalty. MaxChangepoints only limits binary segmentation.
+type ChangepointConfig struct {
+	Method          string
+	Cost            string
+	Penalty         float64
+	MinSegment      int
+	MaxChangepoints int
+}
+
+// DetectChangepoints returns the sorted indices at which a new segment
+// starts, so series[cp:] is the data after the break.
+func DetectChangepoints(series []float64, cfg ChangepointConfig) ([]int, error) {
+	cfg.Method = strings.ToLower(strings.TrimSpace(cfg.Method))
+	cfg.Cost = strings.ToLower(strings.TrimSpace(cfg.Cost))
+	if cfg.Method == "" {
+		cfg.Method = ChangepointPELT
+	}
+	if cfg.Cost == "" {
+		cfg.Cost = ChangeCostMean
+	}
+	if cfg.MinSegment <= 0 {
+		cfg.MinSegment = 3
+	}
+
+	n := len(series)
+	if n < 2*cfg.MinSegment {
+		return nil, fmt.Errorf("series too short for changepoint detection: need at least %d points", 2*cfg.MinSegment)
+	}
+
+	cost, params, err := newSegmentCost(series, cfg.Cost)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Penalty <= 0 {
+		cfg.Penalty = float64(params+1) * math.Log(float64(n))
+	}
+
+	switch cfg.Method {
+	case ChangepointPELT:
+		return pelt(n, cost, cfg.Penalty, cfg.MinSegment), nil
+	case ChangepointBinSeg:
+		return binarySegmentation(n, cost, cfg.Penalty, cfg.MinSegment, cfg.MaxChangepoints), nil
+	default:
+		return nil, fmt.Errorf("unknown changepoint method: %q", cfg.Method)
+	}
+}
+
+// segmentCost returns the cost of series[start:end].
+type segmentCost func(start, end int) float64
+
+func newSegmentCost(series []float64, kind string) (segmentCost, int, error) {
+	s1 := make([]float64, len(series)+1)
+	s2 := make([]float64, len(series)+1)
+	for i, v := range series {
+		s1[i+1] = s1[i] + v
+		s2[i+1] = s2[i] + v*v
+	}
+	sse := func(start, end int) float64 {
+		m := float64(end - start)
+		sum := s1[end] - s1[start]
+		return math.Max(0, s2[end]-s2[start]-sum*sum/m)
+	}
+
+	switch kind {
+	case ChangeCostMean:
+		diffs := make([]float64, 0, len(series)-1)
+		for i := 1; i < len(series); i++ {
+			diffs = append(diffs, series[i]-series[i-1])
+		}
+		sigma := mad(diffs, median(diffs)) / math.Sqrt2
+		if sigma < 1e-9 {
+			sigma = stdDev(series)
+		}
+		if sigma < 1e-9 {
+			sigma = 1
+		}
+		variance := sigma * sigma
+		return func(start, end int) float64 {
+			return sse(start, end) / variance
+		}, 1, nil
+	case ChangeCostMeanVar:
+		floor := math.Max(1e-12, 1e-8*stdDev(series)*stdDev(series))
+		return func(start, end int) float64 {
+			m := float64(end - start)
+			return m * math.Log(math.Max(sse(start, end)/m, floor))
+		}, 2, nil
+	default:
+		return nil, 0, fmt.Errorf("unknown changepoint cost: %q", kind)
+	}
+}
+
+func pelt(n int, cost segmentCost, penalty float64, minSeg int) []int {
+	best := make([]float64, n+1)
+	last := make([]int, n+1)
+	for t := 1; t <= n; t++ {
+		best[t] = math.Inf(1)
+	}
+	best[0] = -penalty
+
+	candidates := []int{}
+	for t := minSeg; t <= n; t++ {
+		if s := t - minSeg; s == 0 || s >= minSeg {
+			candidates = append(candidates, s)
+		}
+
+		for _, s := range candidates {
+			if v := best[s] + cost(s, t) + penalty; v < best[t] {
+				best[t] = v
+				last[t] = s
+			}
+		}
+
+		kept := candidates[:0]
+		for _, s := range candidates {
+			if best[s]+cost(s, t) <= best[t] {
+				kept = append(kept, s)
+			}
+		}
+		candidates = kept
+	}
+
+	var cps []int
+	for t := last[n]; t > 0; t = last[t] {
+		cps = append(cps, t)
+	}
+	sort.Ints(cps)
+	return cps
+}
+
+func binarySegmentation(n int, cost segmentCost, penalty float64, minSeg, maxChangepoints int) []int {
+	type segment struct{ start, end int }
+	segments := []segment{{0, n}}
+	var cps []int
+
+	for maxChangepoints <= 0 || len(cps) < maxChangepoints {
+		bestGain := penalty
+		bestSeg := -1
+		bestSplit := 0
+		for i, seg := range segments {
+			whole := cost(seg.start, seg.end)
+			for k := seg.start + minSeg; k <= seg.end-minSeg; k++ {
+				if gain := whole - cost(seg.start, k) - cost(k, seg.end); gain > bestGain {
+					bestGain, bestSeg, bestSplit = gain, i, k
+				}
+			}
+		}
+		if bestSeg < 0 {
+			break
+		}
+
+		seg := segments[bestSeg]
+		segments[bestSeg] = segment{seg.start, bestSplit}
+		segments = append(segments, segment{bestSplit, seg.end})
+		cps = append(cps, bestSplit)
+	}
+
+	sort.Ints(cps)
+	return cps
+}
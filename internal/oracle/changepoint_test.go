@@ -0,0 +1,69 @@
+package oracle
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestDetectChangepointsFindsMeanShift(t *testing.T) {
+	rnd := rand.New(rand.NewSource(4))
+	series := make([]float64, 0, 120)
+	for i := 0; i < 120; i++ {
+		level := 10.0
+		if i >= 70 {
+			level = 25
+		}
+		series = append(series, level+rnd.NormFloat64())
+	}
+
+	for _, method := range []string{ChangepointPELT, ChangepointBinSeg} {
+		cps, err := DetectChangepoints(series, ChangepointConfig{Method: method})
+		if err != nil {
+			t.Fatalf("%s: DetectChangepoints failed: %v", method, err)
+		}
+		if len(cps) != 1 || cps[0] < 68 || cps[0] > 72 {
+			t.Fatalf("%s: changepoints = %v, want one near 70", method, cps)
+		}
+	}
+}
+
+func TestDetectChangepointsFindsVarianceShift(t *testing.T) {
+	rnd := rand.New(rand.NewSource(8))
+	series := make([]float64, 0, 200)
+	for i := 0; i < 200; i++ {
+		scale := 0.5
+		if i >= 100 {
+			scale = 5
+		}
+		series = append(series, 3+scale*rnd.NormFloat64())
+	}
+
+	cps, err := DetectChangepoints(series, ChangepointConfig{Cost: ChangeCostMeanVar, MinSegment: 10})
+	if err != nil {
+		t.Fatalf("DetectChangepoints failed: %v", err)
+	}
+	if len(cps) == 0 || math.Abs(float64(cps[len(cps)-1]-100)) > 8 {
+		t.Fatalf("changepoints = %v, want last near 100", cps)
+	}
+}
+
+func TestDetectChangepointsStableSeries(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	series := make([]float64, 0, 100)
+	for i := 0; i < 100; i++ {
+		series = append(series, 5+rnd.NormFloat64())
+	}
+
+	cps, err := DetectChangepoints(series, ChangepointConfig{Method: ChangepointBinSeg})
+	if err != nil {
+		t.Fatalf("DetectChangepoints failed: %v", err)
+	}
+	if len(cps) != 0 {
+		t.Fatalf("changepoints = %v, want none", cps)
+	}
+
+	if _, err := DetectChangepoints(series, ChangepointConfig{Method: "cusum"}); err == nil {
+		t.Fatalf("expected error for unknown method")
+	}
+}
@@ -0,0 +1,214 @@
+package oracle
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+)
+
+const (
+	checkpointFormatVersion = 1
+	defaultCheckpointEvery  = 1000
+)
+
+// Checkpoint is the state of network training after Epoch of Epochs epochs.
+// Result is the model as of that epoch, with the losses so far in
+// LossHistory. Draws counts the values taken from the generator seeded with
+// Seed and Order is the window order left by the last shuffle, which is all
+// plain SGD needs to continue exactly where it stopped. DataPoints and
+// DataChecksum identify the training series.
+type Checkpoint struct {
+	Epoch        int
+	Epochs       int
+	Result       *TrainResult
+	LearningRate float64
+	Seed         int64
+	Draws        uint64
+	Order        []int
+	DataPoints   int
+	DataChecksum string
+}
+
+// Matches reports why training series with cfg cannot continue from c, or
+// nil if it can. Epochs may differ as long as c has not run past it: the
+// first epochs of a longer run are the same.
+func (c *Checkpoint) Matches(series []float64, cfg TrainConfig) error {
+	cfg = cfg.withDefaults()
+	switch {
+	case normalizeModel(cfg.Model) != ModelMLP:
+		return fmt.Errorf("checkpoints only apply to the mlp, not %s", cfg.Model)
+	case c.DataPoints != len(series) || c.DataChecksum != seriesChecksum(series):
+		return fmt.Errorf("checkpoint was taken on different data (%d points)", c.DataPoints)
+	case c.Result.Lag != cfg.Lag || c.Result.Model.HiddenSize != cfg.Hidden:
+		return fmt.Errorf("checkpoint has lag %d and hidden size %d, not %d and %d", c.Result.Lag, c.Result.Model.HiddenSize, cfg.Lag, cfg.Hidden)
+	case c.LearningRate != cfg.LearningRate || c.Seed != cfg.Seed:
+		return fmt.Errorf("checkpoint has learning rate %g and seed %d, not %g and %d", c.LearningRate, c.Seed, cfg.LearningRate, cfg.Seed)
+	case c.Epoch > cfg.Epochs:
+		return fmt.Errorf("checkpoint is at epoch %d, past the %d epochs to train", c.Epoch, cfg.Epochs)
+	}
+	return nil
+}
+
+// resumeFor drops cfg.Resume unless it was taken on series, for callers
+// that train several times with one config.
+func (cfg TrainConfig) resumeFor(series []float64) TrainConfig {
+	if cfg.Resume != nil && cfg.Resume.Matches(series, cfg) != nil {
+		cfg.Resume = nil
+	}
+	return cfg
+}
+
+type persistedCheckpoint struct {
+	Version      int                `json:"checkpoint_version"`
+	Epoch        int                `json:"epoch"`
+	Epochs       int                `json:"epochs"`
+	Model        persistedModel     `json:"model"`
+	LossHistory  []float64          `json:"loss_history"`
+	Optimizer    persistedOptimizer `json:"optimizer"`
+	RNG          persistedRNG       `json:"rng"`
+	DataPoints   int                `json:"data_points"`
+	DataChecksum string             `json:"data_checksum"`
+}
+
+// persistedOptimizer describes plain SGD, whose only state is its rate.
+type persistedOptimizer struct {
+	Method       string  `json:"method"`
+	LearningRate float64 `json:"learning_rate"`
+}
+
+type persistedRNG struct {
+	Seed  int64  `json:"seed"`
+	Draws uint64 `json:"draws"`
+	Order []int  `json:"window_order"`
+}
+
+// SaveCheckpoint writes c atomically, so a crash while saving keeps the
+// previous checkpoint.
+func SaveCheckpoint(path string, c *Checkpoint) error {
+	pc := persistedCheckpoint{
+		Version:      checkpointFormatVersion,
+		Epoch:        c.Epoch,
+		Epochs:       c.Epochs,
+		Model:        persistResult(c.Result),
+		LossHistory:  c.Result.LossHistory,
+		Optimizer:    persistedOptimizer{Method: "sgd", LearningRate: c.LearningRate},
+		RNG:          persistedRNG{Seed: c.Seed, Draws: c.Draws, Order: c.Order},
+		DataPoints:   c.DataPoints,
+		DataChecksum: c.DataChecksum,
+	}
+	if pc.LossHistory == nil {
+		pc.LossHistory = []float64{}
+	}
+	if err := validatePersistedCheckpoint(pc); err != nil {
+		return err
+	}
+	body, err := json.MarshalIndent(pc, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, append(body, '\n'))
+}
+
+func LoadCheckpoint(path string) (*Checkpoint, error) {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var pc persistedCheckpoint
+	if err := json.Unmarshal(body, &pc); err != nil {
+		return nil, err
+	}
+	if err := validatePersistedCheckpoint(pc); err != nil {
+		return nil, err
+	}
+
+	result := pc.Model.result()
+	result.Epochs = pc.Epoch
+	result.LossHistory = pc.LossHistory
+	return &Checkpoint{
+		Epoch:        pc.Epoch,
+		Epochs:       pc.Epochs,
+		Result:       result,
+		LearningRate: pc.Optimizer.LearningRate,
+		Seed:         pc.RNG.Seed,
+		Draws:        pc.RNG.Draws,
+		Order:        pc.RNG.Order,
+		DataPoints:   pc.DataPoints,
+		DataChecksum: pc.DataChecksum,
+	}, nil
+}
+
+func validatePersistedCheckpoint(pc persistedCheckpoint) error {
+	if pc.Version != checkpointFormatVersion {
+		return fmt.Errorf("unsupported checkpoint version: %d", pc.Version)
+	}
+	if err := validatePersistedModel(pc.Model); err != nil {
+		return err
+	}
+	switch {
+	case pc.Model.Intermittent != nil:
+		return fmt.Errorf("checkpoint holds an intermittent model")
+	case pc.Optimizer.Method != "sgd" || pc.Optimizer.LearningRate <= 0:
+		return fmt.Errorf("invalid optimizer in checkpoint: %s with learning rate %g", pc.Optimizer.Method, pc.Optimizer.LearningRate)
+	case pc.Epoch < 0 || pc.Epoch > pc.Epochs:
+		return fmt.Errorf("invalid checkpoint epoch: %d of %d", pc.Epoch, pc.Epochs)
+	case len(pc.LossHistory) != pc.Epoch:
+		return fmt.Errorf("checkpoint has %d losses for %d epochs", len(pc.LossHistory), pc.Epoch)
+	case len(pc.RNG.Order) != pc.DataPoints-pc.Model.Lag:
+		return fmt.Errorf("checkpoint window order has %d entries for %d windows", len(pc.RNG.Order), pc.DataPoints-pc.Model.Lag)
+	}
+	seen := make([]bool, len(pc.RNG.Order))
+	for _, i := range pc.RNG.Order {
+		if i < 0 || i >= len(seen) || seen[i] {
+			return fmt.Errorf("checkpoint window order is not a permutation")
+		}
+		seen[i] = true
+	}
+	return nil
+}
+
+// seriesChecksum is the hex SHA-256 of the series' float bits.
+func seriesChecksum(series []float64) string {
+	h := sha256.New()
+	var buf [8]byte
+	for _, v := range series {
+		binary.LittleEndian.PutUint64(buf[:], math.Float64bits(v))
+		h.Write(buf[:])
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// countingSource counts the values drawn from a seeded math/rand source, so
+// its state can be restored by replaying that many draws from the seed.
+type countingSource struct {
+	src   rand.Source64
+	draws uint64
+}
+
+func newCountingSource(seed int64, draws uint64) *countingSource {
+	s := &countingSource{src: rand.NewSource(seed).(rand.Source64)}
+	for s.draws < draws {
+		s.Uint64()
+	}
+	return s
+}
+
+func (s *countingSource) Int63() int64 {
+	s.draws++
+	return s.src.Int63()
+}
+
+func (s *countingSource) Uint64() uint64 {
+	s.draws++
+	return s.src.Uint64()
+}
+
+func (s *countingSource) Seed(seed int64) {
+	s.src.Seed(seed)
+	s.draws = 0
+}
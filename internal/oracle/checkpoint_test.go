@@ -0,0 +1,86 @@
+package oracle
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestResumeFromCheckpointIsExact(t *testing.T) {
+	series := make([]float64, 50)
+	for i := range series {
+		series[i] = float64(i%7) + 0.3*float64(i)
+	}
+	cfg := TrainConfig{Lag: 5, Hidden: 6, Epochs: 300, LearningRate: 0.01, Seed: 4}
+	want, err := Train(series, cfg)
+	if err != nil {
+		t.Fatalf("train failed: %v", err)
+	}
+
+	// Stop after 120 epochs: the last periodic checkpoint is at 100, and
+	// canceling saves one more.
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	ctx, cancel := context.WithCancel(context.Background())
+	interrupted := cfg
+	interrupted.CheckpointPath = path
+	interrupted.CheckpointEvery = 50
+	interrupted.OnEpoch = func(s EpochStats) bool {
+		if s.Epoch == 120 {
+			cancel()
+		}
+		return false
+	}
+	if _, err := TrainContext(ctx, series, interrupted); !errors.Is(err, context.Canceled) {
+		t.Fatalf("interrupted training error = %v, want context.Canceled", err)
+	}
+
+	checkpoint, err := LoadCheckpoint(path)
+	if err != nil {
+		t.Fatalf("LoadCheckpoint failed: %v", err)
+	}
+	if checkpoint.Epoch != 120 || checkpoint.Epochs != 300 || len(checkpoint.Result.LossHistory) != 120 {
+		t.Fatalf("checkpoint at epoch %d of %d with %d losses, want 120 of 300", checkpoint.Epoch, checkpoint.Epochs, len(checkpoint.Result.LossHistory))
+	}
+
+	resumed := cfg
+	resumed.Resume = checkpoint
+	resumed.CheckpointPath = path
+	var epochs []int
+	resumed.OnEpoch = func(s EpochStats) bool {
+		epochs = append(epochs, s.Epoch)
+		return false
+	}
+	got, err := Train(series, resumed)
+	if err != nil {
+		t.Fatalf("resumed training failed: %v", err)
+	}
+	if len(epochs) != 180 || epochs[0] != 121 {
+		t.Fatalf("resumed run trained epochs %d..., want 180 epochs from 121", epochs[:1])
+	}
+	if !reflect.DeepEqual(got.Model, want.Model) || !reflect.DeepEqual(got.LossHistory, want.LossHistory) || got.MSE != want.MSE || got.Epochs != want.Epochs {
+		t.Fatalf("resumed training differs from an uninterrupted run")
+	}
+
+	// The final checkpoint resumes to the finished model without training.
+	final, err := LoadCheckpoint(path)
+	if err != nil {
+		t.Fatalf("LoadCheckpoint failed: %v", err)
+	}
+	done, err := Train(series, TrainConfig{Lag: 5, Hidden: 6, Epochs: 300, LearningRate: 0.01, Seed: 4, Resume: final})
+	if err != nil || !reflect.DeepEqual(done.Model, want.Model) {
+		t.Fatalf("resuming a finished checkpoint = %v, want the trained model", err)
+	}
+
+	other := append([]float64(nil), series...)
+	other[3]++
+	if _, err := Train(other, resumed); err == nil || !strings.Contains(err.Error(), "different data") {
+		t.Fatalf("resuming on other data error = %v, want a data mismatch", err)
+	}
+	resumed.Seed = 5
+	if _, err := Train(series, resumed); err == nil || !strings.Contains(err.Error(), "seed") {
+		t.Fatalf("resuming with another seed error = %v, want a seed mismatch", err)
+	}
+}
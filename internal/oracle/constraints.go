@@ -0,0 +1,172 @@
+package oracle
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Constraints restrict forecast values, e.g. to non-negative whole numbers
+// for sales counts. Lower and Upper bound every value, Integer rounds to
+// whole numbers (bounds are then rounded inwards) and Total makes a
+// forecast path sum to a fixed value. A nil *Constraints imposes nothing.
+type Constraints struct {
+	Lower   *float64 `json:"lower,omitempty"`
+	Upper   *float64 `json:"upper,omitempty"`
+	Integer bool     `json:"integer,omitempty"`
+	Total   *float64 `json:"total,omitempty"`
+}
+
+func (c *Constraints) Validate() error {
+	if c == nil {
+		return nil
+	}
+	lo, hi := c.bounds()
+	for _, v := range []*float64{c.Lower, c.Upper, c.Total} {
+		if v != nil && (math.IsNaN(*v) || math.IsInf(*v, 0)) {
+			return fmt.Errorf("constraints must be finite numbers")
+		}
+	}
+	if lo > hi {
+		return fmt.Errorf("lower bound %v is above upper bound %v", lo, hi)
+	}
+	if c.Total != nil && c.Integer && *c.Total != math.Round(*c.Total) {
+		return fmt.Errorf("total %v must be a whole number for integer forecasts", *c.Total)
+	}
+	return nil
+}
+
+// bounds returns the effective bounds, infinite when unset.
+func (c *Constraints) bounds() (float64, float64) {
+	lo, hi := math.Inf(-1), math.Inf(1)
+	if c.Lower != nil {
+		lo = *c.Lower
+	}
+	if c.Upper != nil {
+		hi = *c.Upper
+	}
+	if c.Integer {
+		lo, hi = math.Ceil(lo), math.Floor(hi)
+	}
+	return lo, hi
+}
+
+// Clamp applies the bounds and rounding to a single value.
+func (c *Constraints) Clamp(v float64) float64 {
+	if c == nil {
+		return v
+	}
+	lo, hi := c.bounds()
+	if c.Integer {
+		v = math.Round(v)
+	}
+	return math.Max(lo, math.Min(hi, v))
+}
+
+// Interval applies the bounds to a prediction interval and, for integer
+// forecasts, widens it outwards to whole numbers so coverage is kept.
+func (c *Constraints) Interval(low, high float64) (float64, float64) {
+	if c == nil {
+		return low, high
+	}
+	lo, hi := c.bounds()
+	if c.Integer {
+		low, high = math.Floor(low), math.Ceil(high)
+	}
+	return math.Max(lo, math.Min(hi, low)), math.Max(lo, math.Min(hi, high))
+}
+
+// Apply constrains a forecast path in place. With a Total, the path is
+// shifted by the single amount that makes the bounded values add up to it
+// (the closest such path in squared distance), and integer rounding gives
+// the leftover units to the values with the largest fractions.
+func (c *Constraints) Apply(path []float64) error {
+	if c == nil || len(path) == 0 {
+		return nil
+	}
+	if c.Total == nil {
+		for i, v := range path {
+			path[i] = c.Clamp(v)
+		}
+		return nil
+	}
+
+	lo, hi := c.bounds()
+	total := *c.Total
+	n := float64(len(path))
+	if total < n*lo || total > n*hi {
+		return fmt.Errorf("total %v cannot be met by %d values between %v and %v", total, len(path), lo, hi)
+	}
+	shifted := func(shift float64) float64 {
+		sum := 0.0
+		for _, v := range path {
+			sum += math.Max(lo, math.Min(hi, v+shift))
+		}
+		return sum
+	}
+	// The bounded sum grows monotonically with the shift; bisect on it.
+	sum := 0.0
+	for _, v := range path {
+		sum += v
+	}
+	span := math.Abs(total-sum) + 1
+	for _, v := range path {
+		span = math.Max(span, math.Abs(v)+1)
+	}
+	if !math.IsInf(lo, 0) {
+		span = math.Max(span, math.Abs(lo)*2+1)
+	}
+	if !math.IsInf(hi, 0) {
+		span = math.Max(span, math.Abs(hi)*2+1)
+	}
+	left, right := -2*span, 2*span
+	for i := 0; i < 200 && right-left > 1e-12*span; i++ {
+		mid := (left + right) / 2
+		if shifted(mid) < total {
+			left = mid
+		} else {
+			right = mid
+		}
+	}
+	shift := (left + right) / 2
+	for i, v := range path {
+		path[i] = math.Max(lo, math.Min(hi, v+shift))
+	}
+	if !c.Integer {
+		// Remove the bisection's rounding error from the free values.
+		diff := total
+		for _, v := range path {
+			diff -= v
+		}
+		for i, v := range path {
+			if v > lo && v < hi {
+				path[i] += diff
+				break
+			}
+		}
+		return nil
+	}
+
+	floors := 0.0
+	order := make([]int, len(path))
+	for i, v := range path {
+		order[i] = i
+		floors += math.Floor(v)
+	}
+	frac := func(i int) float64 { return path[i] - math.Floor(path[i]) }
+	sort.SliceStable(order, func(a, b int) bool { return frac(order[a]) > frac(order[b]) })
+	extra := int(math.Round(total - floors))
+	for i := range path {
+		path[i] = math.Floor(path[i])
+	}
+	for _, i := range order {
+		if extra == 0 {
+			break
+		}
+		if path[i]+1 <= hi {
+			path[i]++
+			extra--
+		}
+	}
+	return nil
+}
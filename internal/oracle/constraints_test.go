@@ -0,0 +1,96 @@
+package oracle
+
+import (
+	"math"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func float(v float64) *float64 { return &v }
+
+func TestConstraintsApply(t *testing.T) {
+	cases := []struct {
+		c    Constraints
+		in   []float64
+		want []float64
+	}{
+		{Constraints{Lower: float(0)}, []float64{-1.5, 2.25}, []float64{0, 2.25}},
+		{Constraints{Lower: float(0), Upper: float(10), Integer: true}, []float64{-0.4, 3.6, 12}, []float64{0, 4, 10}},
+		// The shift of -1 takes the total from 12 to 9.
+		{Constraints{Total: float(9)}, []float64{2, 4, 6}, []float64{1, 3, 5}},
+		// The first value sticks at the bound, so the others move by 2.5.
+		{Constraints{Lower: float(0), Total: float(6)}, []float64{1, 4, 7}, []float64{0, 1.5, 4.5}},
+		// The floors add up to 5; the two leftover units go to the largest
+		// fractions, with ties kept in path order.
+		{Constraints{Integer: true, Total: float(7)}, []float64{1.6, 2.6, 2.8}, []float64{2, 2, 3}},
+	}
+	for _, tc := range cases {
+		path := append([]float64(nil), tc.in...)
+		if err := tc.c.Apply(path); err != nil {
+			t.Fatalf("Apply(%v) failed: %v", tc.in, err)
+		}
+		for i := range path {
+			if math.Abs(path[i]-tc.want[i]) > 1e-6 {
+				t.Fatalf("Apply(%v) with %+v = %v, want %v", tc.in, tc.c, path, tc.want)
+			}
+		}
+	}
+
+	infeasible := Constraints{Upper: float(1), Total: float(5)}
+	if err := infeasible.Apply([]float64{0, 0}); err == nil {
+		t.Fatalf("expected error for an unreachable total")
+	}
+	for _, c := range []Constraints{
+		{Lower: float(3), Upper: float(2)},
+		{Integer: true, Total: float(2.5)},
+		{Lower: float(math.NaN())},
+	} {
+		if err := c.Validate(); err == nil {
+			t.Fatalf("expected validation error for %+v", c)
+		}
+	}
+
+	var none *Constraints
+	if low, high := none.Interval(-1, 1); low != -1 || high != 1 {
+		t.Fatalf("nil constraints changed the interval")
+	}
+	c := &Constraints{Lower: float(0), Integer: true}
+	if low, high := c.Interval(-0.5, 2.2); low != 0 || high != 3 {
+		t.Fatalf("Interval = %v, %v; want 0, 3", low, high)
+	}
+}
+
+func TestConstrainedModelRoundTrip(t *testing.T) {
+	series := []float64{5, 3, 1, 4, 2, 0, 3, 1, 0, 2, 1, 0}
+	c := &Constraints{Lower: float(0), Integer: true}
+	result, err := Train(series, TrainConfig{Lag: 3, Hidden: 4, Epochs: 200, Seed: 1, Constraints: c})
+	if err != nil {
+		t.Fatalf("Train failed: %v", err)
+	}
+	forecast, err := Forecast(result, series, 6)
+	if err != nil {
+		t.Fatalf("Forecast failed: %v", err)
+	}
+	for _, v := range forecast {
+		if v < 0 || v != math.Round(v) {
+			t.Fatalf("forecast violates constraints: %v", forecast)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "model.json")
+	if err := SaveModel(path, result); err != nil {
+		t.Fatalf("SaveModel failed: %v", err)
+	}
+	loaded, err := LoadModel(path)
+	if err != nil {
+		t.Fatalf("LoadModel failed: %v", err)
+	}
+	if !reflect.DeepEqual(loaded.Constraints, c) {
+		t.Fatalf("constraints not persisted: %+v", loaded.Constraints)
+	}
+	again, err := Forecast(loaded, series, 6)
+	if err != nil || !reflect.DeepEqual(again, forecast) {
+		t.Fatalf("loaded forecast = %v, %v; want %v", again, err, forecast)
+	}
+}
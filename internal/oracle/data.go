@@ -2,8 +2,11 @@ package oracle
 
 import (
 	"bufio"
+	"encoding/csv"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"unicode"
@@ -50,3 +53,191 @@ func splitFields(line string) []string {
 		return r == ',' || r == ';' || r == '\t' || unicode.IsSpace(r)
 	})
 }
+
+// NamedSeries is one series of a multi-series input. Err is set instead of
+// Values when only this series could not be read, so callers can report it
+// and carry on with the others.
+type NamedSeries struct {
+	ID     string
+	Values []float64
+	Err    error
+}
+
+// LoadSeriesDir loads every regular file in dir with LoadSeriesFromFile,
+// in name order. The series ID is the file name without its extension.
+func LoadSeriesDir(dir string) ([]NamedSeries, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var out []NamedSeries
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		name := entry.Name()
+		values, err := LoadSeriesFromFile(filepath.Join(dir, name))
+		out = append(out, NamedSeries{
+			ID:     strings.TrimSuffix(name, filepath.Ext(name)),
+			Values: values,
+			Err:    err,
+		})
+	}
+	if len(out) == 0 {
+		return nil, fmt.Errorf("no series files found in %s", dir)
+	}
+	return out, nil
+}
+
+// SeriesCSVConfig describes the layout of a multi-series CSV file with a
+// header row.
+//
+// With IDColumn set the file is long: each row holds one observation of the
+// series named in IDColumn, read from ValueColumn ("value" by default), and
+// rows are sorted by TimeColumn within each series when it is set. Without
+// IDColumn the file is wide: every column except TimeColumn is one series
+// named by its header, and empty cells are skipped.
+type SeriesCSVConfig struct {
+	IDColumn    string
+	ValueColumn string
+	TimeColumn  string
+}
+
+// LoadSeriesCSV reads the series of a wide or long CSV file in order of
+// first appearance.
+func LoadSeriesCSV(path string, cfg SeriesCSVConfig) ([]NamedSeries, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	r := csv.NewReader(file)
+	r.FieldsPerRecord = -1
+	r.TrimLeadingSpace = true
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", path, err)
+	}
+	if len(rows) < 2 {
+		return nil, fmt.Errorf("%s needs a header and at least one data row", path)
+	}
+
+	header := rows[0]
+	column := func(name string) (int, error) {
+		for i, h := range header {
+			if strings.TrimSpace(h) == name {
+				return i, nil
+			}
+		}
+		return -1, fmt.Errorf("column %q not found in %s", name, path)
+	}
+	timeCol := -1
+	if cfg.TimeColumn != "" {
+		if timeCol, err = column(cfg.TimeColumn); err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.IDColumn == "" {
+		return wideSeries(header, rows[1:], timeCol), nil
+	}
+
+	idCol, err := column(cfg.IDColumn)
+	if err != nil {
+		return nil, err
+	}
+	valueName := cfg.ValueColumn
+	if valueName == "" {
+		valueName = "value"
+	}
+	valueCol, err := column(valueName)
+	if err != nil {
+		return nil, err
+	}
+	return longSeries(rows[1:], idCol, valueCol, timeCol), nil
+}
+
+func wideSeries(header []string, rows [][]string, timeCol int) []NamedSeries {
+	var out []NamedSeries
+	for col, name := range header {
+		if col == timeCol {
+			continue
+		}
+		s := NamedSeries{ID: strings.TrimSpace(name)}
+		for i, row := range rows {
+			if col >= len(row) || strings.TrimSpace(row[col]) == "" {
+				continue
+			}
+			v, err := strconv.ParseFloat(strings.TrimSpace(row[col]), 64)
+			if err != nil {
+				s.Err = fmt.Errorf("row %d: invalid value %q", i+2, row[col])
+				break
+			}
+			s.Values = append(s.Values, v)
+		}
+		if s.Err == nil && len(s.Values) == 0 {
+			s.Err = fmt.Errorf("no numeric values")
+		}
+		if s.Err != nil {
+			s.Values = nil
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+func longSeries(rows [][]string, idCol, valueCol, timeCol int) []NamedSeries {
+	type observation struct {
+		time  string
+		value float64
+	}
+	var (
+		order []string
+		obs   = map[string][]observation{}
+		errs  = map[string]error{}
+	)
+	for i, row := range rows {
+		if idCol >= len(row) {
+			continue
+		}
+		id := strings.TrimSpace(row[idCol])
+		if _, seen := obs[id]; !seen {
+			order = append(order, id)
+			obs[id] = nil
+		}
+		if errs[id] != nil || valueCol >= len(row) || strings.TrimSpace(row[valueCol]) == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(row[valueCol]), 64)
+		if err != nil {
+			errs[id] = fmt.Errorf("row %d: invalid value %q", i+2, row[valueCol])
+			continue
+		}
+		o := observation{value: v}
+		if timeCol >= 0 && timeCol < len(row) {
+			o.time = strings.TrimSpace(row[timeCol])
+		}
+		obs[id] = append(obs[id], o)
+	}
+
+	out := make([]NamedSeries, 0, len(order))
+	for _, id := range order {
+		s := NamedSeries{ID: id, Err: errs[id]}
+		if s.Err == nil {
+			points := obs[id]
+			if timeCol >= 0 {
+				sort.SliceStable(points, func(i, j int) bool { return points[i].time < points[j].time })
+			}
+			for _, p := range points {
+				s.Values = append(s.Values, p.value)
+			}
+			if len(s.Values) == 0 {
+				s.Err = fmt.Errorf("no numeric values")
+			}
+		}
+		out = append(out, s)
+	}
+	return out
+}
@@ -0,0 +1,77 @@
+package oracle
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, dir, name, body string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	return path
+}
+
+func TestLoadSeriesCSVWide(t *testing.T) {
+	path := writeTestFile(t, t.TempDir(), "wide.csv", "date,a,b,c\n2025-01-01,1,10,x\n2025-01-02,2,,3\n2025-01-03,3,30,4\n")
+
+	series, err := LoadSeriesCSV(path, SeriesCSVConfig{TimeColumn: "date"})
+	if err != nil {
+		t.Fatalf("LoadSeriesCSV failed: %v", err)
+	}
+	if len(series) != 3 {
+		t.Fatalf("len(series) = %d, want 3", len(series))
+	}
+	if series[0].ID != "a" || !reflect.DeepEqual(series[0].Values, []float64{1, 2, 3}) {
+		t.Fatalf("unexpected series a: %+v", series[0])
+	}
+	if !reflect.DeepEqual(series[1].Values, []float64{10, 30}) {
+		t.Fatalf("empty cells should be skipped: %+v", series[1])
+	}
+	if series[2].Err == nil || series[2].Values != nil {
+		t.Fatalf("series c should carry its parse error: %+v", series[2])
+	}
+}
+
+func TestLoadSeriesCSVLong(t *testing.T) {
+	path := writeTestFile(t, t.TempDir(), "long.csv", "sku,date,qty\nq,2025-01-02,5\np,2025-01-02,2\np,2025-01-01,1\nq,2025-01-01,4\n")
+
+	series, err := LoadSeriesCSV(path, SeriesCSVConfig{IDColumn: "sku", ValueColumn: "qty", TimeColumn: "date"})
+	if err != nil {
+		t.Fatalf("LoadSeriesCSV failed: %v", err)
+	}
+	want := []NamedSeries{
+		{ID: "q", Values: []float64{4, 5}},
+		{ID: "p", Values: []float64{1, 2}},
+	}
+	if !reflect.DeepEqual(series, want) {
+		t.Fatalf("series = %+v, want %+v", series, want)
+	}
+
+	if _, err := LoadSeriesCSV(path, SeriesCSVConfig{IDColumn: "sku"}); err == nil {
+		t.Fatalf("expected an error for the missing default value column")
+	}
+}
+
+func TestLoadSeriesDir(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFile(t, dir, "b.txt", "3\n4\n")
+	writeTestFile(t, dir, "a.csv", "1\n2\n")
+	writeTestFile(t, dir, "empty.txt", "value\n")
+	writeTestFile(t, dir, ".hidden", "9\n")
+
+	series, err := LoadSeriesDir(dir)
+	if err != nil {
+		t.Fatalf("LoadSeriesDir failed: %v", err)
+	}
+	if len(series) != 3 || series[0].ID != "a" || series[1].ID != "b" || series[2].ID != "empty" {
+		t.Fatalf("unexpected series: %+v", series)
+	}
+	if series[2].Err == nil {
+		t.Fatalf("expected a per-series error for the empty file")
+	}
+}
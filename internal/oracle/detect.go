@@ -0,0 +1,106 @@
+package oracle
+
+import (
+	"fmt"
+	"math"
+)
+
+// DetectConfig controls when a one-step-ahead residual counts as an anomaly.
+// ZThreshold takes precedence; otherwise Interval is the two-sided coverage
+// of a normal prediction interval (0.99 when unset).
+type DetectConfig struct {
+	ZThreshold float64
+	Interval   float64
+}
+
+type AnomalyPoint struct {
+	Index     int
+	Actual    float64
+	Predicted float64
+	Residual  float64
+	Score     float64
+	Low       float64
+	High      float64
+	Anomaly   bool
+}
+
+// Detector scores observations one at a time by the residual of a
+// StreamPredictor. Observed values are always appended to the history, as
+// in Validate, and values that are not finite are rejected.
+type Detector struct {
+	predictor *StreamPredictor
+	scale     float64
+	threshold float64
+}
+
+func NewDetector(result *TrainResult, history []float64, cfg DetectConfig) (*Detector, error) {
+	threshold := cfg.ZThreshold
+	if threshold <= 0 {
+		interval := cfg.Interval
+		if interval <= 0 {
+			interval = 0.99
+		}
+		if interval >= 1 {
+			return nil, fmt.Errorf("interval must be below 1: %v", interval)
+		}
+		threshold = normalQuantile(0.5 + interval/2)
+	}
+
+	predictor, err := NewStreamPredictor(result, history, StreamConfig{})
+	if err != nil {
+		return nil, err
+	}
+	return &Detector{
+		predictor: predictor,
+		scale:     math.Max(result.ResidualStdDev, 1e-9),
+		threshold: threshold,
+	}, nil
+}
+
+// Threshold returns the absolute z-score above which points are flagged.
+func (d *Detector) Threshold() float64 {
+	return d.threshold
+}
+
+func (d *Detector) Observe(actual float64) (AnomalyPoint, error) {
+	step, err := d.predictor.Observe(actual)
+	if err != nil {
+		return AnomalyPoint{}, err
+	}
+	point := AnomalyPoint{
+		Index:     step.Index,
+		Actual:    step.Actual,
+		Predicted: step.Predicted,
+		Residual:  step.Residual,
+		Score:     step.Residual / d.scale,
+		Low:       step.Predicted - d.threshold*d.scale,
+		High:      step.Predicted + d.threshold*d.scale,
+	}
+	point.Anomaly = math.Abs(point.Score) > d.threshold
+	return point, nil
+}
+
+// Detect scores every point of series that has a full lag window behind it.
+func Detect(result *TrainResult, series []float64, cfg DetectConfig) ([]AnomalyPoint, error) {
+	if !result.valid() {
+		return nil, fmt.Errorf("invalid train result")
+	}
+	if len(series) <= result.Lag {
+		return nil, fmt.Errorf("series length must be larger than lag")
+	}
+
+	detector, err := NewDetector(result, series[:result.Lag], cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]AnomalyPoint, 0, len(series)-result.Lag)
+	for _, v := range series[result.Lag:] {
+		p, err := detector.Observe(v)
+		if err != nil {
+			return nil, err
+		}
+		points = append(points, p)
+	}
+	return points, nil
+}
@@ -0,0 +1,75 @@
+package oracle
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDetectFlagsSpike(t *testing.T) {
+	series := make([]float64, 0, 80)
+	for i := 0; i < 80; i++ {
+		series = append(series, 10+0.4*float64(i)+0.3*math.Sin(float64(i)))
+	}
+
+	result, err := Train(series, TrainConfig{Lag: 6, Hidden: 10, Epochs: 800, LearningRate: 0.006, Seed: 5})
+	if err != nil {
+		t.Fatalf("train failed: %v", err)
+	}
+
+	series[60] += 25
+	points, err := Detect(result, series, DetectConfig{ZThreshold: 6})
+	if err != nil {
+		t.Fatalf("Detect failed: %v", err)
+	}
+	if len(points) != len(series)-result.Lag {
+		t.Fatalf("len(points) = %d, want %d", len(points), len(series)-result.Lag)
+	}
+
+	first := points[60-result.Lag]
+	if first.Index != 60 || !first.Anomaly {
+		t.Fatalf("spike not flagged: %+v", first)
+	}
+	for _, p := range points[:60-result.Lag] {
+		if p.Anomaly {
+			t.Fatalf("unexpected anomaly before spike: %+v", p)
+		}
+	}
+}
+
+func TestDetectorStreamsFromHistory(t *testing.T) {
+	series := make([]float64, 0, 40)
+	for i := 0; i < 40; i++ {
+		series = append(series, 3+0.5*float64(i))
+	}
+
+	result, err := Train(series, TrainConfig{Lag: 5, Hidden: 8, Epochs: 600, LearningRate: 0.008, Seed: 2})
+	if err != nil {
+		t.Fatalf("train failed: %v", err)
+	}
+
+	batch, err := Detect(result, series, DetectConfig{})
+	if err != nil {
+		t.Fatalf("Detect failed: %v", err)
+	}
+
+	detector, err := NewDetector(result, series[:30], DetectConfig{})
+	if err != nil {
+		t.Fatalf("NewDetector failed: %v", err)
+	}
+	if math.Abs(detector.Threshold()-2.5758) > 1e-3 {
+		t.Fatalf("default threshold = %.5f, want 99%% normal quantile", detector.Threshold())
+	}
+	for i := 30; i < len(series); i++ {
+		p, err := detector.Observe(series[i])
+		if err != nil {
+			t.Fatalf("Observe failed: %v", err)
+		}
+		want := batch[i-result.Lag]
+		if p.Index != want.Index || math.Abs(p.Score-want.Score) > 1e-12 {
+			t.Fatalf("streamed point %+v differs from batch %+v", p, want)
+		}
+	}
+	if _, err := detector.Observe(math.NaN()); err == nil {
+		t.Fatalf("expected an error for NaN")
+	}
+}
@@ -0,0 +1,191 @@
+package oracle
+
+import (
+	"fmt"
+	"math"
+)
+
+// DiagnosticTest is a test statistic with its chi-square degrees of freedom
+// and p-value; a small p-value rejects the white-noise assumption.
+type DiagnosticTest struct {
+	Statistic float64
+	DF        int
+	PValue    float64
+}
+
+// ResidualDiagnostics checks whether one-step residuals look like white
+// noise. ACF and PACF hold lags 1..len; Bound is the ±1.96/√n band inside
+// which white-noise autocorrelations fall 95% of the time. Kurtosis is the
+// excess kurtosis (0 for a normal distribution).
+type ResidualDiagnostics struct {
+	Count      int
+	Mean       float64
+	StdDev     float64
+	Skewness   float64
+	Kurtosis   float64
+	ACF        []float64
+	PACF       []float64
+	Bound      float64
+	LjungBox   DiagnosticTest
+	JarqueBera DiagnosticTest
+	ARCH       DiagnosticTest
+	Warnings   []string
+}
+
+// diagnosticsAlpha is the significance level behind the warnings.
+const diagnosticsAlpha = 0.05
+
+// DiagnoseResiduals runs the autocorrelation (ACF, PACF, Ljung-Box),
+// normality (Jarque-Bera) and heteroscedasticity (Engle's ARCH LM on up to
+// 5 lags of squared residuals) checks. lags <= 0 uses min(10, n/5).
+func DiagnoseResiduals(residuals []float64, lags int) (*ResidualDiagnostics, error) {
+	n := len(residuals)
+	if n < 8 {
+		return nil, fmt.Errorf("need at least 8 residuals for diagnostics, got %d", n)
+	}
+	if lags <= 0 {
+		lags = max(1, min(10, n/5))
+	}
+	if lags >= n/2 {
+		return nil, fmt.Errorf("diagnostic lags %d must be below half the %d residuals", lags, n)
+	}
+
+	d := &ResidualDiagnostics{Count: n, Mean: mean(residuals), StdDev: stdDev(residuals)}
+	if d.StdDev == 0 {
+		return nil, fmt.Errorf("residuals are constant")
+	}
+	var m3, m4 float64
+	for _, v := range residuals {
+		z := (v - d.Mean) / d.StdDev
+		m3 += z * z * z
+		m4 += z * z * z * z
+	}
+	d.Skewness = m3 / float64(n)
+	d.Kurtosis = m4/float64(n) - 3
+	jb := float64(n) / 6 * (d.Skewness*d.Skewness + d.Kurtosis*d.Kurtosis/4)
+	d.JarqueBera = DiagnosticTest{Statistic: jb, DF: 2, PValue: chiSquareSurvival(jb, 2)}
+
+	d.ACF = autocorrelations(residuals, lags)
+	d.PACF = partialAutocorrelations(d.ACF)
+	d.Bound = 1.96 / math.Sqrt(float64(n))
+	q := 0.0
+	for k, r := range d.ACF {
+		q += r * r / float64(n-k-1)
+	}
+	q *= float64(n) * float64(n+2)
+	d.LjungBox = DiagnosticTest{Statistic: q, DF: lags, PValue: chiSquareSurvival(q, lags)}
+
+	d.ARCH = archTest(residuals, min(5, lags))
+	d.Warnings = d.warnings()
+	return d, nil
+}
+
+func autocorrelations(values []float64, lags int) []float64 {
+	m := mean(values)
+	denom := 0.0
+	for _, v := range values {
+		denom += (v - m) * (v - m)
+	}
+	acf := make([]float64, lags)
+	for k := 1; k <= lags; k++ {
+		sum := 0.0
+		for t := k; t < len(values); t++ {
+			sum += (values[t] - m) * (values[t-k] - m)
+		}
+		acf[k-1] = sum / denom
+	}
+	return acf
+}
+
+// partialAutocorrelations runs the Durbin-Levinson recursion on acf.
+func partialAutocorrelations(acf []float64) []float64 {
+	pacf := make([]float64, len(acf))
+	phi := []float64{}
+	for k := range acf {
+		num, den := acf[k], 1.0
+		for j, p := range phi {
+			num -= p * acf[k-j-1]
+			den -= p * acf[j]
+		}
+		kk := 0.0
+		if den != 0 {
+			kk = num / den
+		}
+		next := make([]float64, k+1)
+		for j, p := range phi {
+			next[j] = p - kk*phi[k-j-1]
+		}
+		next[k] = kk
+		phi = next
+		pacf[k] = kk
+	}
+	return pacf
+}
+
+// archTest regresses the squared residuals on their q previous values; the
+// LM statistic is the number of regression rows times R².
+func archTest(residuals []float64, q int) DiagnosticTest {
+	test := DiagnosticTest{DF: q, PValue: 1}
+	rows := len(residuals) - q
+	x := make([][]float64, rows)
+	y := make([][]float64, rows)
+	for t := q; t < len(residuals); t++ {
+		row := []float64{1}
+		for j := 1; j <= q; j++ {
+			row = append(row, residuals[t-j]*residuals[t-j])
+		}
+		x[t-q] = row
+		y[t-q] = []float64{residuals[t] * residuals[t]}
+	}
+	xt := transpose(x)
+	beta, err := solve(matMul(xt, x), matMul(xt, y))
+	if err != nil {
+		return test
+	}
+
+	fitted := matMul(x, beta)
+	ym := 0.0
+	for _, row := range y {
+		ym += row[0] / float64(rows)
+	}
+	ssr, sst := 0.0, 0.0
+	for i, row := range y {
+		ssr += (row[0] - fitted[i][0]) * (row[0] - fitted[i][0])
+		sst += (row[0] - ym) * (row[0] - ym)
+	}
+	if sst == 0 {
+		return test
+	}
+	test.Statistic = float64(rows) * math.Max(0, 1-ssr/sst)
+	test.PValue = chiSquareSurvival(test.Statistic, q)
+	return test
+}
+
+// warnings explains in plain words what the failed checks mean for the
+// forecasts.
+func (d *ResidualDiagnostics) warnings() []string {
+	var warnings []string
+	if t := math.Abs(d.Mean) / (d.StdDev / math.Sqrt(float64(d.Count))); t > 1.96 {
+		direction := "under"
+		if d.Mean < 0 {
+			direction = "over"
+		}
+		warnings = append(warnings, fmt.Sprintf("residuals average %.4g instead of 0: the model systematically %s-forecasts", d.Mean, direction))
+	}
+	if d.LjungBox.PValue < diagnosticsAlpha {
+		worst := 0
+		for k, r := range d.ACF {
+			if math.Abs(r) > math.Abs(d.ACF[worst]) {
+				worst = k
+			}
+		}
+		warnings = append(warnings, fmt.Sprintf("residuals are autocorrelated (Ljung-Box p = %.3g, strongest at lag %d: %.3f): the model leaves predictable structure unexplained; try a larger -lag or check for seasonality", d.LjungBox.PValue, worst+1, d.ACF[worst]))
+	}
+	if d.JarqueBera.PValue < diagnosticsAlpha {
+		warnings = append(warnings, fmt.Sprintf("residuals are not normally distributed (Jarque-Bera p = %.3g, skewness %.2f, excess kurtosis %.2f): the normal 95%% intervals may be too narrow or lopsided", d.JarqueBera.PValue, d.Skewness, d.Kurtosis))
+	}
+	if d.ARCH.PValue < diagnosticsAlpha {
+		warnings = append(warnings, fmt.Sprintf("residual variance changes over time (ARCH LM p = %.3g): intervals are too narrow in volatile periods and too wide in calm ones", d.ARCH.PValue))
+	}
+	return warnings
+}
@@ -0,0 +1,88 @@
+package oracle
+
+import (
+	"math"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestChiSquareSurvival(t *testing.T) {
+	cases := []struct {
+		x    float64
+		df   int
+		want float64
+	}{
+		{2, 2, math.Exp(-1)},
+		{3.841459, 1, 0.05},
+		{18.307038, 10, 0.05},
+		{0.5, 4, 0.973500},
+		{0, 3, 1},
+	}
+	for _, tc := range cases {
+		if got := chiSquareSurvival(tc.x, tc.df); math.Abs(got-tc.want) > 1e-6 {
+			t.Fatalf("chiSquareSurvival(%v, %d) = %v, want %v", tc.x, tc.df, got, tc.want)
+		}
+	}
+}
+
+func TestPartialAutocorrelations(t *testing.T) {
+	// An AR(1) process with coefficient 0.5 has no partial correlation
+	// beyond the first lag.
+	pacf := partialAutocorrelations([]float64{0.5, 0.25, 0.125})
+	for i, want := range []float64{0.5, 0, 0} {
+		if math.Abs(pacf[i]-want) > 1e-12 {
+			t.Fatalf("pacf = %v, want [0.5 0 0]", pacf)
+		}
+	}
+}
+
+func TestDiagnoseResiduals(t *testing.T) {
+	rng := rand.New(rand.NewSource(3))
+	noise := make([]float64, 200)
+	for i := range noise {
+		noise[i] = rng.NormFloat64()
+	}
+	d, err := DiagnoseResiduals(noise, 0)
+	if err != nil {
+		t.Fatalf("DiagnoseResiduals failed: %v", err)
+	}
+	if len(d.ACF) != 10 || len(d.PACF) != 10 || d.ARCH.DF != 5 || d.JarqueBera.DF != 2 {
+		t.Fatalf("unexpected shape: %+v", d)
+	}
+	if len(d.Warnings) != 0 {
+		t.Fatalf("white noise should pass every check, got %q", d.Warnings)
+	}
+
+	// Autocorrelated, shifted and with variance bursts.
+	structured := make([]float64, 200)
+	prev := 0.0
+	for i := range structured {
+		scale := 1.0
+		if (i/25)%2 == 1 {
+			scale = 4
+		}
+		prev = 0.8*prev + scale*noise[i]
+		structured[i] = prev + 2
+	}
+	d, err = DiagnoseResiduals(structured, 8)
+	if err != nil {
+		t.Fatalf("DiagnoseResiduals failed: %v", err)
+	}
+	text := strings.Join(d.Warnings, "\n")
+	for _, want := range []string{"under-forecasts", "Ljung-Box", "ARCH"} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("warnings %q should mention %q", d.Warnings, want)
+		}
+	}
+	if d.ACF[0] < 0.5 || math.Abs(d.PACF[0]-d.ACF[0]) > 1e-12 {
+		t.Fatalf("unexpected ACF %v / PACF %v", d.ACF, d.PACF)
+	}
+
+	if _, err := DiagnoseResiduals(noise[:7], 0); err == nil {
+		t.Fatalf("expected error for too few residuals")
+	}
+	if _, err := DiagnoseResiduals(noise[:20], 10); err == nil {
+		t.Fatalf("expected error for too many lags")
+	}
+}
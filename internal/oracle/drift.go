@@ -0,0 +1,279 @@
+package oracle
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// driftQuantiles is the number of steps between the 0% and 100% quantiles
+// kept in TrainingStats.
+const driftQuantiles = 20
+
+// TrainingStats summarizes the series a model was trained on and its
+// in-sample one-step-ahead residuals, so later data can be compared with it
+// by CheckDrift. Quantiles are the 0%, 5%, ..., 100% quantiles of the
+// series. BinEdges are its distinct deciles: a value falls in the first bin
+// whose edge it does not exceed, or the last one above all edges, and
+// BinShares holds the share of the training values in each bin.
+type TrainingStats struct {
+	Points            int       `json:"points"`
+	Mean              float64   `json:"mean"`
+	StdDev            float64   `json:"std_dev"`
+	Min               float64   `json:"min"`
+	Max               float64   `json:"max"`
+	Quantiles         []float64 `json:"quantiles"`
+	BinEdges          []float64 `json:"bin_edges"`
+	BinShares         []float64 `json:"bin_shares"`
+	Residuals         int       `json:"residuals"`
+	ResidualMean      float64   `json:"residual_mean"`
+	ResidualStdDev    float64   `json:"residual_std_dev"`
+	ResidualQuantiles []float64 `json:"residual_quantiles"`
+}
+
+func newTrainingStats(series, residuals []float64) *TrainingStats {
+	sorted := sortedCopy(series)
+	stats := &TrainingStats{
+		Points:            len(series),
+		Mean:              mean(series),
+		StdDev:            stdDev(series),
+		Min:               sorted[0],
+		Max:               sorted[len(sorted)-1],
+		Quantiles:         quantiles(sorted),
+		Residuals:         len(residuals),
+		ResidualMean:      mean(residuals),
+		ResidualStdDev:    stdDev(residuals),
+		ResidualQuantiles: quantiles(sortedCopy(residuals)),
+	}
+	for i := 1; i < 10; i++ {
+		edge := quantile(sorted, float64(i)/10)
+		if n := len(stats.BinEdges); n == 0 || edge > stats.BinEdges[n-1] {
+			stats.BinEdges = append(stats.BinEdges, edge)
+		}
+	}
+	stats.BinShares = binShares(stats.BinEdges, series)
+	return stats
+}
+
+// recordStats sets r.Stats from the series r was trained on.
+func (r *TrainResult) recordStats(series []float64) error {
+	var residuals []float64
+	if len(series) > r.Lag {
+		var err error
+		if residuals, err = InSampleResiduals(r, series); err != nil {
+			return err
+		}
+	}
+	r.Stats = newTrainingStats(series, residuals)
+	return nil
+}
+
+func quantiles(sorted []float64) []float64 {
+	out := make([]float64, driftQuantiles+1)
+	for i := range out {
+		out[i] = quantile(sorted, float64(i)/driftQuantiles)
+	}
+	return out
+}
+
+func binShares(edges, values []float64) []float64 {
+	shares := make([]float64, len(edges)+1)
+	for _, v := range values {
+		shares[sort.SearchFloat64s(edges, v)]++
+	}
+	for i := range shares {
+		shares[i] /= float64(len(values))
+	}
+	return shares
+}
+
+func (s *TrainingStats) validate() error {
+	if s == nil {
+		return nil
+	}
+	switch {
+	case s.Points <= 0 || s.Residuals < 0:
+		return fmt.Errorf("%d points and %d residuals", s.Points, s.Residuals)
+	case s.StdDev < 0 || s.ResidualStdDev < 0 || s.Min > s.Max:
+		return fmt.Errorf("negative spread or min above max")
+	case len(s.Quantiles) != driftQuantiles+1 || len(s.ResidualQuantiles) != driftQuantiles+1:
+		return fmt.Errorf("need %d quantiles", driftQuantiles+1)
+	case !sort.Float64sAreSorted(s.Quantiles) || !sort.Float64sAreSorted(s.ResidualQuantiles):
+		return fmt.Errorf("quantiles are not sorted")
+	case len(s.BinShares) != len(s.BinEdges)+1:
+		return fmt.Errorf("%d bin shares for %d edges", len(s.BinShares), len(s.BinEdges))
+	}
+	for i := 1; i < len(s.BinEdges); i++ {
+		if s.BinEdges[i] <= s.BinEdges[i-1] {
+			return fmt.Errorf("bin edges are not increasing")
+		}
+	}
+	sum := 0.0
+	for _, share := range s.BinShares {
+		if share < 0 {
+			return fmt.Errorf("negative bin share")
+		}
+		sum += share
+	}
+	if math.Abs(sum-1) > 1e-6 {
+		return fmt.Errorf("bin shares add up to %v", sum)
+	}
+	return nil
+}
+
+// cdf approximates the training distribution function by interpolating
+// linearly between the stored quantiles.
+func (s *TrainingStats) cdf(x float64) float64 {
+	q := s.Quantiles
+	if x < q[0] {
+		return 0
+	}
+	if x >= q[driftQuantiles] {
+		return 1
+	}
+	j := sort.Search(len(q), func(i int) bool { return q[i] > x }) - 1
+	return (float64(j) + (x-q[j])/(q[j+1]-q[j])) / driftQuantiles
+}
+
+// DriftConfig sets which values CheckDrift compares and when it reports
+// drift. Window is the number of last values compared (all of the series
+// when 0); earlier values only serve as lag history for the residuals. PSI
+// (0.25 when unset) is the population stability index above which the
+// values count as shifted, KSPValue (0.01 when unset) the Kolmogorov-Smirnov
+// p-value below which they no longer follow the training distribution, and
+// ResidualShift (0.5 when unset) the shift of the mean residual, in training
+// residual standard deviations, above which the model is systematically off.
+type DriftConfig struct {
+	Window        int
+	PSI           float64
+	KSPValue      float64
+	ResidualShift float64
+}
+
+// DriftReport compares recent data with a model's TrainingStats. Residual
+// figures cover the compared values that have a full lag window behind
+// them; ResidualScale is their standard deviation over the training one.
+// Warnings explains each exceeded threshold.
+type DriftReport struct {
+	Points        int
+	Mean          float64
+	StdDev        float64
+	PSI           float64
+	KSStatistic   float64
+	KSPValue      float64
+	Residuals     int
+	ResidualMean  float64
+	ResidualShift float64
+	ResidualScale float64
+	Drift         bool
+	Warnings      []string
+}
+
+// CheckDrift compares the last values of series with the statistics of
+// the data result was trained on.
+func CheckDrift(result *TrainResult, series []float64, cfg DriftConfig) (*DriftReport, error) {
+	if !result.valid() {
+		return nil, fmt.Errorf("invalid train result")
+	}
+	stats := result.Stats
+	if stats == nil {
+		return nil, fmt.Errorf("model has no training statistics")
+	}
+	if len(series) == 0 {
+		return nil, fmt.Errorf("empty series")
+	}
+	if cfg.Window < 0 {
+		return nil, fmt.Errorf("window must not be negative, got %d", cfg.Window)
+	}
+	if cfg.PSI <= 0 {
+		cfg.PSI = 0.25
+	}
+	if cfg.KSPValue <= 0 {
+		cfg.KSPValue = 0.01
+	}
+	if cfg.KSPValue >= 1 {
+		return nil, fmt.Errorf("KS p-value threshold must be below 1: %v", cfg.KSPValue)
+	}
+	if cfg.ResidualShift <= 0 {
+		cfg.ResidualShift = 0.5
+	}
+
+	start := 0
+	if cfg.Window > 0 && cfg.Window < len(series) {
+		start = len(series) - cfg.Window
+	}
+	values := series[start:]
+	report := &DriftReport{
+		Points: len(values),
+		Mean:   mean(values),
+		StdDev: stdDev(values),
+	}
+
+	// Each bin gets half a value more, so the empty bins of a short window
+	// do not dominate the index; empty training bins are floored.
+	shares := binShares(stats.BinEdges, values)
+	n := float64(len(values))
+	for i, share := range shares {
+		actual := (share*n + 0.5) / (n + 0.5*float64(len(shares)))
+		expected := math.Max(stats.BinShares[i], 1e-4)
+		report.PSI += (actual - expected) * math.Log(actual/expected)
+	}
+	if report.PSI > cfg.PSI {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("PSI %.4f is above %.4f: the distribution of the values has shifted", report.PSI, cfg.PSI))
+	}
+
+	sorted := sortedCopy(values)
+	for i, v := range sorted {
+		f := stats.cdf(v)
+		report.KSStatistic = math.Max(report.KSStatistic, math.Max(float64(i+1)/n-f, f-float64(i)/n))
+	}
+	report.KSPValue = ksPValue(report.KSStatistic, n*float64(stats.Points)/(n+float64(stats.Points)))
+	if report.KSPValue < cfg.KSPValue {
+		report.Warnings = append(report.Warnings, fmt.Sprintf("KS test p = %.4g is below %.4g (D = %.4f): the values no longer follow the training distribution", report.KSPValue, cfg.KSPValue, report.KSStatistic))
+	}
+
+	if first := max(start, result.Lag); first < len(series) {
+		s := result.newStepper(series[first-result.Lag : first])
+		residuals := make([]float64, 0, len(series)-first)
+		for _, v := range series[first:] {
+			next, err := s.next()
+			if err != nil {
+				return nil, err
+			}
+			residuals = append(residuals, v-next)
+			s.observe(v)
+		}
+		scale := math.Max(stats.ResidualStdDev, 1e-9)
+		report.Residuals = len(residuals)
+		report.ResidualMean = mean(residuals)
+		report.ResidualShift = (report.ResidualMean - stats.ResidualMean) / scale
+		report.ResidualScale = stdDev(residuals) / scale
+		if math.Abs(report.ResidualShift) > cfg.ResidualShift {
+			report.Warnings = append(report.Warnings, fmt.Sprintf("mean residual moved by %+.2f training residual std devs (limit %.2f): the model is systematically off", report.ResidualShift, cfg.ResidualShift))
+		}
+	}
+
+	report.Drift = len(report.Warnings) > 0
+	return report, nil
+}
+
+// ksPValue returns the asymptotic p-value of a Kolmogorov-Smirnov statistic
+// d with effective sample size n, with the small-sample correction of
+// Stephens (1970).
+func ksPValue(d, n float64) float64 {
+	sq := math.Sqrt(n)
+	lambda := (sq + 0.12 + 0.11/sq) * d
+	if lambda < 0.2 {
+		return 1
+	}
+	sum, sign := 0.0, 1.0
+	for k := 1; k <= 100; k++ {
+		term := sign * math.Exp(-2*float64(k*k)*lambda*lambda)
+		sum += term
+		if math.Abs(term) < 1e-12 {
+			break
+		}
+		sign = -sign
+	}
+	return math.Max(0, math.Min(1, 2*sum))
+}
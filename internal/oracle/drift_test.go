@@ -0,0 +1,76 @@
+package oracle
+
+import (
+	"math"
+	"math/rand"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCheckDrift(t *testing.T) {
+	rnd := rand.New(rand.NewSource(4))
+	noisy := func(n int, level float64) []float64 {
+		out := make([]float64, n)
+		for i := range out {
+			out[i] = level + 2*math.Sin(float64(i)/3) + rnd.NormFloat64()*0.3
+		}
+		return out
+	}
+	training := noisy(200, 10)
+	result, err := Train(training, TrainConfig{Lag: 6, Hidden: 6, Epochs: 200, Seed: 1})
+	if err != nil {
+		t.Fatalf("train failed: %v", err)
+	}
+	if s := result.Stats; s == nil || s.Points != 200 || s.Residuals != 194 || len(s.BinShares) != 10 {
+		t.Fatalf("training stats = %+v", result.Stats)
+	}
+
+	path := filepath.Join(t.TempDir(), "model.json")
+	if err := SaveModel(path, result); err != nil {
+		t.Fatalf("SaveModel failed: %v", err)
+	}
+	loaded, err := LoadModel(path)
+	if err != nil {
+		t.Fatalf("LoadModel failed: %v", err)
+	}
+	if !reflect.DeepEqual(loaded.Stats, result.Stats) {
+		t.Fatalf("training stats did not round-trip")
+	}
+
+	// More of the same data passes; the last 60 points on a higher level
+	// fail every check.
+	same, err := CheckDrift(loaded, append(training, noisy(60, 10)...), DriftConfig{Window: 60})
+	if err != nil {
+		t.Fatalf("CheckDrift failed: %v", err)
+	}
+	if same.Drift || same.Points != 60 || same.Residuals != 60 {
+		t.Fatalf("unchanged data reported drift: %+v", same)
+	}
+	shifted, err := CheckDrift(loaded, append(training, noisy(60, 14)...), DriftConfig{Window: 60})
+	if err != nil {
+		t.Fatalf("CheckDrift failed: %v", err)
+	}
+	if !shifted.Drift || len(shifted.Warnings) != 3 || shifted.PSI <= 0.25 || shifted.KSPValue >= 0.01 || shifted.ResidualShift <= 0.5 {
+		t.Fatalf("shifted data report = %+v", shifted)
+	}
+
+	legacy := *loaded
+	legacy.Stats = nil
+	if _, err := CheckDrift(&legacy, training, DriftConfig{}); err == nil {
+		t.Fatalf("expected an error for a model without training stats")
+	}
+}
+
+func TestKSPValue(t *testing.T) {
+	// Critical values of the asymptotic Kolmogorov distribution.
+	for _, tc := range []struct{ lambda, p float64 }{{1.224, 0.10}, {1.358, 0.05}, {1.628, 0.01}} {
+		n := 1e6
+		if got := ksPValue(tc.lambda/math.Sqrt(n), n); math.Abs(got-tc.p) > 1e-3 {
+			t.Fatalf("ksPValue at lambda %.3f = %.4f, want %.2f", tc.lambda, got, tc.p)
+		}
+	}
+	if ksPValue(0, 100) != 1 {
+		t.Fatalf("a zero statistic should have p-value 1")
+	}
+}
@@ -1,51 +1,164 @@
 package oracle
 
 import (
+	"context"
 	"fmt"
 	"math"
 	"math/rand"
+	"strings"
+	"time"
 )
 
 type TrainConfig struct {
+	// Model is one of ModelTypes; empty means mlp. The intermittent-demand
+	// models ignore every other setting.
+	Model        string
 	Lag          int
 	Hidden       int
 	Epochs       int
 	LearningRate float64
 	Seed         int64
+	// Constraints are stored in the result and applied to its forecasts.
+	Constraints *Constraints
+	// OnEpoch, when set, is called after every epoch of network training.
+	// Returning true stops training early; the result keeps the weights of
+	// that epoch and Epochs counts the epochs run.
+	OnEpoch func(EpochStats) bool
+	// KeepPartial makes canceled training return the weights at the end of
+	// the epoch with the lowest running loss so far in CanceledError.Partial,
+	// at the cost of copying them whenever that loss improves.
+	KeepPartial bool
+	// CheckpointPath, when set, makes network training save a Checkpoint
+	// there every CheckpointEvery epochs (1000 when zero), after the last
+	// epoch and when it is canceled.
+	CheckpointPath  string
+	CheckpointEvery int
+	// Resume continues network training from a checkpoint of the same
+	// series and settings; the result is identical to an uninterrupted run.
+	Resume *Checkpoint
 }
 
+// EpochStats describes one finished training epoch. Loss is the mean squared
+// error over the training windows on the standardized scale and Elapsed the
+// time since training started.
+type EpochStats struct {
+	Epoch        int
+	Epochs       int
+	Loss         float64
+	LearningRate float64
+	Elapsed      time.Duration
+}
+
+// epochDone records the loss of epoch (0-based) and reports whether the
+// OnEpoch hook asked to stop.
+func (cfg TrainConfig) epochDone(history *[]float64, epoch int, loss float64, start time.Time) bool {
+	*history = append(*history, loss)
+	if cfg.OnEpoch == nil {
+		return false
+	}
+	return cfg.OnEpoch(EpochStats{Epoch: epoch + 1, Epochs: cfg.Epochs, Loss: loss, LearningRate: cfg.LearningRate, Elapsed: time.Since(start)})
+}
+
+func (cfg TrainConfig) withDefaults() TrainConfig {
+	if cfg.Lag <= 0 {
+		cfg.Lag = 6
+	}
+	if cfg.Hidden <= 0 {
+		cfg.Hidden = 12
+	}
+	if cfg.Epochs <= 0 {
+		cfg.Epochs = 1800
+	}
+	if cfg.LearningRate <= 0 {
+		cfg.LearningRate = 0.008
+	}
+	return cfg
+}
+
+// TrainResult holds either an MLP (Model) or an intermittent-demand smoother
+// (Intermittent), whose Lag is 1.
 type TrainResult struct {
 	Model          *MLP
+	Intermittent   *IntermittentModel
+	Constraints    *Constraints
 	Scaler         Standardizer
 	Lag            int
+	Epochs         int
 	MSE            float64
 	ResidualStdDev float64
+
+	// Stats summarizes the training data for CheckDrift. It is saved with
+	// the model; files saved before it was recorded leave it nil.
+	Stats *TrainingStats
+
+	// LossHistory is the mean squared error over the training windows in
+	// each epoch, on the standardized scale, as seen while training. It is
+	// not saved with the model and empty for intermittent models.
+	LossHistory []float64
 }
 
+// ValidationMetrics scores point forecasts. MAPE skips the MAPEExcluded
+// zero actual values; sMAPE counts a zero forecast of a zero actual as exact.
+// MASE, RMSSE and SPL (the scaled pinball loss of the 2.5%, 50% and 97.5%
+// quantiles of the normal forecast distribution) are divided by the MAE or
+// MSE of the (seasonal) naive forecast over the training history, so they
+// stay meaningful for mostly-zero series; they are zero when that history is
+// constant. Bias is the mean of predicted minus actual, positive when
+// over-forecasting. ZeroMAE is the error over periods whose actual value was
+// zero and NonzeroMAE over the rest. Predicted holds the one-step holdout
+// predictions for Validate and is nil elsewhere.
 type ValidationMetrics struct {
-	Count int
-	MAE   float64
-	RMSE  float64
-	MAPE  float64
+	Count        int
+	MAE          float64
+	RMSE         float64
+	MAPE         float64
+	MAPEExcluded int
+	SMAPE        float64
+	MASE         float64
+	RMSSE        float64
+	SPL          float64
+	R2           float64
+	Bias         float64
+	ZeroCount    int
+	ZeroMAE      float64
+	NonzeroMAE   float64
+	Predicted    []float64
 }
 
 func Train(series []float64, cfg TrainConfig) (*TrainResult, error) {
+	return TrainContext(context.Background(), series, cfg)
+}
+
+// TrainContext is Train that checks ctx before every epoch and stops with a
+// *CanceledError once it is done.
+func TrainContext(ctx context.Context, series []float64, cfg TrainConfig) (*TrainResult, error) {
+	if err := checkContext(ctx, "training"); err != nil {
+		return nil, err
+	}
 	if len(series) < 6 {
 		return nil, fmt.Errorf("series too short: need at least 6 points")
 	}
 
-	if cfg.Lag <= 0 {
-		cfg.Lag = 6
-	}
-	if cfg.Hidden <= 0 {
-		cfg.Hidden = 12
-	}
-	if cfg.Epochs <= 0 {
-		cfg.Epochs = 1800
+	if err := cfg.Constraints.Validate(); err != nil {
+		return nil, err
 	}
-	if cfg.LearningRate <= 0 {
-		cfg.LearningRate = 0.008
+	switch model := normalizeModel(cfg.Model); model {
+	case ModelMLP:
+	case ModelCroston, ModelSBA, ModelTSB:
+		result, err := trainIntermittent(series, model)
+		if err != nil {
+			return nil, err
+		}
+		result.Constraints = cfg.Constraints
+		if err := result.recordStats(series); err != nil {
+			return nil, err
+		}
+		return result, nil
+	default:
+		return nil, fmt.Errorf("unknown model %q (use %s)", cfg.Model, strings.Join(ModelTypes, ", "))
 	}
+
+	cfg = cfg.withDefaults()
 	if len(series) <= cfg.Lag {
 		return nil, fmt.Errorf("series length must be larger than lag")
 	}
@@ -58,65 +171,139 @@ func Train(series []float64, cfg TrainConfig) (*TrainResult, error) {
 		return nil, fmt.Errorf("failed to build training windows")
 	}
 
-	rnd := rand.New(rand.NewSource(cfg.Seed))
-	model := NewMLP(cfg.Lag, cfg.Hidden, rnd)
-	order := make([]int, len(x))
-	for i := range order {
-		order[i] = i
+	var (
+		src     *countingSource
+		model   *MLP
+		order   []int
+		history = make([]float64, 0, cfg.Epochs)
+		first   int
+	)
+	if c := cfg.Resume; c != nil {
+		if err := c.Matches(series, cfg); err != nil {
+			return nil, fmt.Errorf("cannot resume: %w", err)
+		}
+		src = newCountingSource(cfg.Seed, c.Draws)
+		model = c.Result.Model.clone()
+		order = append([]int(nil), c.Order...)
+		history = append(history, c.Result.LossHistory...)
+		first = c.Epoch
+	} else {
+		src = newCountingSource(cfg.Seed, 0)
+		model = NewMLP(cfg.Lag, cfg.Hidden, rand.New(src))
+		order = make([]int, len(x))
+		for i := range order {
+			order[i] = i
+		}
 	}
+	rnd := rand.New(src)
 
-	for epoch := 0; epoch < cfg.Epochs; epoch++ {
+	every := cfg.CheckpointEvery
+	if every <= 0 {
+		every = defaultCheckpointEvery
+	}
+	saved := first
+	checkpoint := func() error {
+		if cfg.CheckpointPath == "" || len(history) == saved {
+			return nil
+		}
+		result, err := finishTraining(model, scaler, series, x, cfg, history)
+		if err != nil {
+			return err
+		}
+		err = SaveCheckpoint(cfg.CheckpointPath, &Checkpoint{
+			Epoch:        len(history),
+			Epochs:       cfg.Epochs,
+			Result:       result,
+			LearningRate: cfg.LearningRate,
+			Seed:         cfg.Seed,
+			Draws:        src.draws,
+			Order:        order,
+			DataPoints:   len(series),
+			DataChecksum: seriesChecksum(series),
+		})
+		if err != nil {
+			return fmt.Errorf("saving checkpoint: %w", err)
+		}
+		saved = len(history)
+		return nil
+	}
+
+	start := time.Now()
+	var best bestEpoch
+	for epoch := first; epoch < cfg.Epochs; epoch++ {
+		if err := ctx.Err(); err != nil {
+			if err := checkpoint(); err != nil {
+				return nil, err
+			}
+			canceled := &CanceledError{Op: "training", Epoch: epoch, Epochs: cfg.Epochs, Err: err}
+			if best.model != nil {
+				canceled.Partial, err = finishTraining(best.model, scaler, series, x, cfg, history[:best.epoch+1])
+				if err != nil {
+					return nil, err
+				}
+			}
+			return nil, canceled
+		}
 		rnd.Shuffle(len(order), func(i, j int) {
 			order[i], order[j] = order[j], order[i]
 		})
 
+		sum := 0.0
 		for _, idx := range order {
-			in := x[idx]
-			target := y[idx]
-
-			h, out, err := model.Forward(in)
+			loss, err := model.step(x[idx], y[idx], cfg.LearningRate, nil)
 			if err != nil {
 				return nil, err
 			}
-
-			diff := out - target
-			dOut := 2.0 * diff
-
-			dZ1 := make([]float64, cfg.Hidden)
-			for j := 0; j < cfg.Hidden; j++ {
-				dZ1[j] = dOut * model.W2[j] * (1 - h[j]*h[j])
-			}
-
-			for j := 0; j < cfg.Hidden; j++ {
-				model.W2[j] -= cfg.LearningRate * dOut * h[j]
-			}
-			model.B2 -= cfg.LearningRate * dOut
-
-			for j := 0; j < cfg.Hidden; j++ {
-				for i := 0; i < cfg.Lag; i++ {
-					model.W1[j][i] -= cfg.LearningRate * dZ1[j] * in[i]
-				}
-				model.B1[j] -= cfg.LearningRate * dZ1[j]
+			sum += loss
+		}
+		loss := sum / float64(len(order))
+		if cfg.KeepPartial {
+			best.observe(model, epoch, loss)
+		}
+		stop := cfg.epochDone(&history, epoch, loss, start)
+		if (epoch+1)%every == 0 || epoch+1 == cfg.Epochs {
+			if err := checkpoint(); err != nil {
+				return nil, err
 			}
 		}
+		if stop {
+			break
+		}
 	}
+	return finishTraining(model, scaler, series, x, cfg, history)
+}
 
+// finishTraining measures the in-sample fit of model and wraps it in a
+// result that ran len(history) epochs.
+func finishTraining(model *MLP, scaler Standardizer, series []float64, x [][]float64, cfg TrainConfig, history []float64) (*TrainResult, error) {
 	mse, stdDev, err := evaluate(model, scaler, series, x, cfg.Lag)
 	if err != nil {
 		return nil, err
 	}
 
-	return &TrainResult{
+	result := &TrainResult{
 		Model:          model,
+		Constraints:    cfg.Constraints,
 		Scaler:         scaler,
 		Lag:            cfg.Lag,
+		Epochs:         len(history),
 		MSE:            mse,
 		ResidualStdDev: stdDev,
-	}, nil
+		LossHistory:    history,
+	}
+	if err := result.recordStats(series); err != nil {
+		return nil, err
+	}
+	return result, nil
 }
 
 func Forecast(result *TrainResult, observed []float64, steps int) ([]float64, error) {
-	if result == nil || result.Model == nil {
+	return ForecastContext(context.Background(), result, observed, steps)
+}
+
+// ForecastContext is Forecast that checks ctx before every step.
+func ForecastContext(ctx context.Context, result *TrainResult, observed []float64, steps int) ([]float64, error) {
+	if !result.valid() {
 		return nil, fmt.Errorf("invalid train result")
 	}
 	if len(observed) < result.Lag {
@@ -126,24 +313,27 @@ func Forecast(result *TrainResult, observed []float64, steps int) ([]float64, er
 		return []float64{}, nil
 	}
 
-	history := append([]float64(nil), observed...)
+	s := result.newStepper(observed)
 	predictions := make([]float64, 0, steps)
-
 	for i := 0; i < steps; i++ {
-		start := len(history) - result.Lag
-		window := history[start:]
-		normalizedWindow := result.Scaler.TransformSlice(window)
-
-		nextNorm, err := result.Model.Predict(normalizedWindow)
+		if err := checkContext(ctx, "forecast"); err != nil {
+			return nil, err
+		}
+		next, err := s.next()
 		if err != nil {
 			return nil, err
 		}
-
-		next := result.Scaler.Inverse(nextNorm)
 		predictions = append(predictions, next)
-		history = append(history, next)
+		// Intermittent forecasts are flat; feeding them back would count
+		// them as demand.
+		if result.Intermittent == nil {
+			s.observe(next)
+		}
 	}
 
+	if err := result.Constraints.Apply(predictions); err != nil {
+		return nil, err
+	}
 	return predictions, nil
 }
 
@@ -151,8 +341,20 @@ func Forecast(result *TrainResult, observed []float64, steps int) ([]float64, er
 // The model is asked to predict each next point from the current history,
 // then history is advanced with the actual observed value.
 func Validate(result *TrainResult, series []float64, holdout int) (ValidationMetrics, error) {
+	return ValidateSeasonal(result, series, holdout, 1)
+}
+
+// ValidateSeasonal is Validate with the scaled errors measured against the
+// seasonal naive forecast, which repeats the value `season` points back.
+func ValidateSeasonal(result *TrainResult, series []float64, holdout, season int) (ValidationMetrics, error) {
+	return ValidateContext(context.Background(), result, series, holdout, season)
+}
+
+// ValidateContext is ValidateSeasonal that checks ctx before every holdout
+// point.
+func ValidateContext(ctx context.Context, result *TrainResult, series []float64, holdout, season int) (ValidationMetrics, error) {
 	metrics := ValidationMetrics{}
-	if result == nil || result.Model == nil {
+	if !result.valid() {
 		return metrics, fmt.Errorf("invalid train result")
 	}
 	if holdout <= 0 {
@@ -167,45 +369,129 @@ func Validate(result *TrainResult, series []float64, holdout int) (ValidationMet
 		return metrics, fmt.Errorf("training segment shorter than lag")
 	}
 
-	history := append([]float64(nil), series[:trainEnd]...)
-	sumAbs := 0.0
-	sumSq := 0.0
-	sumPct := 0.0
-	pctCount := 0
+	s := result.newStepper(series[:trainEnd])
+	predicted := make([]float64, 0, holdout)
 
 	for i := trainEnd; i < len(series); i++ {
-		start := len(history) - result.Lag
-		window := history[start:]
-		normalizedWindow := result.Scaler.TransformSlice(window)
-
-		nextNorm, err := result.Model.Predict(normalizedWindow)
+		if err := checkContext(ctx, "validation"); err != nil {
+			return metrics, err
+		}
+		next, err := s.next()
 		if err != nil {
 			return metrics, err
 		}
 
-		predicted := result.Scaler.Inverse(nextNorm)
-		actual := series[i]
-		diff := actual - predicted
-		absDiff := math.Abs(diff)
+		predicted = append(predicted, next)
+		s.observe(series[i])
+	}
+
+	metrics = pointMetrics(series[trainEnd:], predicted, naiveScale(series[:trainEnd], season), result.ResidualStdDev)
+	metrics.Predicted = predicted
+	return metrics, nil
+}
+
+// InSampleResiduals returns the actual minus the one-step-ahead prediction
+// for every point of series after the first Lag.
+func InSampleResiduals(result *TrainResult, series []float64) ([]float64, error) {
+	if !result.valid() {
+		return nil, fmt.Errorf("invalid train result")
+	}
+	if len(series) <= result.Lag {
+		return nil, fmt.Errorf("series length must be larger than lag")
+	}
 
-		sumAbs += absDiff
-		sumSq += diff * diff
-		if math.Abs(actual) > 1e-9 {
-			sumPct += absDiff / math.Abs(actual)
-			pctCount++
+	s := result.newStepper(series[:result.Lag])
+	residuals := make([]float64, 0, len(series)-result.Lag)
+	for _, v := range series[result.Lag:] {
+		next, err := s.next()
+		if err != nil {
+			return nil, err
 		}
+		residuals = append(residuals, v-next)
+		s.observe(v)
+	}
+	return residuals, nil
+}
 
-		history = append(history, actual)
+func (r *TrainResult) valid() bool {
+	return r != nil && (r.Model != nil || r.Intermittent != nil)
+}
+
+// ModelType returns the TrainConfig.Model value that produced r.
+func (r *TrainResult) ModelType() string {
+	if r.Intermittent != nil {
+		return r.Intermittent.Method
 	}
+	return ModelMLP
+}
+
+// stepper makes one-step-ahead predictions while observing a series value
+// by value.
+type stepper interface {
+	next() (float64, error)
+	observe(v float64)
+}
 
-	metrics.Count = holdout
-	metrics.MAE = sumAbs / float64(holdout)
-	metrics.RMSE = math.Sqrt(sumSq / float64(holdout))
-	if pctCount > 0 {
-		metrics.MAPE = 100 * (sumPct / float64(pctCount))
+// newStepper returns a stepper that has already observed history, which
+// must hold at least Lag points. Its predictions respect the bounds and
+// rounding of r.Constraints.
+func (r *TrainResult) newStepper(history []float64) stepper {
+	var s stepper
+	if m := r.Intermittent; m != nil {
+		is := &intermittentStepper{model: m, state: m.start()}
+		for _, v := range history {
+			is.observe(v)
+		}
+		s = is
+	} else {
+		s = &windowStepper{result: r, window: append([]float64(nil), history[len(history)-r.Lag:]...)}
+	}
+	if r.Constraints != nil {
+		s = &clampedStepper{stepper: s, constraints: r.Constraints}
 	}
+	return s
+}
 
-	return metrics, nil
+type clampedStepper struct {
+	stepper
+	constraints *Constraints
+}
+
+func (s *clampedStepper) next() (float64, error) {
+	v, err := s.stepper.next()
+	return s.constraints.Clamp(v), err
+}
+
+type windowStepper struct {
+	result *TrainResult
+	window []float64
+}
+
+func (s *windowStepper) next() (float64, error) {
+	nextNorm, err := s.result.Model.Predict(s.result.Scaler.TransformSlice(s.window))
+	if err != nil {
+		return 0, err
+	}
+	return s.result.Scaler.Inverse(nextNorm), nil
+}
+
+// observe slides the window, keeping only the last Lag values so
+// long-running streams stay bounded.
+func (s *windowStepper) observe(v float64) {
+	s.window = append(s.window[1:], v)
+}
+
+type intermittentStepper struct {
+	model *IntermittentModel
+	state intermittentState
+}
+
+func (s *intermittentStepper) next() (float64, error) {
+	return s.model.predict(s.state), nil
+}
+
+func (s *intermittentStepper) observe(v float64) {
+	s.model.update(&s.state, v)
 }
 
 func makeWindows(series []float64, lag int) ([][]float64, []float64) {
@@ -106,3 +106,35 @@ func TestValidateRejectsInvalidInput(t *testing.T) {
 		t.Fatalf("expected error for oversized holdout")
 	}
 }
+
+func TestTrainOnEpochStopsEarly(t *testing.T) {
+	series := make([]float64, 0, 40)
+	for i := 0; i < 40; i++ {
+		series = append(series, math.Sin(float64(i)/3))
+	}
+
+	var seen []EpochStats
+	cfg := TrainConfig{Lag: 4, Hidden: 6, Epochs: 200, LearningRate: 0.01, Seed: 3}
+	cfg.OnEpoch = func(s EpochStats) bool {
+		seen = append(seen, s)
+		return s.Epoch == 25
+	}
+	result, err := Train(series, cfg)
+	if err != nil {
+		t.Fatalf("train failed: %v", err)
+	}
+	if len(seen) != 25 || result.Epochs != 25 || len(result.LossHistory) != 25 {
+		t.Fatalf("stopped after %d callbacks, Epochs %d, %d losses; want 25 each", len(seen), result.Epochs, len(result.LossHistory))
+	}
+	for i, s := range seen {
+		if s.Epoch != i+1 || s.Epochs != 200 || s.LearningRate != 0.01 || s.Loss != result.LossHistory[i] {
+			t.Fatalf("epoch %d stats = %+v", i+1, s)
+		}
+		if i > 0 && s.Elapsed < seen[i-1].Elapsed {
+			t.Fatalf("elapsed went backwards at epoch %d", s.Epoch)
+		}
+	}
+	if result.LossHistory[24] >= result.LossHistory[0] {
+		t.Fatalf("loss did not fall: %v", result.LossHistory)
+	}
+}
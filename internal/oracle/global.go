@@ -0,0 +1,262 @@
+package oracle
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+// Series features appended to each training window of a global model.
+const (
+	SeriesFeaturesNone      = "none"
+	SeriesFeaturesOneHot    = "onehot"
+	SeriesFeaturesEmbedding = "embedding"
+)
+
+type GlobalConfig struct {
+	Train TrainConfig
+	// Features tells the network which series a window came from: none,
+	// onehot (one input per series) or embedding (a learned vector of
+	// EmbeddingDim inputs per series, default 3).
+	Features     string
+	EmbeddingDim int
+}
+
+// GlobalModel is one network trained on the windows of many series, each
+// normalized by its own Standardizer.
+type GlobalModel struct {
+	Model      *MLP
+	Lag        int
+	Epochs     int
+	Features   string
+	IDs        []string
+	Scalers    []Standardizer
+	Embeddings [][]float64
+	// Constraints apply to the forecasts of every series.
+	Constraints *Constraints
+
+	// MSE is the in-sample error over all pooled windows on the normalized
+	// scale, where series of different magnitudes are comparable.
+	MSE float64
+	// LossHistory is the pooled training loss of each epoch, not saved.
+	LossHistory []float64
+
+	index map[string]int
+	fits  []seriesFit
+}
+
+type seriesFit struct {
+	mse, stdDev float64
+}
+
+// TrainGlobal fits one shared network on all series. Series too short to
+// form a window still get a scaler, so they can be forecast once they are
+// at least Lag points long.
+func TrainGlobal(series []NamedSeries, cfg GlobalConfig) (*GlobalModel, error) {
+	return TrainGlobalContext(context.Background(), series, cfg)
+}
+
+// TrainGlobalContext is TrainGlobal that checks ctx before every epoch and
+// stops with a *CanceledError once it is done. KeepPartial is not supported.
+func TrainGlobalContext(ctx context.Context, series []NamedSeries, cfg GlobalConfig) (*GlobalModel, error) {
+	if len(series) == 0 {
+		return nil, fmt.Errorf("no series to train on")
+	}
+	train := cfg.Train.withDefaults()
+	if model := normalizeModel(train.Model); model != ModelMLP {
+		return nil, fmt.Errorf("a global model must be an mlp, not %q", train.Model)
+	}
+	if err := train.Constraints.Validate(); err != nil {
+		return nil, err
+	}
+
+	features := strings.ToLower(strings.TrimSpace(cfg.Features))
+	featureDim := 0
+	switch features {
+	case "", SeriesFeaturesNone:
+		features = SeriesFeaturesNone
+	case SeriesFeaturesOneHot:
+		featureDim = len(series)
+	case SeriesFeaturesEmbedding:
+		featureDim = cfg.EmbeddingDim
+		if featureDim <= 0 {
+			featureDim = 3
+		}
+	default:
+		return nil, fmt.Errorf("unknown series features %q (use none, onehot or embedding)", cfg.Features)
+	}
+
+	g := &GlobalModel{
+		Lag:         train.Lag,
+		Epochs:      train.Epochs,
+		Features:    features,
+		Constraints: train.Constraints,
+		index:       map[string]int{},
+	}
+
+	type sample struct {
+		series int
+		in     []float64
+		target float64
+	}
+	var samples []sample
+	for i, s := range series {
+		if s.Err != nil {
+			return nil, fmt.Errorf("series %q: %w", s.ID, s.Err)
+		}
+		if _, dup := g.index[s.ID]; dup {
+			return nil, fmt.Errorf("duplicate series ID %q", s.ID)
+		}
+		g.index[s.ID] = i
+		g.IDs = append(g.IDs, s.ID)
+
+		scaler := Standardizer{}
+		scaler.Fit(s.Values)
+		g.Scalers = append(g.Scalers, scaler)
+
+		if len(s.Values) <= train.Lag {
+			continue
+		}
+		x, y := makeWindows(scaler.TransformSlice(s.Values), train.Lag)
+		for k := range x {
+			in := append(x[k], make([]float64, featureDim)...)
+			if features == SeriesFeaturesOneHot {
+				in[train.Lag+i] = 1
+			}
+			samples = append(samples, sample{series: i, in: in, target: y[k]})
+		}
+	}
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("no series is longer than lag %d", train.Lag)
+	}
+
+	rnd := rand.New(rand.NewSource(train.Seed))
+	g.Model = NewMLP(train.Lag+featureDim, train.Hidden, rnd)
+	if features == SeriesFeaturesEmbedding {
+		g.Embeddings = make([][]float64, len(series))
+		for i := range g.Embeddings {
+			g.Embeddings[i] = make([]float64, featureDim)
+			for k := range g.Embeddings[i] {
+				g.Embeddings[i][k] = (rnd.Float64()*2 - 1) * 0.1
+			}
+		}
+	}
+
+	order := make([]int, len(samples))
+	for i := range order {
+		order[i] = i
+	}
+	var dIn []float64
+	if features == SeriesFeaturesEmbedding {
+		dIn = make([]float64, train.Lag+featureDim)
+	}
+	start := time.Now()
+	for epoch := 0; epoch < train.Epochs; epoch++ {
+		if err := ctx.Err(); err != nil {
+			return nil, &CanceledError{Op: "global training", Epoch: epoch, Epochs: train.Epochs, Err: err}
+		}
+		rnd.Shuffle(len(order), func(i, j int) {
+			order[i], order[j] = order[j], order[i]
+		})
+
+		sum := 0.0
+		for _, idx := range order {
+			s := samples[idx]
+			if dIn != nil {
+				copy(s.in[train.Lag:], g.Embeddings[s.series])
+			}
+			loss, err := g.Model.step(s.in, s.target, train.LearningRate, dIn)
+			if err != nil {
+				return nil, err
+			}
+			sum += loss
+			if dIn != nil {
+				emb := g.Embeddings[s.series]
+				for k := range emb {
+					emb[k] -= train.LearningRate * dIn[train.Lag+k]
+				}
+			}
+		}
+		if train.epochDone(&g.LossHistory, epoch, sum/float64(len(order)), start) {
+			break
+		}
+	}
+	g.Epochs = len(g.LossHistory)
+
+	sumSq := 0.0
+	for _, s := range samples {
+		if dIn != nil {
+			copy(s.in[train.Lag:], g.Embeddings[s.series])
+		}
+		pred, err := g.Model.Predict(s.in)
+		if err != nil {
+			return nil, err
+		}
+		sumSq += (pred - s.target) * (pred - s.target)
+	}
+	g.MSE = sumSq / float64(len(samples))
+
+	g.fits = make([]seriesFit, len(series))
+	for i, s := range series {
+		if len(s.Values) <= train.Lag {
+			continue
+		}
+		model := g.seriesModel(i)
+		x, _ := makeWindows(g.Scalers[i].TransformSlice(s.Values), train.Lag)
+		mse, stdDev, err := evaluate(model, g.Scalers[i], s.Values, x, train.Lag)
+		if err != nil {
+			return nil, err
+		}
+		g.fits[i] = seriesFit{mse: mse, stdDev: stdDev}
+	}
+	return g, nil
+}
+
+// Result returns the shared network specialized to one series, usable with
+// Forecast, Validate and Detect like a model from Train. MSE and
+// ResidualStdDev are that series' own in-sample fit, zero when it was too
+// short to form a window.
+func (g *GlobalModel) Result(id string) (*TrainResult, error) {
+	i, ok := g.index[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: series %q is not part of the global model", ErrModelNotFound, id)
+	}
+	return &TrainResult{
+		Model:          g.seriesModel(i),
+		Constraints:    g.Constraints,
+		Scaler:         g.Scalers[i],
+		Lag:            g.Lag,
+		Epochs:         g.Epochs,
+		MSE:            g.fits[i].mse,
+		ResidualStdDev: g.fits[i].stdDev,
+		LossHistory:    g.LossHistory,
+	}, nil
+}
+
+// seriesModel folds the constant series features into the hidden biases,
+// leaving an equivalent network over the lag window alone.
+func (g *GlobalModel) seriesModel(i int) *MLP {
+	m := g.Model
+	out := &MLP{
+		InputSize:  g.Lag,
+		HiddenSize: m.HiddenSize,
+		W1:         make([][]float64, m.HiddenSize),
+		B1:         append([]float64(nil), m.B1...),
+		W2:         append([]float64(nil), m.W2...),
+		B2:         m.B2,
+	}
+	for j := range out.W1 {
+		out.W1[j] = append([]float64(nil), m.W1[j][:g.Lag]...)
+		switch g.Features {
+		case SeriesFeaturesOneHot:
+			out.B1[j] += m.W1[j][g.Lag+i]
+		case SeriesFeaturesEmbedding:
+			for k, e := range g.Embeddings[i] {
+				out.B1[j] += m.W1[j][g.Lag+k] * e
+			}
+		}
+	}
+	return out
+}
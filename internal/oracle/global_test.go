@@ -0,0 +1,124 @@
+package oracle
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func relatedSeries(count, length int) []NamedSeries {
+	out := make([]NamedSeries, 0, count)
+	for s := 0; s < count; s++ {
+		level := 10 * float64(s+1)
+		values := make([]float64, length)
+		for i := range values {
+			values[i] = level + level/5*math.Sin(float64(i)/2+float64(s))
+		}
+		out = append(out, NamedSeries{ID: fmt.Sprintf("s%d", s), Values: values})
+	}
+	return out
+}
+
+func TestTrainGlobalSingleSeriesMatchesTrain(t *testing.T) {
+	series := relatedSeries(1, 40)
+	cfg := TrainConfig{Lag: 5, Hidden: 6, Epochs: 200, Seed: 3}
+
+	local, err := Train(series[0].Values, cfg)
+	if err != nil {
+		t.Fatalf("Train failed: %v", err)
+	}
+	global, err := TrainGlobal(series, GlobalConfig{Train: cfg})
+	if err != nil {
+		t.Fatalf("TrainGlobal failed: %v", err)
+	}
+	result, err := global.Result("s0")
+	if err != nil {
+		t.Fatalf("Result failed: %v", err)
+	}
+
+	if !reflect.DeepEqual(result.Model, local.Model) || result.MSE != local.MSE || result.ResidualStdDev != local.ResidualStdDev {
+		t.Fatalf("global model over one series should equal Train")
+	}
+}
+
+func TestGlobalResultMatchesSharedNetwork(t *testing.T) {
+	series := relatedSeries(4, 25)
+	for _, features := range []string{SeriesFeaturesOneHot, SeriesFeaturesEmbedding} {
+		g, err := TrainGlobal(series, GlobalConfig{
+			Train:        TrainConfig{Lag: 4, Hidden: 8, Epochs: 150, Seed: 2},
+			Features:     features,
+			EmbeddingDim: 2,
+		})
+		if err != nil {
+			t.Fatalf("%s: TrainGlobal failed: %v", features, err)
+		}
+
+		wantInputs := 4 + 4
+		if features == SeriesFeaturesEmbedding {
+			wantInputs = 4 + 2
+		}
+		if g.Model.InputSize != wantInputs {
+			t.Fatalf("%s: input size = %d, want %d", features, g.Model.InputSize, wantInputs)
+		}
+
+		for i, s := range series {
+			result, err := g.Result(s.ID)
+			if err != nil {
+				t.Fatalf("%s: Result failed: %v", features, err)
+			}
+			window := g.Scalers[i].TransformSlice(s.Values[:4])
+			in := append(append([]float64(nil), window...), make([]float64, wantInputs-4)...)
+			if features == SeriesFeaturesOneHot {
+				in[4+i] = 1
+			} else {
+				copy(in[4:], g.Embeddings[i])
+			}
+
+			full, _ := g.Model.Predict(in)
+			folded, _ := result.Model.Predict(window)
+			if math.Abs(full-folded) > 1e-12 {
+				t.Fatalf("%s/%s: folded prediction %f, shared network %f", features, s.ID, folded, full)
+			}
+			if result.ResidualStdDev <= 0 {
+				t.Fatalf("%s/%s: missing per-series fit", features, s.ID)
+			}
+		}
+	}
+}
+
+func TestGlobalModelForecastsEachSeriesOnItsScale(t *testing.T) {
+	series := relatedSeries(5, 30)
+	g, err := TrainGlobal(series, GlobalConfig{
+		Train:    TrainConfig{Lag: 4, Hidden: 10, Epochs: 400, Seed: 5},
+		Features: SeriesFeaturesEmbedding,
+	})
+	if err != nil {
+		t.Fatalf("TrainGlobal failed: %v", err)
+	}
+
+	for _, s := range series {
+		result, err := g.Result(s.ID)
+		if err != nil {
+			t.Fatalf("Result failed: %v", err)
+		}
+		metrics, err := Validate(result, s.Values, 5)
+		if err != nil {
+			t.Fatalf("Validate failed: %v", err)
+		}
+		if metrics.MAPE > 15 {
+			t.Fatalf("%s: MAPE %.2f%% too high for a shared model", s.ID, metrics.MAPE)
+		}
+	}
+
+	if _, err := g.Result("missing"); !errors.Is(err, ErrModelNotFound) {
+		t.Fatalf("unknown series error = %v, want ErrModelNotFound", err)
+	}
+	if _, err := TrainGlobal([]NamedSeries{{ID: "a", Values: []float64{1, 2}}}, GlobalConfig{}); err == nil {
+		t.Fatalf("expected an error when no series forms a window")
+	}
+	if _, err := TrainGlobal(append(series[:1:1], series[0]), GlobalConfig{}); err == nil {
+		t.Fatalf("expected an error for duplicate IDs")
+	}
+}
@@ -0,0 +1,181 @@
+package oracle
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Model types selectable with TrainConfig.Model.
+const (
+	ModelMLP     = "mlp"
+	ModelCroston = "croston"
+	ModelSBA     = "sba"
+	ModelTSB     = "tsb"
+)
+
+// ModelTypes lists the accepted values of TrainConfig.Model.
+var ModelTypes = []string{ModelMLP, ModelCroston, ModelSBA, ModelTSB}
+
+// IntermittentModel is a Croston-type smoother for series that are mostly
+// zero. Croston and SBA (Syntetos-Boylan, Croston with its bias corrected
+// by 1-Alpha/2) smooth the demand size and the interval between demands
+// whenever demand occurs; TSB (Teunter-Syntetos-Babai) smooths the size
+// with Alpha and the probability of demand with Beta every period. The
+// forecast is the same for every future step.
+type IntermittentModel struct {
+	Method          string  `json:"method"`
+	Alpha           float64 `json:"alpha"`
+	Beta            float64 `json:"beta,omitempty"`
+	InitDemand      float64 `json:"init_demand"`
+	InitInterval    float64 `json:"init_interval,omitempty"`
+	InitProbability float64 `json:"init_probability,omitempty"`
+}
+
+type intermittentState struct {
+	demand, interval, probability float64
+	// since counts the periods since the last demand, including the
+	// current one.
+	since int
+}
+
+func (m *IntermittentModel) start() intermittentState {
+	return intermittentState{demand: m.InitDemand, interval: m.InitInterval, probability: m.InitProbability, since: 1}
+}
+
+func (m *IntermittentModel) update(s *intermittentState, v float64) {
+	if m.Method == ModelTSB {
+		if v > 0 {
+			s.demand += m.Alpha * (v - s.demand)
+			s.probability += m.Beta * (1 - s.probability)
+		} else {
+			s.probability -= m.Beta * s.probability
+		}
+		return
+	}
+	if v > 0 {
+		s.demand += m.Alpha * (v - s.demand)
+		s.interval += m.Alpha * (float64(s.since) - s.interval)
+		s.since = 1
+	} else {
+		s.since++
+	}
+}
+
+func (m *IntermittentModel) predict(s intermittentState) float64 {
+	switch m.Method {
+	case ModelTSB:
+		return s.probability * s.demand
+	case ModelSBA:
+		return (1 - m.Alpha/2) * s.demand / s.interval
+	}
+	return s.demand / s.interval
+}
+
+func (m *IntermittentModel) validate() error {
+	switch {
+	case !containsModel(m.Method) || m.Method == ModelMLP:
+		return fmt.Errorf("unknown intermittent method %q", m.Method)
+	case m.Alpha <= 0 || m.Alpha > 1:
+		return fmt.Errorf("invalid alpha: %v", m.Alpha)
+	case m.Method == ModelTSB && (m.Beta <= 0 || m.Beta > 1):
+		return fmt.Errorf("invalid beta: %v", m.Beta)
+	case m.Method != ModelTSB && m.InitInterval < 1:
+		return fmt.Errorf("invalid initial interval: %v", m.InitInterval)
+	}
+	return nil
+}
+
+// smoothingGrid holds the candidate values of Alpha and Beta; 0.05-0.3 is
+// the range usually recommended, extended a little for volatile series.
+var smoothingGrid = []float64{0.05, 0.1, 0.15, 0.2, 0.25, 0.3, 0.4, 0.5}
+
+// trainIntermittent initializes the smoother from the averages of the whole
+// series and picks the smoothing constants with the lowest in-sample
+// one-step MSE.
+func trainIntermittent(series []float64, method string) (*TrainResult, error) {
+	nonzero, sum := 0, 0.0
+	for _, v := range series {
+		if v < 0 {
+			return nil, fmt.Errorf("%s needs non-negative data, got %v", method, v)
+		}
+		if v > 0 {
+			nonzero++
+			sum += v
+		}
+	}
+	if nonzero == 0 {
+		return nil, fmt.Errorf("%s needs at least one non-zero value", method)
+	}
+
+	base := IntermittentModel{
+		Method:     method,
+		InitDemand: sum / float64(nonzero),
+	}
+	if method == ModelTSB {
+		base.InitProbability = float64(nonzero) / float64(len(series))
+	} else {
+		base.InitInterval = float64(len(series)) / float64(nonzero)
+	}
+
+	betas := []float64{0}
+	if method == ModelTSB {
+		betas = smoothingGrid
+	}
+	var best *IntermittentModel
+	bestMSE := math.Inf(1)
+	for _, alpha := range smoothingGrid {
+		for _, beta := range betas {
+			m := base
+			m.Alpha, m.Beta = alpha, beta
+			mse, _ := intermittentFit(&m, series)
+			if mse < bestMSE {
+				best, bestMSE = &m, mse
+			}
+		}
+	}
+
+	scaler := Standardizer{}
+	scaler.Fit(series)
+	mse, stdDev := intermittentFit(best, series)
+	return &TrainResult{
+		Intermittent:   best,
+		Scaler:         scaler,
+		Lag:            1,
+		MSE:            mse,
+		ResidualStdDev: stdDev,
+	}, nil
+}
+
+// intermittentFit returns the MSE and standard deviation of the one-step
+// residuals from the second point on.
+func intermittentFit(m *IntermittentModel, series []float64) (float64, float64) {
+	s := m.start()
+	m.update(&s, series[0])
+	residuals := make([]float64, 0, len(series)-1)
+	sumSq := 0.0
+	for _, v := range series[1:] {
+		r := v - m.predict(s)
+		residuals = append(residuals, r)
+		sumSq += r * r
+		m.update(&s, v)
+	}
+	return sumSq / float64(len(residuals)), stdDev(residuals)
+}
+
+func containsModel(name string) bool {
+	for _, m := range ModelTypes {
+		if m == name {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeModel(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return ModelMLP
+	}
+	return name
+}
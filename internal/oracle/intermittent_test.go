@@ -0,0 +1,88 @@
+package oracle
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+)
+
+func intermittentSeries() []float64 {
+	return []float64{0, 0, 3, 0, 0, 0, 2, 0, 4, 0, 0, 0, 0, 3, 0, 0, 2, 0, 0, 5, 0, 0, 0, 3}
+}
+
+func TestIntermittentUpdates(t *testing.T) {
+	m := &IntermittentModel{Method: ModelCroston, Alpha: 0.5, InitDemand: 2, InitInterval: 2}
+	s := m.start()
+	for _, v := range []float64{0, 0, 4} {
+		m.update(&s, v)
+	}
+	// demand 2 -> 3, interval 2 -> 2.5 (three periods since the start).
+	if got := m.predict(s); math.Abs(got-1.2) > 1e-12 {
+		t.Fatalf("croston forecast = %v, want 1.2", got)
+	}
+	m.Method = ModelSBA
+	if got := m.predict(s); math.Abs(got-0.9) > 1e-12 {
+		t.Fatalf("sba forecast = %v, want 0.9", got)
+	}
+
+	tsb := &IntermittentModel{Method: ModelTSB, Alpha: 0.5, Beta: 0.5, InitDemand: 2, InitProbability: 0.5}
+	ts := tsb.start()
+	tsb.update(&ts, 0)
+	tsb.update(&ts, 4)
+	// probability 0.5 -> 0.25 -> 0.625, demand 2 -> 3.
+	if got := tsb.predict(ts); math.Abs(got-1.875) > 1e-12 {
+		t.Fatalf("tsb forecast = %v, want 1.875", got)
+	}
+}
+
+func TestTrainIntermittent(t *testing.T) {
+	series := intermittentSeries()
+	for _, model := range []string{ModelCroston, ModelSBA, ModelTSB} {
+		result, err := Train(series, TrainConfig{Model: model})
+		if err != nil {
+			t.Fatalf("%s: Train failed: %v", model, err)
+		}
+		if result.ModelType() != model || result.Lag != 1 || result.Model != nil {
+			t.Fatalf("%s: unexpected result %+v", model, result)
+		}
+
+		forecast, err := Forecast(result, series, 3)
+		if err != nil {
+			t.Fatalf("%s: Forecast failed: %v", model, err)
+		}
+		if forecast[0] <= 0 || forecast[0] > 3 || forecast[1] != forecast[0] || forecast[2] != forecast[0] {
+			t.Fatalf("%s: forecast should be flat and within the demand range: %v", model, forecast)
+		}
+
+		metrics, err := Validate(result, series, 8)
+		if err != nil {
+			t.Fatalf("%s: Validate failed: %v", model, err)
+		}
+		if metrics.ZeroCount != 5 || metrics.MASE <= 0 || metrics.SPL <= 0 {
+			t.Fatalf("%s: unexpected metrics %+v", model, metrics)
+		}
+
+		path := filepath.Join(t.TempDir(), "model.json")
+		if err := SaveModel(path, result); err != nil {
+			t.Fatalf("%s: SaveModel failed: %v", model, err)
+		}
+		loaded, err := LoadModel(path)
+		if err != nil {
+			t.Fatalf("%s: LoadModel failed: %v", model, err)
+		}
+		again, err := Forecast(loaded, series, 1)
+		if err != nil || again[0] != forecast[0] {
+			t.Fatalf("%s: loaded model forecasts %v, %v; want %v", model, again, err, forecast[0])
+		}
+	}
+
+	if _, err := Train([]float64{0, 0, 0, 0, 0, 0}, TrainConfig{Model: ModelCroston}); err == nil {
+		t.Fatalf("expected error for a series without demand")
+	}
+	if _, err := Train([]float64{0, 1, -1, 0, 2, 0}, TrainConfig{Model: ModelTSB}); err == nil {
+		t.Fatalf("expected error for negative values")
+	}
+	if _, err := Train(series, TrainConfig{Model: "arima"}); err == nil {
+		t.Fatalf("expected error for an unknown model")
+	}
+}
@@ -0,0 +1,97 @@
+package oracle
+
+import (
+	"fmt"
+	"math"
+)
+
+// solve returns X with A·X = B by Gaussian elimination with partial
+// pivoting. A is n×n and B is n×m; neither is modified.
+func solve(a, b [][]float64) ([][]float64, error) {
+	n := len(a)
+	if len(b) != n {
+		return nil, fmt.Errorf("dimension mismatch: %d×%d system with %d right-hand rows", n, n, len(b))
+	}
+	m := 0
+	if n > 0 {
+		m = len(b[0])
+	}
+
+	aug := make([][]float64, n)
+	for i := range aug {
+		if len(a[i]) != n {
+			return nil, fmt.Errorf("matrix is not square")
+		}
+		aug[i] = make([]float64, n+m)
+		copy(aug[i], a[i])
+		copy(aug[i][n:], b[i])
+	}
+
+	scale := 0.0
+	for i := range a {
+		for _, v := range a[i] {
+			scale = math.Max(scale, math.Abs(v))
+		}
+	}
+	for col := 0; col < n; col++ {
+		pivot := col
+		for r := col + 1; r < n; r++ {
+			if math.Abs(aug[r][col]) > math.Abs(aug[pivot][col]) {
+				pivot = r
+			}
+		}
+		if math.Abs(aug[pivot][col]) <= 1e-12*scale {
+			return nil, fmt.Errorf("matrix is singular")
+		}
+		aug[col], aug[pivot] = aug[pivot], aug[col]
+
+		for r := 0; r < n; r++ {
+			if r == col || aug[r][col] == 0 {
+				continue
+			}
+			f := aug[r][col] / aug[col][col]
+			for c := col; c < n+m; c++ {
+				aug[r][c] -= f * aug[col][c]
+			}
+		}
+	}
+
+	x := make([][]float64, n)
+	for i := range x {
+		x[i] = make([]float64, m)
+		for j := 0; j < m; j++ {
+			x[i][j] = aug[i][n+j] / aug[i][i]
+		}
+	}
+	return x, nil
+}
+
+func matMul(a, b [][]float64) [][]float64 {
+	out := make([][]float64, len(a))
+	for i := range a {
+		out[i] = make([]float64, len(b[0]))
+		for k, av := range a[i] {
+			if av == 0 {
+				continue
+			}
+			for j, bv := range b[k] {
+				out[i][j] += av * bv
+			}
+		}
+	}
+	return out
+}
+
+func transpose(a [][]float64) [][]float64 {
+	if len(a) == 0 {
+		return nil
+	}
+	out := make([][]float64, len(a[0]))
+	for j := range out {
+		out[j] = make([]float64, len(a))
+		for i := range a {
+			out[j][i] = a[i][j]
+		}
+	}
+	return out
+}
@@ -107,3 +107,42 @@ func (m *MLP) Predict(x []float64) (float64, error) {
 	_, out, err := m.Forward(x)
 	return out, err
 }
+
+// step applies one SGD update for a squared-error loss on a single sample.
+// When dIn is non-nil it receives the loss gradient with respect to the
+// input, taken before the weights change. It returns the sample's squared
+// error before the update.
+func (m *MLP) step(in []float64, target, lr float64, dIn []float64) (float64, error) {
+	h, out, err := m.Forward(in)
+	if err != nil {
+		return 0, err
+	}
+
+	dOut := 2.0 * (out - target)
+	dZ1 := make([]float64, m.HiddenSize)
+	for j := 0; j < m.HiddenSize; j++ {
+		dZ1[j] = dOut * m.W2[j] * (1 - h[j]*h[j])
+	}
+
+	if dIn != nil {
+		for i := range dIn {
+			dIn[i] = 0
+			for j := 0; j < m.HiddenSize; j++ {
+				dIn[i] += dZ1[j] * m.W1[j][i]
+			}
+		}
+	}
+
+	for j := 0; j < m.HiddenSize; j++ {
+		m.W2[j] -= lr * dOut * h[j]
+	}
+	m.B2 -= lr * dOut
+
+	for j := 0; j < m.HiddenSize; j++ {
+		for i := 0; i < m.InputSize; i++ {
+			m.W1[j][i] -= lr * dZ1[j] * in[i]
+		}
+		m.B1[j] -= lr * dZ1[j]
+	}
+	return (out - target) * (out - target), nil
+}
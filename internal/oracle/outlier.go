@@ -0,0 +1,156 @@
+package oracle
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+const (
+	OutlierHampel = "hampel"
+	OutlierMAD    = "mad"
+	OutlierIQR    = "iqr"
+
+	OutlierActionFlag    = "flag"
+	OutlierActionClip    = "clip"
+	OutlierActionReplace = "replace"
+)
+
+// OutlierConfig selects how outliers are detected and what happens to them.
+//
+// Hampel uses a centered window of Window points on each side, MAD uses the
+// Window points preceding each value, and IQR uses fences over the whole
+// series. Threshold is the number of robust deviations (Hampel, MAD) or the
+// IQR multiplier; zero selects 3 and 1.5 respectively.
+type OutlierConfig struct {
+	Method    string
+	Action    string
+	Window    int
+	Threshold float64
+}
+
+type OutlierReport struct {
+	Method    string
+	Action    string
+	Indices   []int
+	Original  []float64
+	Cleaned   []float64
+	Threshold float64
+}
+
+// CleanOutliers detects outliers in series and returns a copy in which they
+// have been handled according to cfg.Action. With the flag action the
+// returned series equals the input.
+func CleanOutliers(series []float64, cfg OutlierConfig) ([]float64, OutlierReport, error) {
+	cfg.Method = strings.ToLower(strings.TrimSpace(cfg.Method))
+	cfg.Action = strings.ToLower(strings.TrimSpace(cfg.Action))
+	if cfg.Action == "" {
+		cfg.Action = OutlierActionFlag
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = 5
+	}
+	if cfg.Threshold <= 0 {
+		cfg.Threshold = 3
+		if cfg.Method == OutlierIQR {
+			cfg.Threshold = 1.5
+		}
+	}
+
+	report := OutlierReport{Method: cfg.Method, Action: cfg.Action, Threshold: cfg.Threshold}
+	switch cfg.Action {
+	case OutlierActionFlag, OutlierActionClip, OutlierActionReplace:
+	default:
+		return nil, report, fmt.Errorf("unknown outlier action: %q", cfg.Action)
+	}
+	if len(series) == 0 {
+		return nil, report, fmt.Errorf("empty series")
+	}
+
+	var bounds []outlierBound
+	switch cfg.Method {
+	case OutlierHampel:
+		bounds = hampelBounds(series, cfg.Window, cfg.Threshold)
+	case OutlierMAD:
+		bounds = rollingMADBounds(series, cfg.Window, cfg.Threshold)
+	case OutlierIQR:
+		bounds = iqrBounds(series, cfg.Threshold)
+	default:
+		return nil, report, fmt.Errorf("unknown outlier method: %q", cfg.Method)
+	}
+
+	cleaned := append([]float64(nil), series...)
+	for i, v := range series {
+		b := bounds[i]
+		if !b.ok || (v >= b.low && v <= b.high) {
+			continue
+		}
+		report.Indices = append(report.Indices, i)
+		report.Original = append(report.Original, v)
+
+		switch cfg.Action {
+		case OutlierActionClip:
+			cleaned[i] = math.Max(b.low, math.Min(b.high, v))
+		case OutlierActionReplace:
+			cleaned[i] = b.fill
+		}
+		report.Cleaned = append(report.Cleaned, cleaned[i])
+	}
+
+	return cleaned, report, nil
+}
+
+// outlierBound is the accepted range for one point and the value used to
+// replace it. Points without enough context have ok set to false.
+type outlierBound struct {
+	low, high, fill float64
+	ok              bool
+}
+
+func hampelBounds(series []float64, window int, threshold float64) []outlierBound {
+	bounds := make([]outlierBound, len(series))
+	for i := range series {
+		lo := max(0, i-window)
+		hi := min(len(series), i+window+1)
+		neighbourhood := series[lo:hi]
+		m := median(neighbourhood)
+		s := threshold * mad(neighbourhood, m)
+		bounds[i] = outlierBound{low: m - s, high: m + s, fill: m, ok: true}
+	}
+	return bounds
+}
+
+// rollingMADBounds only looks at preceding values, so points with fewer than
+// three predecessors are never flagged.
+func rollingMADBounds(series []float64, window int, threshold float64) []outlierBound {
+	bounds := make([]outlierBound, len(series))
+	for i := range series {
+		lo := max(0, i-window)
+		if i-lo < 3 {
+			continue
+		}
+		past := series[lo:i]
+		m := median(past)
+		s := threshold * mad(past, m)
+		bounds[i] = outlierBound{low: m - s, high: m + s, fill: m, ok: true}
+	}
+	return bounds
+}
+
+func iqrBounds(series []float64, k float64) []outlierBound {
+	sorted := sortedCopy(series)
+	q1 := quantile(sorted, 0.25)
+	q3 := quantile(sorted, 0.75)
+	b := outlierBound{
+		low:  q1 - k*(q3-q1),
+		high: q3 + k*(q3-q1),
+		fill: quantile(sorted, 0.5),
+		ok:   true,
+	}
+
+	bounds := make([]outlierBound, len(series))
+	for i := range bounds {
+		bounds[i] = b
+	}
+	return bounds
+}
@@ -0,0 +1,74 @@
+package oracle
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCleanOutliersHampelReplacesSpike(t *testing.T) {
+	series := make([]float64, 0, 40)
+	for i := 0; i < 40; i++ {
+		series = append(series, 10+0.5*float64(i))
+	}
+	series[17] = 500
+
+	cleaned, report, err := CleanOutliers(series, OutlierConfig{Method: OutlierHampel, Action: OutlierActionReplace})
+	if err != nil {
+		t.Fatalf("CleanOutliers failed: %v", err)
+	}
+	if len(report.Indices) != 1 || report.Indices[0] != 17 {
+		t.Fatalf("flagged indices = %v, want [17]", report.Indices)
+	}
+	if math.Abs(cleaned[17]-(10+0.5*17)) > 1 {
+		t.Fatalf("replaced value = %.4f, want close to %.4f", cleaned[17], 10+0.5*17)
+	}
+	if series[17] != 500 {
+		t.Fatalf("input series was modified")
+	}
+}
+
+func TestCleanOutliersActions(t *testing.T) {
+	series := []float64{5, 6, 5, 7, 6, 5, 6, 7, 5, 6, -80, 6, 5, 7}
+
+	flagged, report, err := CleanOutliers(series, OutlierConfig{Method: OutlierIQR, Action: OutlierActionFlag})
+	if err != nil {
+		t.Fatalf("CleanOutliers(flag) failed: %v", err)
+	}
+	if len(report.Indices) != 1 || report.Indices[0] != 10 {
+		t.Fatalf("flagged indices = %v, want [10]", report.Indices)
+	}
+	if flagged[10] != -80 {
+		t.Fatalf("flag action changed the value: %.4f", flagged[10])
+	}
+
+	clipped, _, err := CleanOutliers(series, OutlierConfig{Method: OutlierIQR, Action: OutlierActionClip})
+	if err != nil {
+		t.Fatalf("CleanOutliers(clip) failed: %v", err)
+	}
+	if clipped[10] <= -80 || clipped[10] > 5 {
+		t.Fatalf("clipped value = %.4f, want lower fence", clipped[10])
+	}
+
+	// The rolling MAD looks backwards, so the spike is judged against the
+	// points before it only.
+	_, report, err = CleanOutliers(series, OutlierConfig{Method: OutlierMAD, Window: 6})
+	if err != nil {
+		t.Fatalf("CleanOutliers(mad) failed: %v", err)
+	}
+	found := false
+	for _, idx := range report.Indices {
+		found = found || idx == 10
+	}
+	if !found {
+		t.Fatalf("flagged indices = %v, want 10 included", report.Indices)
+	}
+}
+
+func TestCleanOutliersRejectsUnknownMethod(t *testing.T) {
+	if _, _, err := CleanOutliers([]float64{1, 2, 3}, OutlierConfig{Method: "zscore"}); err == nil {
+		t.Fatalf("expected error for unknown method")
+	}
+	if _, _, err := CleanOutliers([]float64{1, 2, 3}, OutlierConfig{Method: OutlierIQR, Action: "drop"}); err == nil {
+		t.Fatalf("expected error for unknown action")
+	}
+}
@@ -4,9 +4,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
 )
 
-const modelFormatVersion = 1
+// modelFormatVersion is the version SaveModel writes. Version 2 added the
+// intermittent, constraints and training_stats fields, so readers of
+// version 1 reject such files instead of dropping what they do not know;
+// version 1 files are plain MLPs and still load.
+const modelFormatVersion = 2
 
 type persistedModel struct {
 	Version        int          `json:"version"`
@@ -18,59 +23,84 @@ type persistedModel struct {
 	B1             []float64    `json:"b1"`
 	W2             []float64    `json:"w2"`
 	B2             float64      `json:"b2"`
+
+	// Intermittent replaces the network weights for the intermittent-demand
+	// models; files without it are MLPs.
+	Intermittent *IntermittentModel `json:"intermittent,omitempty"`
+
+	Constraints *Constraints `json:"constraints,omitempty"`
+
+	// TrainingStats is omitted by files saved before it was recorded.
+	TrainingStats *TrainingStats `json:"training_stats,omitempty"`
 }
 
 func SaveModel(path string, result *TrainResult) error {
-	if result == nil || result.Model == nil {
+	if !result.valid() {
 		return fmt.Errorf("invalid train result")
 	}
 
-	pm := persistedModel{
-		Version:        modelFormatVersion,
-		Lag:            result.Lag,
-		Scaler:         result.Scaler,
-		MSE:            result.MSE,
-		ResidualStdDev: result.ResidualStdDev,
-		W1:             result.Model.W1,
-		B1:             result.Model.B1,
-		W2:             result.Model.W2,
-		B2:             result.Model.B2,
-	}
-
+	pm := persistResult(result)
 	if err := validatePersistedModel(pm); err != nil {
 		return err
 	}
 
-	file, err := os.Create(path)
+	body, err := json.MarshalIndent(pm, "", "  ")
 	if err != nil {
 		return err
 	}
-	defer file.Close()
-
-	enc := json.NewEncoder(file)
-	enc.SetIndent("", "  ")
-	if err := enc.Encode(pm); err != nil {
-		return err
-	}
-	return nil
+	return writeFileAtomic(path, append(body, '\n'))
 }
 
 func LoadModel(path string) (*TrainResult, error) {
-	file, err := os.Open(path)
+	body, err := os.ReadFile(path)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
+	return decodeModel(body)
+}
 
+func decodeModel(body []byte) (*TrainResult, error) {
 	var pm persistedModel
-	dec := json.NewDecoder(file)
-	if err := dec.Decode(&pm); err != nil {
+	if err := json.Unmarshal(body, &pm); err != nil {
 		return nil, err
 	}
 
 	if err := validatePersistedModel(pm); err != nil {
 		return nil, err
 	}
+	return pm.result(), nil
+}
+
+func persistResult(result *TrainResult) persistedModel {
+	pm := persistedModel{
+		Version:        modelFormatVersion,
+		Lag:            result.Lag,
+		Scaler:         result.Scaler,
+		MSE:            result.MSE,
+		ResidualStdDev: result.ResidualStdDev,
+		Intermittent:   result.Intermittent,
+		Constraints:    result.Constraints,
+		TrainingStats:  result.Stats,
+	}
+	if m := result.Model; m != nil {
+		pm.W1, pm.B1, pm.W2, pm.B2 = m.W1, m.B1, m.W2, m.B2
+	}
+	return pm
+}
+
+// result expects pm to have passed validatePersistedModel.
+func (pm persistedModel) result() *TrainResult {
+	if pm.Intermittent != nil {
+		return &TrainResult{
+			Intermittent:   pm.Intermittent,
+			Constraints:    pm.Constraints,
+			Stats:          pm.TrainingStats,
+			Scaler:         pm.Scaler,
+			Lag:            pm.Lag,
+			MSE:            pm.MSE,
+			ResidualStdDev: pm.ResidualStdDev,
+		}
+	}
 
 	model := &MLP{
 		InputSize:  pm.Lag,
@@ -83,20 +113,58 @@ func LoadModel(path string) (*TrainResult, error) {
 
 	return &TrainResult{
 		Model:          model,
+		Constraints:    pm.Constraints,
+		Stats:          pm.TrainingStats,
 		Scaler:         pm.Scaler,
 		Lag:            pm.Lag,
 		MSE:            pm.MSE,
 		ResidualStdDev: pm.ResidualStdDev,
-	}, nil
+	}
+}
+
+// writeFileAtomic writes body to a temporary file next to path and renames
+// it into place, so readers such as a Registry never see a partial model.
+func writeFileAtomic(path string, body []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".oracle-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	// CreateTemp uses 0600; keep the permissions os.Create used to give.
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(body); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
 }
 
 func validatePersistedModel(pm persistedModel) error {
-	if pm.Version != modelFormatVersion {
+	if pm.Version < 1 || pm.Version > modelFormatVersion {
 		return fmt.Errorf("unsupported model version: %d", pm.Version)
 	}
 	if pm.Lag <= 0 {
 		return fmt.Errorf("invalid lag in model: %d", pm.Lag)
 	}
+	if err := pm.Constraints.Validate(); err != nil {
+		return fmt.Errorf("invalid constraints in model: %w", err)
+	}
+	if err := pm.TrainingStats.validate(); err != nil {
+		return fmt.Errorf("invalid training stats in model: %w", err)
+	}
+	if pm.Intermittent != nil {
+		if len(pm.W1) > 0 || len(pm.B1) > 0 || len(pm.W2) > 0 {
+			return fmt.Errorf("model has both network weights and intermittent parameters")
+		}
+		return pm.Intermittent.validate()
+	}
 	if len(pm.W1) == 0 || len(pm.B1) == 0 || len(pm.W2) == 0 {
 		return fmt.Errorf("empty model parameters")
 	}
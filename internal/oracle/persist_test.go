@@ -1,6 +1,7 @@
 package oracle
 
 import (
+	"fmt"
 	"math"
 	"os"
 	"path/filepath"
@@ -68,6 +69,27 @@ func TestSaveModelRejectsNil(t *testing.T) {
 	}
 }
 
+func TestLoadModelVersions(t *testing.T) {
+	dir := t.TempDir()
+	load := func(version int) error {
+		path := filepath.Join(dir, "model.json")
+		body := fmt.Sprintf(`{"version":%d,"lag":2,"scaler":{"Mean":0,"Std":1},"w1":[[0.5,0.5]],"b1":[0],"w2":[1],"b2":0}`, version)
+		if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+		_, err := LoadModel(path)
+		return err
+	}
+	for _, version := range []int{1, modelFormatVersion} {
+		if err := load(version); err != nil {
+			t.Fatalf("LoadModel version %d failed: %v", version, err)
+		}
+	}
+	if err := load(modelFormatVersion + 1); err == nil {
+		t.Fatalf("expected LoadModel error for a newer version")
+	}
+}
+
 func TestLoadModelRejectsInvalidParameters(t *testing.T) {
 	path := filepath.Join(t.TempDir(), "bad_model.json")
 	body := `{"version":1,"lag":3,"scaler":{"Mean":0,"Std":1},"w1":[[1,2,3],[1,2]],"b1":[0,0],"w2":[0,0],"b2":0}`
@@ -0,0 +1,463 @@
+package oracle
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+)
+
+// Reconciliation methods.
+const (
+	ReconcileBottomUp = "bottomup"
+	ReconcileTopDown  = "topdown"
+	ReconcileOLS      = "ols"
+	ReconcileWLS      = "wls"
+	ReconcileMinT     = "mint"
+	// ReconcileStructural weights each series by the number of bottom
+	// series it sums, needing no residuals; the usual choice for temporal
+	// hierarchies.
+	ReconcileStructural = "structural"
+)
+
+// Hierarchy is a tree of series where every parent is the sum of its
+// children. Nodes lists all series top-down (breadth first, children in file
+// order) and Leaves the bottom level in the same order.
+type Hierarchy struct {
+	Nodes  []string
+	Leaves []string
+
+	// S is the summing matrix: row i maps the leaves onto Nodes[i].
+	S [][]float64
+
+	children map[string][]string
+	depth    map[string]int
+}
+
+// NewHierarchy builds a hierarchy from parent/child pairs. There must be
+// exactly one root and every node has at most one parent.
+func NewHierarchy(edges [][2]string) (*Hierarchy, error) {
+	if len(edges) == 0 {
+		return nil, fmt.Errorf("hierarchy has no edges")
+	}
+	parent := map[string]string{}
+	children := map[string][]string{}
+	var seen []string
+	known := map[string]bool{}
+	for _, e := range edges {
+		p, c := e[0], e[1]
+		if p == "" || c == "" {
+			return nil, fmt.Errorf("empty node name in edge %q,%q", p, c)
+		}
+		if p == c {
+			return nil, fmt.Errorf("node %q is its own parent", p)
+		}
+		if prev, ok := parent[c]; ok {
+			return nil, fmt.Errorf("node %q has two parents: %q and %q", c, prev, p)
+		}
+		parent[c] = p
+		children[p] = append(children[p], c)
+		for _, n := range []string{p, c} {
+			if !known[n] {
+				known[n] = true
+				seen = append(seen, n)
+			}
+		}
+	}
+
+	var roots []string
+	for _, n := range seen {
+		if _, ok := parent[n]; !ok {
+			roots = append(roots, n)
+		}
+	}
+	if len(roots) != 1 {
+		return nil, fmt.Errorf("hierarchy needs exactly one root, found %d (%s)", len(roots), strings.Join(roots, ", "))
+	}
+
+	h := &Hierarchy{children: children, depth: map[string]int{roots[0]: 0}}
+	queue := []string{roots[0]}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		h.Nodes = append(h.Nodes, n)
+		for _, c := range children[n] {
+			h.depth[c] = h.depth[n] + 1
+			queue = append(queue, c)
+		}
+		if len(children[n]) == 0 {
+			h.Leaves = append(h.Leaves, n)
+		}
+	}
+	if len(h.Nodes) != len(seen) {
+		return nil, fmt.Errorf("hierarchy contains a cycle")
+	}
+
+	leafIndex := map[string]int{}
+	for i, l := range h.Leaves {
+		leafIndex[l] = i
+	}
+	h.S = make([][]float64, len(h.Nodes))
+	for i, n := range h.Nodes {
+		h.S[i] = make([]float64, len(h.Leaves))
+		for _, l := range h.leavesUnder(n) {
+			h.S[i][leafIndex[l]] = 1
+		}
+	}
+	return h, nil
+}
+
+// LoadHierarchy reads "parent,child" lines. Blank lines, # comments and a
+// "parent,child" header are skipped.
+func LoadHierarchy(path string) (*Hierarchy, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	var edges [][2]string
+	scanner := bufio.NewScanner(file)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.Split(text, ",")
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: want parent,child", path, line)
+		}
+		p, c := strings.TrimSpace(fields[0]), strings.TrimSpace(fields[1])
+		if len(edges) == 0 && strings.EqualFold(p, "parent") && strings.EqualFold(c, "child") {
+			continue
+		}
+		edges = append(edges, [2]string{p, c})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("scan %s: %w", path, err)
+	}
+
+	h, err := NewHierarchy(edges)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return h, nil
+}
+
+// Depth is the distance of node from the root, which has depth 0.
+func (h *Hierarchy) Depth(node string) int {
+	return h.depth[node]
+}
+
+func (h *Hierarchy) leavesUnder(node string) []string {
+	if len(h.children[node]) == 0 {
+		return []string{node}
+	}
+	var out []string
+	for _, c := range h.children[node] {
+		out = append(out, h.leavesUnder(c)...)
+	}
+	return out
+}
+
+// Aggregate returns one series per node in Nodes order. Nodes present in
+// series are used as given; missing parents are the sum of their children,
+// which must then all have the same length. A failed series makes every
+// aggregate above it fail too. Series outside the hierarchy are an error.
+func (h *Hierarchy) Aggregate(series []NamedSeries) ([]NamedSeries, error) {
+	given := map[string]NamedSeries{}
+	for _, s := range series {
+		if _, ok := h.depth[s.ID]; !ok {
+			return nil, fmt.Errorf("series %q is not in the hierarchy", s.ID)
+		}
+		given[s.ID] = s
+	}
+
+	built := map[string]NamedSeries{}
+	var build func(node string) NamedSeries
+	build = func(node string) NamedSeries {
+		if s, ok := built[node]; ok {
+			return s
+		}
+		s, ok := given[node]
+		if !ok {
+			s = NamedSeries{ID: node}
+			kids := h.children[node]
+			if len(kids) == 0 {
+				s.Err = fmt.Errorf("no data for series %q", node)
+			}
+			for _, c := range kids {
+				child := build(c)
+				switch {
+				case s.Err != nil:
+				case child.Err != nil:
+					s.Err = fmt.Errorf("child %q failed", c)
+				case s.Values == nil:
+					s.Values = append([]float64(nil), child.Values...)
+				case len(child.Values) != len(s.Values):
+					s.Err = fmt.Errorf("children have different lengths (%q has %d points, expected %d)", c, len(child.Values), len(s.Values))
+				default:
+					for i, v := range child.Values {
+						s.Values[i] += v
+					}
+				}
+			}
+			if s.Err != nil {
+				s.Values = nil
+			}
+		}
+		built[node] = s
+		return s
+	}
+
+	out := make([]NamedSeries, len(h.Nodes))
+	for i, n := range h.Nodes {
+		out[i] = build(n)
+	}
+	return out, nil
+}
+
+// ReconcileConfig selects the reconciliation method. History (for top-down)
+// and Residuals (in-sample one-step errors, for wls and mint) are indexed
+// like Hierarchy.Nodes; only their common tail is used.
+type ReconcileConfig struct {
+	Method    string
+	History   [][]float64
+	Residuals [][]float64
+}
+
+// Reconcile turns base forecasts of every node (rows in Nodes order, one
+// column per step) into coherent forecasts.
+func (h *Hierarchy) Reconcile(base [][]float64, cfg ReconcileConfig) ([][]float64, error) {
+	return reconcile(h.S, base, cfg)
+}
+
+// reconcile projects base forecasts onto the coherent subspace spanned by
+// the summing matrix S, i.e. returns S·G·base for the method's G. The first
+// row of S is taken to be the overall total for top-down.
+func reconcile(S, base [][]float64, cfg ReconcileConfig) ([][]float64, error) {
+	n := len(S)
+	if n == 0 {
+		return nil, fmt.Errorf("empty summing matrix")
+	}
+	m := len(S[0])
+	if len(base) != n {
+		return nil, fmt.Errorf("got %d base forecasts for %d series", len(base), n)
+	}
+	steps := len(base[0])
+	for i, row := range base {
+		if len(row) != steps {
+			return nil, fmt.Errorf("base forecast %d has %d steps, want %d", i, len(row), steps)
+		}
+	}
+
+	var G [][]float64
+	switch strings.ToLower(strings.TrimSpace(cfg.Method)) {
+	case ReconcileBottomUp:
+		G = make([][]float64, m)
+		for j := range G {
+			G[j] = make([]float64, n)
+			for i := n - 1; i >= 0; i-- {
+				if isUnitRow(S[i], j) {
+					G[j][i] = 1
+					break
+				}
+			}
+		}
+	case ReconcileTopDown:
+		proportions, err := historicalProportions(S, cfg.History)
+		if err != nil {
+			return nil, err
+		}
+		G = make([][]float64, m)
+		for j := range G {
+			G[j] = make([]float64, n)
+			G[j][0] = proportions[j]
+		}
+	case ReconcileOLS, ReconcileWLS, ReconcileMinT, ReconcileStructural:
+		W, err := reconcileWeights(strings.ToLower(strings.TrimSpace(cfg.Method)), S, cfg.Residuals)
+		if err != nil {
+			return nil, err
+		}
+		// G = (S' W⁻¹ S)⁻¹ S' W⁻¹, using W⁻¹S = X from W·X = S.
+		X, err := solve(W, S)
+		if err != nil {
+			return nil, fmt.Errorf("weight matrix: %w", err)
+		}
+		G, err = solve(matMul(transpose(S), X), transpose(X))
+		if err != nil {
+			return nil, fmt.Errorf("reconciliation matrix: %w", err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown reconciliation method %q (use bottomup, topdown, ols, wls, structural or mint)", cfg.Method)
+	}
+
+	return matMul(S, matMul(G, base)), nil
+}
+
+// isUnitRow reports whether row selects exactly bottom series j.
+func isUnitRow(row []float64, j int) bool {
+	for k, v := range row {
+		want := 0.0
+		if k == j {
+			want = 1
+		}
+		if v != want {
+			return false
+		}
+	}
+	return true
+}
+
+// historicalProportions returns each bottom series' share of the total as
+// the ratio of their sums over the common history.
+func historicalProportions(S [][]float64, history [][]float64) ([]float64, error) {
+	if len(history) != len(S) {
+		return nil, fmt.Errorf("top-down needs the history of every series")
+	}
+	m := len(S[0])
+	bottom := make([]int, m)
+	for j := range bottom {
+		bottom[j] = -1
+		for i := len(S) - 1; i >= 0; i-- {
+			if isUnitRow(S[i], j) {
+				bottom[j] = i
+				break
+			}
+		}
+	}
+
+	length := len(history[0])
+	for _, i := range bottom {
+		length = min(length, len(history[i]))
+	}
+	if length == 0 {
+		return nil, fmt.Errorf("top-down needs a non-empty history")
+	}
+	total := 0.0
+	for _, v := range history[0][len(history[0])-length:] {
+		total += v
+	}
+	if math.Abs(total) < 1e-12 {
+		return nil, fmt.Errorf("top-down proportions are undefined: the total sums to zero")
+	}
+
+	proportions := make([]float64, m)
+	for j, i := range bottom {
+		sum := 0.0
+		for _, v := range history[i][len(history[i])-length:] {
+			sum += v
+		}
+		proportions[j] = sum / total
+	}
+	return proportions, nil
+}
+
+// reconcileWeights returns the error covariance W: the identity for ols,
+// the row sums of S for structural, the diagonal of residual variances for
+// wls, and for mint the sample
+// covariance shrunk towards that diagonal (Schäfer & Strimmer).
+func reconcileWeights(method string, S, residuals [][]float64) ([][]float64, error) {
+	n := len(S)
+	W := make([][]float64, n)
+	for i := range W {
+		W[i] = make([]float64, n)
+	}
+	if method == ReconcileOLS {
+		for i := range W {
+			W[i][i] = 1
+		}
+		return W, nil
+	}
+	if method == ReconcileStructural {
+		for i, row := range S {
+			for _, v := range row {
+				W[i][i] += v
+			}
+		}
+		return W, nil
+	}
+
+	if len(residuals) != n {
+		return nil, fmt.Errorf("%s needs the residuals of every series", method)
+	}
+	T := len(residuals[0])
+	for _, r := range residuals {
+		T = min(T, len(r))
+	}
+	if T < 2 {
+		return nil, fmt.Errorf("%s needs at least 2 residuals per series", method)
+	}
+	e := make([][]float64, n)
+	for i, r := range residuals {
+		e[i] = r[len(r)-T:]
+	}
+
+	cov := make([][]float64, n)
+	for i := range cov {
+		cov[i] = make([]float64, n)
+		for j := 0; j <= i; j++ {
+			sum := 0.0
+			for t := 0; t < T; t++ {
+				sum += e[i][t] * e[j][t]
+			}
+			cov[i][j] = sum / float64(T)
+			cov[j][i] = cov[i][j]
+		}
+	}
+	for i := range cov {
+		if cov[i][i] <= 0 {
+			return nil, fmt.Errorf("%s: series %d has zero residual variance", method, i)
+		}
+	}
+
+	if method == ReconcileWLS {
+		for i := range W {
+			W[i][i] = cov[i][i]
+		}
+		return W, nil
+	}
+
+	// Shrinkage intensity: estimated variance of the off-diagonal sample
+	// correlations over their squared sum, clipped to [0, 1].
+	xs := make([][]float64, n)
+	for i := range xs {
+		sd := math.Sqrt(cov[i][i])
+		xs[i] = make([]float64, T)
+		for t := range xs[i] {
+			xs[i][t] = e[i][t] / sd
+		}
+	}
+	num, den := 0.0, 0.0
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			if i == j {
+				continue
+			}
+			sumSq, sum := 0.0, 0.0
+			for t := 0; t < T; t++ {
+				w := xs[i][t] * xs[j][t]
+				sumSq += w * w
+				sum += w
+			}
+			num += (sumSq - sum*sum/float64(T)) / float64(T*(T-1))
+			r := cov[i][j] / math.Sqrt(cov[i][i]*cov[j][j])
+			den += r * r
+		}
+	}
+	lambda := 1.0
+	if den > 0 {
+		lambda = math.Max(0, math.Min(1, num/den))
+	}
+
+	for i := range W {
+		for j := range W[i] {
+			W[i][j] = (1 - lambda) * cov[i][j]
+		}
+		W[i][i] = cov[i][i]
+	}
+	return W, nil
+}
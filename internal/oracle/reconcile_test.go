@@ -0,0 +1,172 @@
+package oracle
+
+import (
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func testHierarchy(t *testing.T) *Hierarchy {
+	t.Helper()
+	h, err := NewHierarchy([][2]string{
+		{"total", "A"}, {"total", "B"},
+		{"A", "a1"}, {"A", "a2"},
+		{"B", "b1"},
+	})
+	if err != nil {
+		t.Fatalf("NewHierarchy failed: %v", err)
+	}
+	return h
+}
+
+func assertCoherent(t *testing.T, name string, y [][]float64) {
+	t.Helper()
+	// total, A, B, a1, a2, b1
+	for step := range y[0] {
+		checks := [][2]float64{
+			{y[0][step], y[1][step] + y[2][step]},
+			{y[1][step], y[3][step] + y[4][step]},
+			{y[2][step], y[5][step]},
+		}
+		for _, c := range checks {
+			if math.Abs(c[0]-c[1]) > 1e-9 {
+				t.Fatalf("%s: step %d is not coherent: %v", name, step, y)
+			}
+		}
+	}
+}
+
+func TestNewHierarchy(t *testing.T) {
+	h := testHierarchy(t)
+	if !reflect.DeepEqual(h.Nodes, []string{"total", "A", "B", "a1", "a2", "b1"}) {
+		t.Fatalf("Nodes = %v", h.Nodes)
+	}
+	if !reflect.DeepEqual(h.Leaves, []string{"a1", "a2", "b1"}) {
+		t.Fatalf("Leaves = %v", h.Leaves)
+	}
+	want := [][]float64{{1, 1, 1}, {1, 1, 0}, {0, 0, 1}, {1, 0, 0}, {0, 1, 0}, {0, 0, 1}}
+	if !reflect.DeepEqual(h.S, want) {
+		t.Fatalf("S = %v", h.S)
+	}
+	if h.Depth("a2") != 2 || h.Depth("total") != 0 {
+		t.Fatalf("unexpected depths")
+	}
+
+	for _, edges := range [][][2]string{
+		{{"a", "b"}, {"c", "d"}},
+		{{"a", "b"}, {"c", "b"}},
+		{{"a", "b"}, {"b", "a"}},
+		{{"a", "a"}},
+	} {
+		if _, err := NewHierarchy(edges); err == nil {
+			t.Fatalf("expected an error for %v", edges)
+		}
+	}
+}
+
+func TestHierarchyAggregate(t *testing.T) {
+	h := testHierarchy(t)
+	series, err := h.Aggregate([]NamedSeries{
+		{ID: "a1", Values: []float64{1, 2}},
+		{ID: "a2", Values: []float64{10, 20}},
+		{ID: "b1", Values: []float64{100, 200}},
+	})
+	if err != nil {
+		t.Fatalf("Aggregate failed: %v", err)
+	}
+	if !reflect.DeepEqual(series[0].Values, []float64{111, 222}) || !reflect.DeepEqual(series[1].Values, []float64{11, 22}) {
+		t.Fatalf("unexpected aggregates: %+v", series)
+	}
+
+	series, err = h.Aggregate([]NamedSeries{
+		{ID: "a1", Values: []float64{1, 2}},
+		{ID: "a2", Values: []float64{10, 20, 30}},
+		{ID: "b1", Values: []float64{100, 200}},
+	})
+	if err != nil {
+		t.Fatalf("Aggregate failed: %v", err)
+	}
+	if series[1].Err == nil || series[0].Err == nil || series[2].Err != nil {
+		t.Fatalf("length mismatch should fail A and total only: %+v", series)
+	}
+
+	if _, err := h.Aggregate([]NamedSeries{{ID: "zzz"}}); err == nil {
+		t.Fatalf("expected an error for a series outside the hierarchy")
+	}
+}
+
+func TestReconcileMethodsAreCoherent(t *testing.T) {
+	h := testHierarchy(t)
+	base := [][]float64{
+		{120, 125},
+		{14, 15},
+		{100, 104},
+		{3, 3.5},
+		{10, 11},
+		{99, 103},
+	}
+	history := [][]float64{{110, 120}, {10, 14}, {100, 106}, {2, 4}, {8, 10}, {100, 106}}
+	rnd := rand.New(rand.NewSource(1))
+	residuals := make([][]float64, 6)
+	for t := 0; t < 40; t++ {
+		leaf := []float64{rnd.NormFloat64(), rnd.NormFloat64() * 2, rnd.NormFloat64() * 5}
+		agg := []float64{leaf[0] + leaf[1] + leaf[2], leaf[0] + leaf[1], leaf[2]}
+		for i, v := range append(agg, leaf...) {
+			residuals[i] = append(residuals[i], v+0.3*rnd.NormFloat64())
+		}
+	}
+
+	for _, method := range []string{ReconcileBottomUp, ReconcileTopDown, ReconcileOLS, ReconcileWLS, ReconcileMinT} {
+		got, err := h.Reconcile(base, ReconcileConfig{Method: method, History: history, Residuals: residuals})
+		if err != nil {
+			t.Fatalf("%s: Reconcile failed: %v", method, err)
+		}
+		assertCoherent(t, method, got)
+
+		switch method {
+		case ReconcileBottomUp:
+			if got[0][0] != 112 || got[3][0] != 3 {
+				t.Fatalf("bottom-up should keep the leaves: %v", got)
+			}
+		case ReconcileTopDown:
+			// a1 is 6/230 of the historical total.
+			if math.Abs(got[0][0]-120) > 1e-9 || math.Abs(got[3][0]-120*6.0/230) > 1e-9 {
+				t.Fatalf("top-down should split the total: %v", got)
+			}
+		}
+	}
+
+	coherent := [][]float64{{6}, {3}, {3}, {1}, {2}, {3}}
+	for _, method := range []string{ReconcileOLS, ReconcileWLS, ReconcileMinT} {
+		got, err := h.Reconcile(coherent, ReconcileConfig{Method: method, Residuals: residuals})
+		if err != nil {
+			t.Fatalf("%s: Reconcile failed: %v", method, err)
+		}
+		for i := range got {
+			if math.Abs(got[i][0]-coherent[i][0]) > 1e-9 {
+				t.Fatalf("%s changed coherent forecasts: %v", method, got)
+			}
+		}
+	}
+
+	if _, err := h.Reconcile(base, ReconcileConfig{Method: ReconcileMinT}); err == nil {
+		t.Fatalf("expected an error without residuals")
+	}
+	if _, err := h.Reconcile(base, ReconcileConfig{Method: "median"}); err == nil {
+		t.Fatalf("expected an error for an unknown method")
+	}
+}
+
+func TestSolve(t *testing.T) {
+	x, err := solve([][]float64{{0, 2}, {1, 1}}, [][]float64{{4}, {3}})
+	if err != nil {
+		t.Fatalf("solve failed: %v", err)
+	}
+	if math.Abs(x[0][0]-1) > 1e-12 || math.Abs(x[1][0]-2) > 1e-12 {
+		t.Fatalf("x = %v, want [1 2]", x)
+	}
+	if _, err := solve([][]float64{{1, 2}, {2, 4}}, [][]float64{{1}, {2}}); err == nil {
+		t.Fatalf("expected an error for a singular matrix")
+	}
+}
@@ -0,0 +1,238 @@
+package oracle
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ErrModelNotFound = errors.New("model not found")
+
+// ModelVersion is one persisted model file known to a Registry.
+type ModelVersion struct {
+	Name     string
+	Version  string
+	Path     string
+	Size     int64
+	ModTime  time.Time
+	LoadedAt time.Time
+	Checksum string
+	Result   *TrainResult
+}
+
+// Registry serves the models stored under a directory laid out as
+// <dir>/<name>/<version>.json. Refresh rescans the directory and swaps in a
+// new snapshot atomically, so readers never see a half-updated set.
+//
+// Versions are ordered numerically when both are integers and
+// lexicographically otherwise; the greatest one is the latest.
+type Registry struct {
+	dir  string
+	mu   sync.Mutex
+	snap atomic.Pointer[registrySnapshot]
+}
+
+type registrySnapshot struct {
+	models map[string][]*ModelVersion
+}
+
+// OpenRegistry scans dir once. Files that fail to load are skipped and
+// reported in the returned error together with the usable registry.
+func OpenRegistry(dir string) (*Registry, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+
+	r := &Registry{dir: dir}
+	r.snap.Store(&registrySnapshot{models: map[string][]*ModelVersion{}})
+	_, err = r.Refresh()
+	return r, err
+}
+
+// Refresh rescans the directory, reloading only files whose size or
+// modification time changed. A file that fails to load keeps its previously
+// loaded version, if any. It reports whether the snapshot changed.
+func (r *Registry) Refresh() (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	old := r.snap.Load()
+	previous := map[string]*ModelVersion{}
+	for _, versions := range old.models {
+		for _, v := range versions {
+			previous[v.Path] = v
+		}
+	}
+
+	entries, err := os.ReadDir(r.dir)
+	if err != nil {
+		return false, err
+	}
+
+	var errs []error
+	changed := false
+	next := map[string][]*ModelVersion{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+		files, err := filepath.Glob(filepath.Join(r.dir, name, "*.json"))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		for _, path := range files {
+			info, err := os.Stat(path)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+
+			prev := previous[path]
+			delete(previous, path)
+			if prev != nil && prev.Size == info.Size() && prev.ModTime.Equal(info.ModTime()) {
+				next[name] = append(next[name], prev)
+				continue
+			}
+
+			loaded, err := loadModelVersion(name, path, info)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", path, err))
+				if prev != nil {
+					next[name] = append(next[name], prev)
+				}
+				continue
+			}
+			next[name] = append(next[name], loaded)
+			changed = true
+		}
+	}
+	if len(previous) > 0 {
+		changed = true
+	}
+
+	for _, versions := range next {
+		sort.Slice(versions, func(i, j int) bool {
+			return versionLess(versions[i].Version, versions[j].Version)
+		})
+	}
+	if changed {
+		r.snap.Store(&registrySnapshot{models: next})
+	}
+	return changed, errors.Join(errs...)
+}
+
+// Watch polls the directory every interval until ctx is done. Errors are
+// passed to onError, which may be nil.
+func (r *Registry) Watch(ctx context.Context, interval time.Duration, onError func(error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := r.Refresh(); err != nil && onError != nil {
+				onError(err)
+			}
+		}
+	}
+}
+
+func (r *Registry) Names() []string {
+	snap := r.snap.Load()
+	names := make([]string, 0, len(snap.models))
+	for name := range snap.models {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Versions returns the loaded versions of name, oldest first.
+func (r *Registry) Versions(name string) []*ModelVersion {
+	return append([]*ModelVersion(nil), r.snap.Load().models[name]...)
+}
+
+// Get returns the requested version of name, or the latest one when version
+// is empty.
+func (r *Registry) Get(name, version string) (*ModelVersion, error) {
+	versions := r.snap.Load().models[name]
+	if len(versions) == 0 {
+		return nil, fmt.Errorf("%w: %q", ErrModelNotFound, name)
+	}
+	if version == "" {
+		return versions[len(versions)-1], nil
+	}
+	for _, v := range versions {
+		if v.Version == version {
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %q version %q", ErrModelNotFound, name, version)
+}
+
+func loadModelVersion(name, path string, info os.FileInfo) (*ModelVersion, error) {
+	body, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	result, err := decodeModel(body)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha256.Sum256(body)
+	return &ModelVersion{
+		Name:     name,
+		Version:  strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)),
+		Path:     path,
+		Size:     info.Size(),
+		ModTime:  info.ModTime(),
+		LoadedAt: time.Now(),
+		Checksum: hex.EncodeToString(sum[:]),
+		Result:   result,
+	}, nil
+}
+
+// NextVersionPath returns the path of the version after the greatest
+// integer version saved in dir, a <dir>/<name> directory of a registry:
+// <dir>/1.json when there is none yet.
+func NextVersionPath(dir string) (string, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return "", err
+	}
+	next := 1
+	for _, path := range files {
+		if v, err := strconv.Atoi(strings.TrimSuffix(filepath.Base(path), ".json")); err == nil && v >= next {
+			next = v + 1
+		}
+	}
+	return filepath.Join(dir, strconv.Itoa(next)+".json"), nil
+}
+
+func versionLess(a, b string) bool {
+	ai, aErr := strconv.Atoi(a)
+	bi, bErr := strconv.Atoi(b)
+	if aErr == nil && bErr == nil {
+		return ai < bi
+	}
+	return a < b
+}
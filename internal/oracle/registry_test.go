@@ -0,0 +1,98 @@
+package oracle
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRegistryLoadsVersionsAndReloads(t *testing.T) {
+	series := make([]float64, 0, 40)
+	for i := 0; i < 40; i++ {
+		series = append(series, 2+0.3*float64(i))
+	}
+	small, err := Train(series, TrainConfig{Lag: 4, Hidden: 5, Epochs: 100, Seed: 1})
+	if err != nil {
+		t.Fatalf("train failed: %v", err)
+	}
+	large, err := Train(series, TrainConfig{Lag: 6, Hidden: 7, Epochs: 100, Seed: 1})
+	if err != nil {
+		t.Fatalf("train failed: %v", err)
+	}
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "sales"), 0o755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	for _, v := range []string{"2", "10"} {
+		if err := SaveModel(filepath.Join(dir, "sales", v+".json"), small); err != nil {
+			t.Fatalf("SaveModel failed: %v", err)
+		}
+	}
+
+	reg, err := OpenRegistry(dir)
+	if err != nil {
+		t.Fatalf("OpenRegistry failed: %v", err)
+	}
+	latest, err := reg.Get("sales", "")
+	if err != nil {
+		t.Fatalf("Get latest failed: %v", err)
+	}
+	if latest.Version != "10" {
+		t.Fatalf("latest version = %q, want numeric ordering to pick 10", latest.Version)
+	}
+	if _, err := reg.Get("sales", "7"); !errors.Is(err, ErrModelNotFound) {
+		t.Fatalf("Get missing version error = %v, want ErrModelNotFound", err)
+	}
+
+	// Overwrite the pinned version and make sure the change is visible
+	// even when the file system has coarse timestamps.
+	path := filepath.Join(dir, "sales", "2.json")
+	if err := SaveModel(path, large); err != nil {
+		t.Fatalf("SaveModel failed: %v", err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatalf("Chtimes failed: %v", err)
+	}
+
+	changed, err := reg.Refresh()
+	if err != nil || !changed {
+		t.Fatalf("Refresh = (%v, %v), want change", changed, err)
+	}
+	pinned, err := reg.Get("sales", "2")
+	if err != nil {
+		t.Fatalf("Get pinned failed: %v", err)
+	}
+	if pinned.Result.Lag != 6 {
+		t.Fatalf("pinned lag = %d, want reloaded model with lag 6", pinned.Result.Lag)
+	}
+
+	// A broken file keeps serving the previous good version.
+	if err := os.WriteFile(path, []byte("{"), 0o644); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+	if _, err := reg.Refresh(); err == nil {
+		t.Fatalf("expected Refresh error for broken model")
+	}
+	if kept, _ := reg.Get("sales", "2"); kept == nil || kept.Result.Lag != 6 {
+		t.Fatalf("broken file replaced the previous version: %+v", kept)
+	}
+}
+
+func TestNextVersionPath(t *testing.T) {
+	dir := t.TempDir()
+	if got, err := NextVersionPath(dir); err != nil || got != filepath.Join(dir, "1.json") {
+		t.Fatalf("NextVersionPath(empty) = %q, %v, want 1.json", got, err)
+	}
+	for _, name := range []string{"2.json", "10.json", "latest.json", "11.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("{}"), 0o644); err != nil {
+			t.Fatalf("WriteFile failed: %v", err)
+		}
+	}
+	if got, err := NextVersionPath(dir); err != nil || got != filepath.Join(dir, "11.json") {
+		t.Fatalf("NextVersionPath = %q, %v, want 11.json", got, err)
+	}
+}
@@ -0,0 +1,153 @@
+package oracle
+
+import (
+	"math"
+	"sort"
+)
+
+func mean(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum / float64(len(values))
+}
+
+func stdDev(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	m := mean(values)
+	variance := 0.0
+	for _, v := range values {
+		d := v - m
+		variance += d * d
+	}
+	return math.Sqrt(variance / float64(len(values)))
+}
+
+func sortedCopy(values []float64) []float64 {
+	out := append([]float64(nil), values...)
+	sort.Float64s(out)
+	return out
+}
+
+func median(values []float64) float64 {
+	return quantile(sortedCopy(values), 0.5)
+}
+
+// quantile returns the q-th quantile of already sorted values using linear
+// interpolation between closest ranks.
+func quantile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	if q <= 0 {
+		return sorted[0]
+	}
+	if q >= 1 {
+		return sorted[len(sorted)-1]
+	}
+	pos := q * float64(len(sorted)-1)
+	lo := int(math.Floor(pos))
+	hi := int(math.Ceil(pos))
+	frac := pos - float64(lo)
+	return sorted[lo] + frac*(sorted[hi]-sorted[lo])
+}
+
+// mad returns the median absolute deviation scaled to be a consistent
+// estimator of the standard deviation for normally distributed data.
+func mad(values []float64, center float64) float64 {
+	dev := make([]float64, len(values))
+	for i, v := range values {
+		dev[i] = math.Abs(v - center)
+	}
+	return 1.4826 * median(dev)
+}
+
+// normalQuantile returns the inverse of the standard normal CDF using
+// Acklam's rational approximation (relative error below 1.2e-9).
+func normalQuantile(p float64) float64 {
+	if p <= 0 {
+		return math.Inf(-1)
+	}
+	if p >= 1 {
+		return math.Inf(1)
+	}
+
+	a := [...]float64{-3.969683028665376e+01, 2.209460984245205e+02, -2.759285104469687e+02, 1.383577518672690e+02, -3.066479806614716e+01, 2.506628277459239e+00}
+	b := [...]float64{-5.447609879822406e+01, 1.615858368580409e+02, -1.556989798598866e+02, 6.680131188771972e+01, -1.328068155288572e+01}
+	c := [...]float64{-7.784894002430293e-03, -3.223964580411365e-01, -2.400758277161838e+00, -2.549732539343734e+00, 4.374664141464968e+00, 2.938163982698783e+00}
+	d := [...]float64{7.784695709041462e-03, 3.224671290700398e-01, 2.445134137142996e+00, 3.754408661907416e+00}
+
+	const low = 0.02425
+	switch {
+	case p < low:
+		q := math.Sqrt(-2 * math.Log(p))
+		return (((((c[0]*q+c[1])*q+c[2])*q+c[3])*q+c[4])*q + c[5]) /
+			((((d[0]*q+d[1])*q+d[2])*q+d[3])*q + 1)
+	case p > 1-low:
+		q := math.Sqrt(-2 * math.Log(1-p))
+		return -(((((c[0]*q+c[1])*q+c[2])*q+c[3])*q+c[4])*q + c[5]) /
+			((((d[0]*q+d[1])*q+d[2])*q+d[3])*q + 1)
+	default:
+		q := p - 0.5
+		r := q * q
+		return (((((a[0]*r+a[1])*r+a[2])*r+a[3])*r+a[4])*r + a[5]) * q /
+			(((((b[0]*r+b[1])*r+b[2])*r+b[3])*r+b[4])*r + 1)
+	}
+}
+
+// chiSquareSurvival returns P(X > x) for a chi-square variable with df
+// degrees of freedom, the regularized upper incomplete gamma Q(df/2, x/2).
+func chiSquareSurvival(x float64, df int) float64 {
+	if x <= 0 || df <= 0 {
+		return 1
+	}
+	a, z := float64(df)/2, x/2
+	lg, _ := math.Lgamma(a)
+	prefix := math.Exp(a*math.Log(z) - z - lg)
+
+	if z < a+1 {
+		// Series for the lower function P, then Q = 1 - P.
+		term := 1 / a
+		sum := term
+		for n := 1; n < 500; n++ {
+			term *= z / (a + float64(n))
+			sum += term
+			if math.Abs(term) < math.Abs(sum)*1e-15 {
+				break
+			}
+		}
+		return math.Max(0, 1-prefix*sum)
+	}
+
+	// Continued fraction for Q (modified Lentz).
+	const tiny = 1e-300
+	b := z + 1 - a
+	c := 1 / tiny
+	d := 1 / b
+	h := d
+	for n := 1; n < 500; n++ {
+		an := -float64(n) * (float64(n) - a)
+		b += 2
+		d = an*d + b
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = b + an/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		delta := d * c
+		h *= delta
+		if math.Abs(delta-1) < 1e-15 {
+			break
+		}
+	}
+	return prefix * h
+}
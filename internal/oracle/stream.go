@@ -0,0 +1,148 @@
+package oracle
+
+import (
+	"fmt"
+	"math"
+)
+
+// StreamConfig sets the two-sided coverage of the normal prediction
+// interval around each prediction (0.95 when unset).
+type StreamConfig struct {
+	Interval float64
+}
+
+// StreamPrediction is the one-step-ahead prediction of the value at Index.
+type StreamPrediction struct {
+	Index     int
+	Predicted float64
+	Low       float64
+	High      float64
+}
+
+// StreamMetrics are running error metrics over every observed point. Bias
+// is the mean of predicted minus actual, as in ValidationMetrics, and
+// Coverage the share of points that fell inside their interval.
+type StreamMetrics struct {
+	Count    int
+	MAE      float64
+	RMSE     float64
+	Bias     float64
+	Coverage float64
+}
+
+// StreamStep is the result of observing one value: the prediction that had
+// been made for it, its residual, and the prediction for the next value.
+type StreamStep struct {
+	StreamPrediction
+	Actual   float64
+	Residual float64
+	Covered  bool
+	Next     StreamPrediction
+	Metrics  StreamMetrics
+}
+
+// StreamPredictor keeps the last Lag observations and predicts the next
+// value after each one it is given. Its memory does not grow with the
+// number of observed values.
+type StreamPredictor struct {
+	result  *TrainResult
+	stepper stepper
+	z       float64
+	pending StreamPrediction
+
+	count   int
+	covered int
+	sumAbs  float64
+	sumSq   float64
+	sumBias float64
+}
+
+// NewStreamPredictor starts a predictor after history, which must hold at
+// least Lag points; only the last Lag are kept.
+func NewStreamPredictor(result *TrainResult, history []float64, cfg StreamConfig) (*StreamPredictor, error) {
+	if !result.valid() {
+		return nil, fmt.Errorf("invalid train result")
+	}
+	if len(history) < result.Lag {
+		return nil, fmt.Errorf("history shorter than lag")
+	}
+	interval := cfg.Interval
+	if interval <= 0 {
+		interval = 0.95
+	}
+	if interval >= 1 {
+		return nil, fmt.Errorf("interval must be below 1: %v", interval)
+	}
+
+	p := &StreamPredictor{
+		result:  result,
+		stepper: result.newStepper(history),
+		z:       normalQuantile(0.5 + interval/2),
+	}
+	if err := p.predict(len(history)); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Next returns the prediction for the value the next Observe call scores.
+func (p *StreamPredictor) Next() StreamPrediction {
+	return p.pending
+}
+
+// Observe scores actual against the pending prediction, updates the running
+// metrics and predicts the value after it.
+func (p *StreamPredictor) Observe(actual float64) (StreamStep, error) {
+	if math.IsNaN(actual) || math.IsInf(actual, 0) {
+		return StreamStep{}, fmt.Errorf("invalid value: %v", actual)
+	}
+	step := StreamStep{
+		StreamPrediction: p.pending,
+		Actual:           actual,
+		Residual:         actual - p.pending.Predicted,
+		Covered:          actual >= p.pending.Low && actual <= p.pending.High,
+	}
+
+	p.count++
+	p.sumAbs += math.Abs(step.Residual)
+	p.sumSq += step.Residual * step.Residual
+	p.sumBias -= step.Residual
+	if step.Covered {
+		p.covered++
+	}
+
+	p.stepper.observe(actual)
+	if err := p.predict(step.Index + 1); err != nil {
+		return StreamStep{}, err
+	}
+	step.Next = p.pending
+	step.Metrics = p.Metrics()
+	return step, nil
+}
+
+// Metrics returns the running metrics; they are all zero before the first
+// observation.
+func (p *StreamPredictor) Metrics() StreamMetrics {
+	if p.count == 0 {
+		return StreamMetrics{}
+	}
+	n := float64(p.count)
+	return StreamMetrics{
+		Count:    p.count,
+		MAE:      p.sumAbs / n,
+		RMSE:     math.Sqrt(p.sumSq / n),
+		Bias:     p.sumBias / n,
+		Coverage: float64(p.covered) / n,
+	}
+}
+
+func (p *StreamPredictor) predict(index int) error {
+	predicted, err := p.stepper.next()
+	if err != nil {
+		return err
+	}
+	delta := p.z * p.result.ResidualStdDev
+	low, high := p.result.Constraints.Interval(predicted-delta, predicted+delta)
+	p.pending = StreamPrediction{Index: index, Predicted: predicted, Low: low, High: high}
+	return nil
+}
@@ -0,0 +1,52 @@
+package oracle
+
+import (
+	"math"
+	"testing"
+)
+
+func TestStreamPredictorMatchesValidate(t *testing.T) {
+	series := make([]float64, 0, 50)
+	for i := 0; i < 50; i++ {
+		series = append(series, 20+3*math.Sin(float64(i)/2))
+	}
+	result, err := Train(series[:40], TrainConfig{Lag: 5, Hidden: 6, Epochs: 300, Seed: 3})
+	if err != nil {
+		t.Fatalf("train failed: %v", err)
+	}
+	want, err := Validate(result, series, 10)
+	if err != nil {
+		t.Fatalf("validate failed: %v", err)
+	}
+
+	p, err := NewStreamPredictor(result, series[:40], StreamConfig{})
+	if err != nil {
+		t.Fatalf("NewStreamPredictor failed: %v", err)
+	}
+	if next := p.Next(); next.Index != 40 || next.High-next.Predicted != next.Predicted-next.Low {
+		t.Fatalf("first prediction %+v", next)
+	}
+	var step StreamStep
+	for i := 40; i < 50; i++ {
+		if step, err = p.Observe(series[i]); err != nil {
+			t.Fatalf("Observe failed: %v", err)
+		}
+		if step.Index != i || step.Next.Index != i+1 {
+			t.Fatalf("step %d scored index %d, next %d", i, step.Index, step.Next.Index)
+		}
+	}
+	got := step.Metrics
+	if got.Count != 10 || math.Abs(got.MAE-want.MAE) > 1e-9 || math.Abs(got.RMSE-want.RMSE) > 1e-9 || math.Abs(got.Bias-want.Bias) > 1e-9 {
+		t.Fatalf("running metrics %+v, validation %+v", got, want)
+	}
+	if got.Coverage < 0 || got.Coverage > 1 || got != p.Metrics() {
+		t.Fatalf("coverage %v, metrics %+v", got.Coverage, p.Metrics())
+	}
+
+	if _, err := p.Observe(math.NaN()); err == nil {
+		t.Fatalf("expected an error for NaN")
+	}
+	if _, err := NewStreamPredictor(result, series[:3], StreamConfig{}); err == nil {
+		t.Fatalf("expected an error for a history shorter than lag")
+	}
+}
@@ -0,0 +1,200 @@
+package oracle
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// TemporalConfig describes a temporal hierarchy forecast. Levels are
+// aggregation factors in base periods (e.g. 1, 7, 28 for daily data); each
+// must divide the largest one, and 1 is always included. Steps is the
+// horizon in periods of the largest level.
+type TemporalConfig struct {
+	Levels []int
+	Steps  int
+	Method string
+	Train  TrainConfig
+}
+
+type TemporalLevel struct {
+	Aggregation int
+	// Series is the aggregated history the level's model was trained on.
+	Series     []float64
+	Result     *TrainResult
+	Base       []float64
+	Reconciled []float64
+}
+
+type TemporalForecast struct {
+	// Levels are ordered from the largest aggregation down to 1.
+	Levels []TemporalLevel
+	// Offset is the number of leading points dropped so that every
+	// aggregated period ends at the last observation.
+	Offset int
+}
+
+// AggregateSeries sums consecutive blocks of k points, ending with the last
+// point; a partial block at the start is dropped.
+func AggregateSeries(series []float64, k int) []float64 {
+	if k <= 0 {
+		return nil
+	}
+	start := len(series) % k
+	out := make([]float64, 0, len(series)/k)
+	for i := start; i+k <= len(series); i += k {
+		sum := 0.0
+		for _, v := range series[i : i+k] {
+			sum += v
+		}
+		out = append(out, sum)
+	}
+	return out
+}
+
+// ForecastTemporal trains one model per aggregation level, forecasts each
+// over the same horizon and reconciles the forecasts so every level adds up
+// to the ones above it. A level whose history is too short for Train.Lag
+// uses half its length as lag instead.
+func ForecastTemporal(series []float64, cfg TemporalConfig) (*TemporalForecast, error) {
+	return ForecastTemporalContext(context.Background(), series, cfg)
+}
+
+// ForecastTemporalContext is ForecastTemporal with ctx passed to the
+// training and forecast of every level.
+func ForecastTemporalContext(ctx context.Context, series []float64, cfg TemporalConfig) (*TemporalForecast, error) {
+	levels, err := temporalLevels(cfg.Levels)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Steps <= 0 {
+		return nil, fmt.Errorf("steps must be positive")
+	}
+	if cfg.Train.Constraints != nil {
+		return nil, fmt.Errorf("constraints are not supported: reconciled forecasts would no longer satisfy them")
+	}
+	top := levels[0]
+	blocks := len(series) / top
+	out := &TemporalForecast{Offset: len(series) % top}
+	aligned := series[out.Offset:]
+
+	train := cfg.Train.withDefaults()
+	S, rows := temporalSumming(levels)
+	base := make([][]float64, len(rows))
+	history := make([][]float64, len(rows))
+	residuals := make([][]float64, len(rows))
+	for li, k := range levels {
+		agg := AggregateSeries(aligned, k)
+		if len(agg) < 6 {
+			return nil, fmt.Errorf("level %d has only %d periods (%d blocks of %d); need at least 6", k, len(agg), blocks, top)
+		}
+		levelCfg := train
+		levelCfg.Lag = min(train.Lag, len(agg)/2)
+		result, err := TrainContext(ctx, agg, levelCfg)
+		if err != nil {
+			return nil, fmt.Errorf("level %d: training failed: %w", k, err)
+		}
+		perBlock := top / k
+		predictions, err := ForecastContext(ctx, result, agg, cfg.Steps*perBlock)
+		if err != nil {
+			return nil, fmt.Errorf("level %d: forecast failed: %w", k, err)
+		}
+		res, err := InSampleResiduals(result, agg)
+		if err != nil {
+			return nil, fmt.Errorf("level %d: %w", k, err)
+		}
+
+		// Row r of the hierarchy is position j within a block of this level;
+		// its history, residuals and forecasts are every perBlock-th value.
+		for r, row := range rows {
+			if row.level != li {
+				continue
+			}
+			for b := 0; b < cfg.Steps; b++ {
+				base[r] = append(base[r], predictions[b*perBlock+row.pos])
+			}
+			for i := row.pos; i < len(agg); i += perBlock {
+				history[r] = append(history[r], agg[i])
+				if i >= result.Lag {
+					residuals[r] = append(residuals[r], res[i-result.Lag])
+				}
+			}
+		}
+		out.Levels = append(out.Levels, TemporalLevel{
+			Aggregation: k,
+			Series:      agg,
+			Result:      result,
+			Base:        predictions,
+		})
+	}
+
+	reconciled, err := reconcile(S, base, ReconcileConfig{Method: cfg.Method, History: history, Residuals: residuals})
+	if err != nil {
+		return nil, err
+	}
+	for li := range out.Levels {
+		perBlock := top / levels[li]
+		level := &out.Levels[li]
+		level.Reconciled = make([]float64, len(level.Base))
+		for r, row := range rows {
+			if row.level != li {
+				continue
+			}
+			for b := 0; b < cfg.Steps; b++ {
+				level.Reconciled[b*perBlock+row.pos] = reconciled[r][b]
+			}
+		}
+	}
+	return out, nil
+}
+
+// temporalLevels sorts the factors from largest to smallest, adds 1 and
+// checks that each divides the largest.
+func temporalLevels(levels []int) ([]int, error) {
+	seen := map[int]bool{1: true}
+	out := []int{1}
+	for _, k := range levels {
+		if k <= 0 {
+			return nil, fmt.Errorf("invalid aggregation level %d", k)
+		}
+		if !seen[k] {
+			seen[k] = true
+			out = append(out, k)
+		}
+	}
+	if len(out) < 2 {
+		return nil, fmt.Errorf("need at least one aggregation level above 1")
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(out)))
+	for _, k := range out[1:] {
+		if out[0]%k != 0 {
+			return nil, fmt.Errorf("aggregation level %d does not divide %d", k, out[0])
+		}
+	}
+	return out, nil
+}
+
+type temporalRow struct {
+	level int
+	pos   int
+}
+
+// temporalSumming returns the summing matrix of one block of the largest
+// level, with rows ordered by level (largest first) and position, and the
+// base periods as columns.
+func temporalSumming(levels []int) ([][]float64, []temporalRow) {
+	top := levels[0]
+	var S [][]float64
+	var rows []temporalRow
+	for li, k := range levels {
+		for pos := 0; pos < top/k; pos++ {
+			row := make([]float64, top)
+			for c := pos * k; c < (pos+1)*k; c++ {
+				row[c] = 1
+			}
+			S = append(S, row)
+			rows = append(rows, temporalRow{level: li, pos: pos})
+		}
+	}
+	return S, rows
+}
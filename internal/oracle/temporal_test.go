@@ -0,0 +1,68 @@
+package oracle
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestAggregateSeries(t *testing.T) {
+	got := AggregateSeries([]float64{9, 1, 2, 3, 4, 5, 6}, 3)
+	if !reflect.DeepEqual(got, []float64{6, 15}) {
+		t.Fatalf("AggregateSeries = %v", got)
+	}
+	if got := AggregateSeries([]float64{1, 2}, 3); len(got) != 0 {
+		t.Fatalf("expected no full period, got %v", got)
+	}
+}
+
+func TestTemporalLevels(t *testing.T) {
+	levels, err := temporalLevels([]int{2, 4, 4})
+	if err != nil || !reflect.DeepEqual(levels, []int{4, 2, 1}) {
+		t.Fatalf("temporalLevels = %v, %v", levels, err)
+	}
+	for _, bad := range [][]int{nil, {1}, {0, 4}, {3, 4}} {
+		if _, err := temporalLevels(bad); err == nil {
+			t.Fatalf("expected error for levels %v", bad)
+		}
+	}
+}
+
+func TestForecastTemporalIsCoherent(t *testing.T) {
+	series := make([]float64, 50)
+	for i := range series {
+		series[i] = 10 + 0.3*float64(i) + 2*math.Sin(float64(i))
+	}
+	for _, method := range []string{ReconcileBottomUp, ReconcileTopDown, ReconcileOLS, ReconcileWLS, ReconcileStructural, ReconcileMinT} {
+		out, err := ForecastTemporal(series, TemporalConfig{
+			Levels: []int{2, 4},
+			Steps:  2,
+			Method: method,
+			Train:  TrainConfig{Lag: 4, Hidden: 4, Epochs: 80, Seed: 1},
+		})
+		if err != nil {
+			t.Fatalf("%s: ForecastTemporal failed: %v", method, err)
+		}
+		if out.Offset != 2 || len(out.Levels) != 3 {
+			t.Fatalf("%s: offset %d, %d levels", method, out.Offset, len(out.Levels))
+		}
+		top, mid, base := out.Levels[0].Reconciled, out.Levels[1].Reconciled, out.Levels[2].Reconciled
+		if len(top) != 2 || len(mid) != 4 || len(base) != 8 {
+			t.Fatalf("%s: unexpected horizons %d, %d, %d", method, len(top), len(mid), len(base))
+		}
+		for i, v := range mid {
+			if math.Abs(v-(base[2*i]+base[2*i+1])) > 1e-9 {
+				t.Fatalf("%s: level 2 step %d is not coherent", method, i+1)
+			}
+		}
+		for i, v := range top {
+			if math.Abs(v-(mid[2*i]+mid[2*i+1])) > 1e-9 {
+				t.Fatalf("%s: level 4 step %d is not coherent", method, i+1)
+			}
+		}
+	}
+
+	if _, err := ForecastTemporal(series[:20], TemporalConfig{Levels: []int{4}, Steps: 1, Method: ReconcileOLS}); err == nil {
+		t.Fatalf("expected error for a level with too few periods")
+	}
+}
@@ -0,0 +1,123 @@
+package oracle
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+)
+
+// UpdateConfig tunes Update. Epochs (50 when unset) and LearningRate (0.008
+// when unset) apply to the fine-tuning of the new points only.
+//
+// RefitScaler refits the Standardizer on the whole series instead of
+// keeping the one the model was trained with. The network weights are then
+// re-expressed for the new scale before fine-tuning: with the old scaler
+// (m, s) and the new one (m', s'), the first layer becomes W1·s'/s with
+// B1 + W1·(m'-m)/s summed over the inputs, and the output layer W2·s/s' with
+// (B2·s + m - m')/s'. The network therefore makes exactly the same
+// predictions as before until fine-tuning changes them.
+type UpdateConfig struct {
+	Epochs       int
+	LearningRate float64
+	Seed         int64
+	RefitScaler  bool
+}
+
+// Update fine-tunes a trained model on the last newPoints values of series,
+// which holds the data the model was trained on followed by the new
+// observations. Only the windows predicting the new points are trained on;
+// MSE and ResidualStdDev are then recomputed over the whole series.
+// Intermittent-demand models have no network and are refit on series, which
+// is as fast. The returned result is a new model; result is not modified.
+func Update(result *TrainResult, series []float64, newPoints int, cfg UpdateConfig) (*TrainResult, error) {
+	return UpdateContext(context.Background(), result, series, newPoints, cfg)
+}
+
+// UpdateContext is Update that checks ctx before every epoch and stops with
+// a *CanceledError once it is done.
+func UpdateContext(ctx context.Context, result *TrainResult, series []float64, newPoints int, cfg UpdateConfig) (*TrainResult, error) {
+	if err := checkContext(ctx, "update"); err != nil {
+		return nil, err
+	}
+	if !result.valid() {
+		return nil, fmt.Errorf("invalid train result")
+	}
+	if newPoints <= 0 {
+		return nil, fmt.Errorf("new points must be positive, got %d", newPoints)
+	}
+	if newPoints > len(series)-result.Lag {
+		return nil, fmt.Errorf("%d new points need %d earlier values for lag %d, but the series has %d points", newPoints, result.Lag, result.Lag, len(series))
+	}
+	if m := result.Intermittent; m != nil {
+		updated, err := trainIntermittent(series, m.Method)
+		if err != nil {
+			return nil, err
+		}
+		updated.Constraints = result.Constraints
+		if err := updated.recordStats(series); err != nil {
+			return nil, err
+		}
+		return updated, nil
+	}
+	if cfg.Epochs <= 0 {
+		cfg.Epochs = 50
+	}
+	if cfg.LearningRate <= 0 {
+		cfg.LearningRate = 0.008
+	}
+
+	model := result.Model.clone()
+	scaler := result.Scaler
+	if cfg.RefitScaler {
+		scaler.Fit(series)
+		model.rescale(result.Scaler, scaler)
+	}
+	x, y := makeWindows(scaler.TransformSlice(series), result.Lag)
+	fresh := len(x) - newPoints
+
+	rnd := rand.New(rand.NewSource(cfg.Seed))
+	order := make([]int, newPoints)
+	for i := range order {
+		order[i] = fresh + i
+	}
+	history := make([]float64, 0, cfg.Epochs)
+	for epoch := 0; epoch < cfg.Epochs; epoch++ {
+		if err := ctx.Err(); err != nil {
+			return nil, &CanceledError{Op: "update", Epoch: epoch, Epochs: cfg.Epochs, Err: err}
+		}
+		rnd.Shuffle(len(order), func(i, j int) {
+			order[i], order[j] = order[j], order[i]
+		})
+		sum := 0.0
+		for _, idx := range order {
+			loss, err := model.step(x[idx], y[idx], cfg.LearningRate, nil)
+			if err != nil {
+				return nil, err
+			}
+			sum += loss
+		}
+		history = append(history, sum/float64(len(order)))
+	}
+
+	return finishTraining(model, scaler, series, x, TrainConfig{Lag: result.Lag, Constraints: result.Constraints}, history)
+}
+
+// rescale re-expresses the weights of a network trained on from-scaled
+// values for to-scaled ones without changing its predictions; see
+// UpdateConfig.
+func (m *MLP) rescale(from, to Standardizer) {
+	ratio := to.Std / from.Std
+	shift := (to.Mean - from.Mean) / from.Std
+	for j, row := range m.W1 {
+		sum := 0.0
+		for i := range row {
+			sum += row[i]
+			row[i] *= ratio
+		}
+		m.B1[j] += sum * shift
+	}
+	for j := range m.W2 {
+		m.W2[j] /= ratio
+	}
+	m.B2 = (m.B2*from.Std + from.Mean - to.Mean) / to.Std
+}
@@ -0,0 +1,84 @@
+package oracle
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestUpdateFineTunesOnNewPoints(t *testing.T) {
+	series := make([]float64, 0, 80)
+	for i := 0; i < 60; i++ {
+		series = append(series, 10+2*math.Sin(float64(i)/3))
+	}
+	base, err := Train(series, TrainConfig{Lag: 6, Hidden: 8, Epochs: 400, Seed: 2})
+	if err != nil {
+		t.Fatalf("train failed: %v", err)
+	}
+	weights := base.Model.clone()
+
+	// The new observations continue the pattern on a higher level.
+	for i := 60; i < 80; i++ {
+		series = append(series, 16+2*math.Sin(float64(i)/3))
+	}
+	before, err := Forecast(base, series, 1)
+	if err != nil {
+		t.Fatalf("forecast failed: %v", err)
+	}
+	for _, refit := range []bool{false, true} {
+		updated, err := Update(base, series, 20, UpdateConfig{Epochs: 200, Seed: 1, RefitScaler: refit})
+		if err != nil {
+			t.Fatalf("update (refit %v) failed: %v", refit, err)
+		}
+		if !reflect.DeepEqual(base.Model, weights) {
+			t.Fatalf("update modified the original model")
+		}
+		if updated.Epochs != 200 || len(updated.LossHistory) != 200 || updated.Lag != base.Lag {
+			t.Fatalf("updated model ran %d epochs with lag %d", updated.Epochs, updated.Lag)
+		}
+		if (updated.Scaler == base.Scaler) == refit {
+			t.Fatalf("refit %v: scaler %+v, original %+v", refit, updated.Scaler, base.Scaler)
+		}
+		after, err := Forecast(updated, series, 1)
+		if err != nil {
+			t.Fatalf("forecast failed: %v", err)
+		}
+		want := 16 + 2*math.Sin(80.0/3)
+		if math.Abs(after[0]-want) >= math.Abs(before[0]-want) {
+			t.Fatalf("refit %v: updated forecast %.3f is no closer to %.3f than %.3f", refit, after[0], want, before[0])
+		}
+		if updated.ResidualStdDev == base.ResidualStdDev || updated.ResidualStdDev <= 0 {
+			t.Fatalf("residual std dev was not recomputed: %v", updated.ResidualStdDev)
+		}
+	}
+
+	if _, err := Update(base, series, 0, UpdateConfig{}); err == nil {
+		t.Fatalf("expected an error without new points")
+	}
+	if _, err := Update(base, series, 75, UpdateConfig{}); err == nil {
+		t.Fatalf("expected an error for more new points than windows")
+	}
+}
+
+func TestRescaleKeepsPredictions(t *testing.T) {
+	series := make([]float64, 40)
+	for i := range series {
+		series[i] = 100 + float64(i%5)*3
+	}
+	result, err := Train(series, TrainConfig{Lag: 5, Hidden: 4, Epochs: 100, Seed: 1})
+	if err != nil {
+		t.Fatalf("train failed: %v", err)
+	}
+	rescaled := *result
+	rescaled.Model = result.Model.clone()
+	rescaled.Scaler = Standardizer{Mean: 80, Std: 12}
+	rescaled.Model.rescale(result.Scaler, rescaled.Scaler)
+
+	want, _ := Forecast(result, series, 5)
+	got, _ := Forecast(&rescaled, series, 5)
+	for i := range want {
+		if math.Abs(got[i]-want[i]) > 1e-9 {
+			t.Fatalf("step %d: rescaled forecast %.12f, want %.12f", i+1, got[i], want[i])
+		}
+	}
+}
@@ -1,14 +1,17 @@
 package main
 
 import (
+	"context"
 	"encoding/csv"
-	"encoding/json"
-	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"syscall"
 
 	"oracle/internal/oracle"
 )
@@ -20,210 +23,190 @@ type ForecastPoint struct {
 	High95     float64 `json:"high_95"`
 }
 
+// ValidationPayload is written with only the metrics selected by -metrics
+// (all when none are); see validationMetrics.
 type ValidationPayload struct {
-	Count int     `json:"count"`
-	MAE   float64 `json:"mae"`
-	RMSE  float64 `json:"rmse"`
-	MAPE  float64 `json:"mape"`
+	Count        int     `json:"count"`
+	MAE          float64 `json:"mae"`
+	RMSE         float64 `json:"rmse"`
+	MAPE         float64 `json:"mape"`
+	MAPEExcluded int     `json:"mape_excluded"`
+	SMAPE        float64 `json:"smape"`
+	MASE         float64 `json:"mase"`
+	RMSSE        float64 `json:"rmsse"`
+	SPL          float64 `json:"spl"`
+	R2           float64 `json:"r2"`
+	Bias         float64 `json:"bias"`
+	ZeroCount    int     `json:"zero_count"`
+	ZeroMAE      float64 `json:"zero_mae"`
+	NonzeroMAE   float64 `json:"nonzero_mae"`
+
+	metrics []string
 }
 
-type OutputPayload struct {
-	DataPoints      int                `json:"data_points"`
-	Lag             int                `json:"lag"`
-	TrainingMSE     float64            `json:"training_mse"`
-	ResidualStdDev  float64            `json:"residual_std_dev"`
-	LastObserved    float64            `json:"last_observed"`
-	ModelLoadedFrom string             `json:"model_loaded_from,omitempty"`
-	ModelSavedTo    string             `json:"model_saved_to,omitempty"`
-	Validation      *ValidationPayload `json:"validation,omitempty"`
-	Forecast        []ForecastPoint    `json:"forecast"`
-	ForecastCSVPath string             `json:"forecast_csv_path,omitempty"`
+type OutlierPayload struct {
+	Method    string    `json:"method"`
+	Action    string    `json:"action"`
+	Threshold float64   `json:"threshold"`
+	Indices   []int     `json:"indices"`
+	Original  []float64 `json:"original"`
+	Cleaned   []float64 `json:"cleaned"`
 }
 
-func main() {
-	var (
-		dataPath      string
-		outPath       string
-		outputFormat  string
-		saveModelPath string
-		loadModelPath string
-		steps         int
-		lag           int
-		hidden        int
-		epochs        int
-		holdout       int
-		seed          int64
-		lr            float64
-	)
+type ChangepointPayload struct {
+	Method      string  `json:"method"`
+	Cost        string  `json:"cost"`
+	Penalty     float64 `json:"penalty,omitempty"`
+	Indices     []int   `json:"indices"`
+	TrainedFrom int     `json:"trained_from"`
+}
 
-	flag.StringVar(&dataPath, "data", "data/sample.csv", "path to time-series data file")
-	flag.StringVar(&outPath, "out", "", "optional CSV output path for forecasts")
-	flag.StringVar(&outputFormat, "format", "text", "output format: text or json")
-	flag.StringVar(&saveModelPath, "save-model", "", "optional path to save trained model JSON")
-	flag.StringVar(&loadModelPath, "load-model", "", "optional path to load model JSON and skip training")
-	flag.IntVar(&steps, "steps", 5, "number of future points to predict")
-	flag.IntVar(&lag, "lag", 6, "number of past points used for one prediction")
-	flag.IntVar(&hidden, "hidden", 12, "hidden layer size")
-	flag.IntVar(&epochs, "epochs", 1800, "training epochs")
-	flag.IntVar(&holdout, "holdout", 0, "number of tail points for one-step holdout validation (0 disables)")
-	flag.Float64Var(&lr, "lr", 0.008, "learning rate")
-	flag.Int64Var(&seed, "seed", 42, "random seed")
-	flag.Parse()
+type OutputPayload struct {
+	DataPoints      int                 `json:"data_points"`
+	Lag             int                 `json:"lag"`
+	TrainingMSE     float64             `json:"training_mse"`
+	ResidualStdDev  float64             `json:"residual_std_dev"`
+	LastObserved    float64             `json:"last_observed"`
+	ModelLoadedFrom string              `json:"model_loaded_from,omitempty"`
+	ModelSavedTo    string              `json:"model_saved_to,omitempty"`
+	Outliers        *OutlierPayload     `json:"outliers,omitempty"`
+	Changepoints    *ChangepointPayload `json:"changepoints,omitempty"`
+	Validation      *ValidationPayload  `json:"validation,omitempty"`
+	Constraints     *oracle.Constraints `json:"constraints,omitempty"`
+	Drift           *DriftPayload       `json:"drift,omitempty"`
+	Forecast        []ForecastPoint     `json:"forecast"`
+	ForecastCSVPath string              `json:"forecast_csv_path,omitempty"`
+	ReportPath      string              `json:"report_path,omitempty"`
+	ChartPath       string              `json:"chart_path,omitempty"`
+}
 
-	outputFormat = strings.ToLower(strings.TrimSpace(outputFormat))
-	if outputFormat != "text" && outputFormat != "json" {
-		log.Fatalf("invalid -format: %q (use text or json)", outputFormat)
+func main() {
+	// The first SIGINT or SIGTERM cancels the run; once it has, a second
+	// one kills the process the default way.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+	err := runCLI(ctx, os.Args[1:])
+	stop()
+	switch code := exitCode(err); code {
+	case 0:
+	case 2:
+		// The flag package has already printed the error and usage.
+		os.Exit(code)
+	default:
+		log.Print(err)
+		os.Exit(code)
 	}
+}
 
-	series, err := oracle.LoadSeriesFromFile(dataPath)
-	if err != nil {
-		log.Fatalf("failed to load data: %v", err)
+func saveModelIfRequested(path string, result *oracle.TrainResult) error {
+	if path == "" {
+		return nil
 	}
-
-	cfg := oracle.TrainConfig{
-		Lag:          lag,
-		Hidden:       hidden,
-		Epochs:       epochs,
-		LearningRate: lr,
-		Seed:         seed,
+	if err := oracle.SaveModel(path, result); err != nil {
+		return fmt.Errorf("saving model failed: %w", err)
 	}
+	return nil
+}
 
-	var (
-		result      *oracle.TrainResult
-		validation  *oracle.ValidationMetrics
-		modelLoaded string
-		modelSaved  string
-	)
-
-	if loadModelPath != "" {
-		result, err = oracle.LoadModel(loadModelPath)
-		if err != nil {
-			log.Fatalf("loading model failed: %v", err)
-		}
-		modelLoaded = loadModelPath
-
-		if holdout > 0 {
-			metrics, validateErr := oracle.Validate(result, series, holdout)
-			if validateErr != nil {
-				log.Fatalf("validation failed: %v", validateErr)
-			}
-			validation = &metrics
-		}
-	} else {
-		trainSeries := series
-		if holdout > 0 {
-			if holdout >= len(series) {
-				log.Fatalf("invalid -holdout: %d (must be smaller than data length %d)", holdout, len(series))
-			}
-			trainSeries = series[:len(series)-holdout]
-		}
-
-		result, err = oracle.Train(trainSeries, cfg)
-		if err != nil {
-			log.Fatalf("training failed: %v", err)
-		}
-
-		if holdout > 0 {
-			metrics, validateErr := oracle.Validate(result, series, holdout)
-			if validateErr != nil {
-				log.Fatalf("validation failed: %v", validateErr)
-			}
-			validation = &metrics
+// hiddenSize is zero for the intermittent-demand models, which have no
+// network.
+func hiddenSize(result *oracle.TrainResult) int {
+	if result.Model == nil {
+		return 0
+	}
+	return result.Model.HiddenSize
+}
 
-			// Retrain on full data so future forecasts use all observed points.
-			result, err = oracle.Train(series, cfg)
-			if err != nil {
-				log.Fatalf("full-data retraining failed: %v", err)
-			}
+// cliModelName labels CLI metrics after the model file when there is one.
+func cliModelName(loadPath, savePath string) string {
+	for _, p := range []string{loadPath, savePath} {
+		if p != "" {
+			return strings.TrimSuffix(filepath.Base(p), filepath.Ext(p))
 		}
 	}
+	return "cli"
+}
 
-	if saveModelPath != "" {
-		if err := oracle.SaveModel(saveModelPath, result); err != nil {
-			log.Fatalf("saving model failed: %v", err)
+func printForecastText(w io.Writer, payload OutputPayload) {
+	fmt.Fprintln(w, "Oracle - Future Forecast")
+	fmt.Fprintf(w, "Data points      : %d\n", payload.DataPoints)
+	fmt.Fprintf(w, "Lag              : %d\n", payload.Lag)
+	fmt.Fprintf(w, "Training MSE     : %.6f\n", payload.TrainingMSE)
+	fmt.Fprintf(w, "Residual Std Dev : %.6f\n", payload.ResidualStdDev)
+	fmt.Fprintf(w, "Last observed    : %.4f\n", payload.LastObserved)
+	if payload.ModelLoadedFrom != "" {
+		fmt.Fprintf(w, "Model loaded     : %s\n", payload.ModelLoadedFrom)
+	}
+	if payload.ModelSavedTo != "" {
+		fmt.Fprintf(w, "Model saved      : %s\n", payload.ModelSavedTo)
+	}
+	if o := payload.Outliers; o != nil {
+		fmt.Fprintf(w, "Outliers         : %d flagged (%s, %s) %v\n", len(o.Indices), o.Method, o.Action, o.Indices)
+	}
+	if c := payload.Changepoints; c != nil {
+		fmt.Fprintf(w, "Changepoints     : %d (%s, %s) %v\n", len(c.Indices), c.Method, c.Cost, c.Indices)
+		if c.TrainedFrom > 0 {
+			fmt.Fprintf(w, "Trained from     : index %d\n", c.TrainedFrom)
 		}
-		modelSaved = saveModelPath
 	}
-
-	predictions, err := oracle.Forecast(result, series, steps)
-	if err != nil {
-		log.Fatalf("forecast failed: %v", err)
+	if c := payload.Constraints; c != nil {
+		fmt.Fprintf(w, "Constraints      : %s\n", describeConstraints(c))
 	}
+	printDriftText(w, payload.Drift)
+	printValidationText(w, payload.Validation)
+	fmt.Fprintln(w)
 
-	points := buildForecastPoints(predictions, result.ResidualStdDev)
-
-	if outPath != "" {
-		if err := writeForecastCSV(outPath, points); err != nil {
-			log.Fatalf("failed writing forecast CSV: %v", err)
-		}
+	for _, p := range payload.Forecast {
+		fmt.Fprintf(w, "t+%d -> %.4f  (95%% range: %.4f .. %.4f)\n", p.Step, p.Prediction, p.Low95, p.High95)
 	}
-
-	if outputFormat == "json" {
-		payload := OutputPayload{
-			DataPoints:      len(series),
-			Lag:             result.Lag,
-			TrainingMSE:     result.MSE,
-			ResidualStdDev:  result.ResidualStdDev,
-			LastObserved:    series[len(series)-1],
-			ModelLoadedFrom: modelLoaded,
-			ModelSavedTo:    modelSaved,
-			Forecast:        points,
-			ForecastCSVPath: outPath,
+	if payload.ForecastCSVPath != "" {
+		fmt.Fprintf(w, "\nSaved forecast CSV: %s\n", payload.ForecastCSVPath)
+	}
+	if payload.ReportPath != "" || payload.ChartPath != "" {
+		if payload.ForecastCSVPath == "" {
+			fmt.Fprintln(w)
 		}
-		if validation != nil {
-			payload.Validation = &ValidationPayload{
-				Count: validation.Count,
-				MAE:   validation.MAE,
-				RMSE:  validation.RMSE,
-				MAPE:  validation.MAPE,
-			}
+		if payload.ReportPath != "" {
+			fmt.Fprintf(w, "Saved report: %s\n", payload.ReportPath)
 		}
-		body, marshalErr := json.MarshalIndent(payload, "", "  ")
-		if marshalErr != nil {
-			log.Fatalf("failed to encode json output: %v", marshalErr)
+		if payload.ChartPath != "" {
+			fmt.Fprintf(w, "Saved chart: %s\n", payload.ChartPath)
 		}
-		fmt.Println(string(body))
-		return
 	}
+}
 
-	fmt.Println("Oracle - Future Forecast")
-	fmt.Printf("Data points      : %d\n", len(series))
-	fmt.Printf("Lag              : %d\n", result.Lag)
-	fmt.Printf("Training MSE     : %.6f\n", result.MSE)
-	fmt.Printf("Residual Std Dev : %.6f\n", result.ResidualStdDev)
-	fmt.Printf("Last observed    : %.4f\n", series[len(series)-1])
-	if modelLoaded != "" {
-		fmt.Printf("Model loaded     : %s\n", modelLoaded)
+func describeConstraints(c *oracle.Constraints) string {
+	var parts []string
+	if c.Lower != nil {
+		parts = append(parts, fmt.Sprintf("lower %g", *c.Lower))
 	}
-	if modelSaved != "" {
-		fmt.Printf("Model saved      : %s\n", modelSaved)
+	if c.Upper != nil {
+		parts = append(parts, fmt.Sprintf("upper %g", *c.Upper))
 	}
-	if validation != nil {
-		fmt.Println()
-		fmt.Printf("Holdout points   : %d\n", validation.Count)
-		fmt.Printf("Validation MAE   : %.6f\n", validation.MAE)
-		fmt.Printf("Validation RMSE  : %.6f\n", validation.RMSE)
-		fmt.Printf("Validation MAPE  : %.4f%%\n", validation.MAPE)
+	if c.Integer {
+		parts = append(parts, "integer")
 	}
-	fmt.Println()
-
-	for _, p := range points {
-		fmt.Printf("t+%d -> %.4f  (95%% range: %.4f .. %.4f)\n", p.Step, p.Prediction, p.Low95, p.High95)
-	}
-	if outPath != "" {
-		fmt.Printf("\nSaved forecast CSV: %s\n", outPath)
+	if c.Total != nil {
+		parts = append(parts, fmt.Sprintf("total %g", *c.Total))
 	}
+	return strings.Join(parts, ", ")
 }
 
-func buildForecastPoints(predictions []float64, residualStdDev float64) []ForecastPoint {
+// buildForecastPoints adds the 95% interval around each prediction, bounded
+// and rounded by the model's constraints (nil for none).
+func buildForecastPoints(predictions []float64, residualStdDev float64, constraints *oracle.Constraints) []ForecastPoint {
 	points := make([]ForecastPoint, 0, len(predictions))
 	delta := 1.96 * residualStdDev
 	for i, p := range predictions {
+		low, high := constraints.Interval(p-delta, p+delta)
 		points = append(points, ForecastPoint{
 			Step:       i + 1,
 			Prediction: p,
-			Low95:      p - delta,
-			High95:     p + delta,
+			Low95:      low,
+			High95:     high,
 		})
 	}
 	return points
@@ -256,3 +239,17 @@ func writeForecastCSV(path string, points []ForecastPoint) error {
 	w.Flush()
 	return w.Error()
 }
+
+func nonNilInts(values []int) []int {
+	if values == nil {
+		return []int{}
+	}
+	return values
+}
+
+func nonNilFloats(values []float64) []float64 {
+	if values == nil {
+		return []float64{}
+	}
+	return values
+}
@@ -8,7 +8,7 @@ import (
 )
 
 func TestBuildForecastPoints(t *testing.T) {
-	points := buildForecastPoints([]float64{10, 11.5}, 0.5)
+	points := buildForecastPoints([]float64{10, 11.5}, 0.5, nil)
 	if len(points) != 2 {
 		t.Fatalf("len(points) = %d, want 2", len(points))
 	}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	latencyBuckets  = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30}
+	trainingBuckets = []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+)
+
+// metrics is a small Prometheus-compatible collector. Series are keyed by
+// their rendered label set, which keeps the text exposition trivial to write
+// without a client library.
+type metrics struct {
+	mu         sync.Mutex
+	counters   map[string]map[string]float64
+	gauges     map[string]map[string]float64
+	histograms map[string]map[string]*histogram
+}
+
+type histogram struct {
+	bounds []float64
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+type metricInfo struct {
+	kind string
+	help string
+}
+
+var metricCatalog = map[string]metricInfo{
+	"oracle_http_requests_total":           {"counter", "HTTP requests by endpoint, model and status code."},
+	"oracle_http_request_duration_seconds": {"histogram", "HTTP request latency by endpoint and model."},
+	"oracle_training_duration_seconds":     {"histogram", "Wall time spent in training, per model."},
+	"oracle_training_epochs_total":         {"counter", "Training epochs completed, per model."},
+	"oracle_model_training_mse":            {"gauge", "In-sample MSE of the latest trained or loaded model."},
+	"oracle_model_residual_std_dev":        {"gauge", "Residual standard deviation of the latest trained or loaded model."},
+	"oracle_validation_mae":                {"gauge", "Latest holdout validation MAE."},
+	"oracle_validation_rmse":               {"gauge", "Latest holdout validation RMSE."},
+	"oracle_validation_mape":               {"gauge", "Latest holdout validation MAPE in percent."},
+	"oracle_validation_mase":               {"gauge", "Latest holdout validation MASE."},
+	"oracle_validation_spl":                {"gauge", "Latest holdout validation scaled pinball loss."},
+}
+
+func newMetrics() *metrics {
+	return &metrics{
+		counters:   map[string]map[string]float64{},
+		gauges:     map[string]map[string]float64{},
+		histograms: map[string]map[string]*histogram{},
+	}
+}
+
+func (m *metrics) add(name string, value float64, labels ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.counters[name] == nil {
+		m.counters[name] = map[string]float64{}
+	}
+	m.counters[name][renderLabels(labels)] += value
+}
+
+func (m *metrics) set(name string, value float64, labels ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.gauges[name] == nil {
+		m.gauges[name] = map[string]float64{}
+	}
+	m.gauges[name][renderLabels(labels)] = value
+}
+
+func (m *metrics) observe(name string, bounds []float64, value float64, labels ...string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.histograms[name] == nil {
+		m.histograms[name] = map[string]*histogram{}
+	}
+	key := renderLabels(labels)
+	h := m.histograms[name][key]
+	if h == nil {
+		h = &histogram{bounds: bounds, counts: make([]uint64, len(bounds))}
+		m.histograms[name][key] = h
+	}
+	for i, b := range h.bounds {
+		if value <= b {
+			h.counts[i]++
+		}
+	}
+	h.sum += value
+	h.count++
+}
+
+func (m *metrics) observeRequest(endpoint, model string, status int, elapsed time.Duration) {
+	m.add("oracle_http_requests_total", 1, "endpoint", endpoint, "model", model, "code", strconv.Itoa(status))
+	m.observe("oracle_http_request_duration_seconds", latencyBuckets, elapsed.Seconds(), "endpoint", endpoint, "model", model)
+}
+
+// observeRun records training and validation results of one forecast run.
+func (m *metrics) observeRun(model string, run *forecastRun) {
+	if run.Training != (trainingStats{}) {
+		m.observe("oracle_training_duration_seconds", trainingBuckets, run.Training.Duration.Seconds(), "model", model)
+		m.add("oracle_training_epochs_total", float64(run.Training.Epochs), "model", model)
+		m.set("oracle_model_training_mse", run.Result.MSE, "model", model)
+		m.set("oracle_model_residual_std_dev", run.Result.ResidualStdDev, "model", model)
+	}
+	if v := run.Validation; v != nil {
+		m.set("oracle_validation_mae", v.MAE, "model", model)
+		m.set("oracle_validation_rmse", v.RMSE, "model", model)
+		m.set("oracle_validation_mape", v.MAPE, "model", model)
+		m.set("oracle_validation_mase", v.MASE, "model", model)
+		m.set("oracle_validation_spl", v.SPL, "model", model)
+	}
+}
+
+// writeTo renders every metric in the Prometheus text exposition format,
+// sorted by name and label set so the output is stable.
+func (m *metrics) writeTo(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	names := make([]string, 0, len(metricCatalog))
+	for name := range metricCatalog {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		info := metricCatalog[name]
+		var keys []string
+		switch info.kind {
+		case "counter":
+			keys = sortedKeys(m.counters[name])
+		case "gauge":
+			keys = sortedKeys(m.gauges[name])
+		case "histogram":
+			keys = sortedKeys(m.histograms[name])
+		}
+		if len(keys) == 0 {
+			continue
+		}
+
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s %s\n", name, info.help, name, info.kind)
+		for _, key := range keys {
+			switch info.kind {
+			case "counter":
+				fmt.Fprintf(&b, "%s%s %s\n", name, key, formatValue(m.counters[name][key]))
+			case "gauge":
+				fmt.Fprintf(&b, "%s%s %s\n", name, key, formatValue(m.gauges[name][key]))
+			case "histogram":
+				h := m.histograms[name][key]
+				for i, bound := range h.bounds {
+					fmt.Fprintf(&b, "%s_bucket%s %d\n", name, withLabel(key, "le", formatValue(bound)), h.counts[i])
+				}
+				fmt.Fprintf(&b, "%s_bucket%s %d\n", name, withLabel(key, "le", "+Inf"), h.count)
+				fmt.Fprintf(&b, "%s_sum%s %s\n", name, key, formatValue(h.sum))
+				fmt.Fprintf(&b, "%s_count%s %d\n", name, key, h.count)
+			}
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeFile dumps the metrics to path, e.g. for the node_exporter textfile
+// collector after a one-shot CLI run.
+func (m *metrics) writeFile(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := m.writeTo(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// renderLabels turns alternating name/value pairs into {a="x",b="y"}.
+func renderLabels(pairs []string) string {
+	if len(pairs) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(pairs)/2)
+	for i := 0; i+1 < len(pairs); i += 2 {
+		parts = append(parts, pairs[i]+`="`+labelEscaper.Replace(pairs[i+1])+`"`)
+	}
+	return "{" + strings.Join(parts, ",") + "}"
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func withLabel(key, name, value string) string {
+	extra := name + `="` + labelEscaper.Replace(value) + `"`
+	if key == "" {
+		return "{" + extra + "}"
+	}
+	return key[:len(key)-1] + "," + extra + "}"
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"oracle/internal/oracle"
+)
+
+func TestMetricsExposition(t *testing.T) {
+	m := newMetrics()
+	m.add("oracle_training_epochs_total", 10, "model", `we"ird\name`)
+	m.observe("oracle_http_request_duration_seconds", []float64{0.1, 1}, 0.5, "endpoint", "forecast", "model", "a")
+
+	var b strings.Builder
+	if err := m.writeTo(&b); err != nil {
+		t.Fatalf("writeTo failed: %v", err)
+	}
+	text := b.String()
+
+	for _, want := range []string{
+		"# TYPE oracle_training_epochs_total counter\n",
+		`oracle_training_epochs_total{model="we\"ird\\name"} 10` + "\n",
+		`oracle_http_request_duration_seconds_bucket{endpoint="forecast",model="a",le="0.1"} 0` + "\n",
+		`oracle_http_request_duration_seconds_bucket{endpoint="forecast",model="a",le="1"} 1` + "\n",
+		`oracle_http_request_duration_seconds_bucket{endpoint="forecast",model="a",le="+Inf"} 1` + "\n",
+		`oracle_http_request_duration_seconds_count{endpoint="forecast",model="a"} 1` + "\n",
+	} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("missing %q in:\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, "oracle_validation_mae") {
+		t.Fatalf("unused metrics should not be written:\n%s", text)
+	}
+}
+
+func TestServerMetricsEndpoint(t *testing.T) {
+	srv, err := newServer(testServeConfig(), forecastOptions{Steps: 2, Train: oracle.TrainConfig{Lag: 3, Hidden: 4, Epochs: 50, Seed: 1}})
+	if err != nil {
+		t.Fatalf("newServer failed: %v", err)
+	}
+	handler := srv.routes()
+
+	body, _ := json.Marshal(ForecastRequest{Series: linearSeries(20), Holdout: 3})
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/forecast", strings.NewReader(string(body))))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("forecast: status = %d, body = %s", rec.Code, rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	text := rec.Body.String()
+	for _, want := range []string{
+		`oracle_http_requests_total{endpoint="forecast",model="adhoc",code="200"} 1`,
+		`oracle_training_epochs_total{model="adhoc"} 100`,
+		`oracle_validation_rmse{model="adhoc"}`,
+		`oracle_training_duration_seconds_count{model="adhoc"} 1`,
+	} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("missing %q in:\n%s", want, text)
+		}
+	}
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"oracle/internal/oracle"
+)
+
+// forecastOptions describes one forecast run independently of whether the
+// settings came from command-line flags or an HTTP request.
+type forecastOptions struct {
+	Steps           int
+	Holdout         int
+	Train           oracle.TrainConfig
+	Outliers        *oracle.OutlierConfig
+	Changepoints    *oracle.ChangepointConfig
+	TrainAfterBreak bool
+
+	// Season is the seasonal naive lag that scales MASE and RMSSE, and
+	// Metrics the validation metrics to report (all when empty).
+	Season  int
+	Metrics []string
+
+	// SkipRefit keeps the model trained without the holdout tail instead of
+	// retraining on the full series, for runs that only need validation.
+	SkipRefit bool
+
+	// Model skips training when set; Train is then ignored.
+	Model *oracle.TrainResult
+
+	// Timeout bounds the training and validation of one run (0 disables).
+	Timeout time.Duration
+
+	// Resume continues whichever of the run's fits the checkpoint was
+	// taken from; the others train from scratch.
+	Resume *oracle.Checkpoint
+}
+
+type forecastRun struct {
+	Series       []float64
+	Result       *oracle.TrainResult
+	Validation   *oracle.ValidationMetrics
+	Outliers     *oracle.OutlierReport
+	Changepoints *ChangepointPayload
+	Points       []ForecastPoint
+	Training     trainingStats
+	Metrics      []string
+}
+
+// trainingStats sums up all Train calls of one run; it stays zero when a
+// loaded model was used.
+type trainingStats struct {
+	Epochs   int
+	Duration time.Duration
+}
+
+func runForecast(ctx context.Context, series []float64, opts forecastOptions) (*forecastRun, error) {
+	prepared, outliers, changepoints, err := prepareSeries(series, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	result, validation, training, err := fitModel(ctx, prepared, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	predictions, err := oracle.ForecastContext(ctx, result, prepared, opts.Steps)
+	if err != nil {
+		return nil, fmt.Errorf("forecast failed: %w", err)
+	}
+
+	return &forecastRun{
+		Series:       prepared,
+		Result:       result,
+		Validation:   validation,
+		Outliers:     outliers,
+		Changepoints: changepoints,
+		Points:       buildForecastPoints(predictions, result.ResidualStdDev, result.Constraints),
+		Training:     training,
+		Metrics:      opts.Metrics,
+	}, nil
+}
+
+// prepareSeries applies the optional outlier cleaning and changepoint
+// windowing stages, in that order. Changepoints are detected before the
+// holdout tail, so a break inside it cannot pick the training window that
+// the holdout then scores.
+func prepareSeries(series []float64, opts forecastOptions) ([]float64, *oracle.OutlierReport, *ChangepointPayload, error) {
+	var outliers *oracle.OutlierReport
+	if opts.Outliers != nil {
+		cleaned, report, err := oracle.CleanOutliers(series, *opts.Outliers)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("outlier cleaning failed: %w", err)
+		}
+		series = cleaned
+		outliers = &report
+	}
+
+	if opts.TrainAfterBreak && opts.Changepoints == nil {
+		return nil, nil, nil, fmt.Errorf("training after the last break requires changepoint detection")
+	}
+	if opts.Changepoints == nil {
+		return series, outliers, nil, nil
+	}
+
+	detectOn := series
+	if opts.Holdout > 0 && opts.Holdout < len(series) {
+		detectOn = series[:len(series)-opts.Holdout]
+	}
+	cps, err := oracle.DetectChangepoints(detectOn, *opts.Changepoints)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("changepoint detection failed: %w", err)
+	}
+	changepoints := &ChangepointPayload{
+		Method:  strings.ToLower(opts.Changepoints.Method),
+		Cost:    strings.ToLower(opts.Changepoints.Cost),
+		Penalty: opts.Changepoints.Penalty,
+		Indices: nonNilInts(cps),
+	}
+	if opts.TrainAfterBreak && len(cps) > 0 {
+		changepoints.TrainedFrom = cps[len(cps)-1]
+		lag := opts.Train.Lag
+		if opts.Model != nil {
+			lag = opts.Model.Lag
+		}
+		if remaining := len(series) - changepoints.TrainedFrom; remaining <= lag+opts.Holdout || remaining < 6 {
+			return nil, nil, nil, fmt.Errorf("only %d points after the last changepoint at index %d: too short for lag %d and holdout %d", remaining, changepoints.TrainedFrom, lag, opts.Holdout)
+		}
+		series = series[changepoints.TrainedFrom:]
+	}
+	return series, outliers, changepoints, nil
+}
+
+// fitModel trains (or reuses opts.Model) and optionally validates on the
+// holdout tail. A trained model is refit on the full series afterwards so
+// forecasts use all observed points.
+func fitModel(ctx context.Context, series []float64, opts forecastOptions) (*oracle.TrainResult, *oracle.ValidationMetrics, trainingStats, error) {
+	var (
+		validation *oracle.ValidationMetrics
+		stats      trainingStats
+	)
+	ctx, cancel := opts.trainingContext(ctx)
+	defer cancel()
+	validate := func(result *oracle.TrainResult) error {
+		metrics, err := oracle.ValidateContext(ctx, result, series, opts.Holdout, opts.Season)
+		if err != nil {
+			return fmt.Errorf("validation failed: %w", err)
+		}
+		validation = &metrics
+		return nil
+	}
+	train := func(data []float64) (*oracle.TrainResult, error) {
+		cfg := opts.Train
+		if opts.Resume != nil && opts.Resume.Matches(data, cfg) == nil {
+			cfg.Resume = opts.Resume
+		}
+		start := time.Now()
+		result, err := oracle.TrainContext(ctx, data, cfg)
+		stats.Duration += time.Since(start)
+		if result != nil {
+			stats.Epochs += result.Epochs
+		}
+		return result, err
+	}
+
+	if opts.Model != nil {
+		if opts.Holdout > 0 {
+			if err := validate(opts.Model); err != nil {
+				return nil, nil, stats, err
+			}
+		}
+		return opts.Model, validation, stats, nil
+	}
+
+	trainSeries := series
+	if opts.Holdout > 0 {
+		if opts.Holdout >= len(series) {
+			return nil, nil, stats, fmt.Errorf("invalid holdout: %d (must be smaller than data length %d)", opts.Holdout, len(series))
+		}
+		trainSeries = series[:len(series)-opts.Holdout]
+	}
+	if opts.Resume != nil {
+		fits := [][]float64{trainSeries}
+		if opts.Holdout > 0 && !opts.SkipRefit {
+			fits = append(fits, series)
+		}
+		var err error
+		for _, data := range fits {
+			if err = opts.Resume.Matches(data, opts.Train); err == nil {
+				break
+			}
+		}
+		if err != nil {
+			return nil, nil, stats, fmt.Errorf("cannot resume: %w", err)
+		}
+	}
+
+	result, err := train(trainSeries)
+	if err != nil {
+		return nil, nil, stats, fmt.Errorf("training failed: %w", err)
+	}
+	if opts.Holdout == 0 {
+		return result, nil, stats, nil
+	}
+
+	if err := validate(result); err != nil {
+		return nil, nil, stats, err
+	}
+	if opts.SkipRefit {
+		return result, validation, stats, nil
+	}
+	result, err = train(series)
+	if err != nil {
+		return nil, nil, stats, fmt.Errorf("full-data retraining failed: %w", err)
+	}
+	return result, validation, stats, nil
+}
+
+// trainingContext bounds ctx by opts.Timeout when it is set.
+func (opts forecastOptions) trainingContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if opts.Timeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, opts.Timeout)
+}
+
+func (run *forecastRun) payload() OutputPayload {
+	payload := OutputPayload{
+		DataPoints:     len(run.Series),
+		Lag:            run.Result.Lag,
+		TrainingMSE:    run.Result.MSE,
+		ResidualStdDev: run.Result.ResidualStdDev,
+		LastObserved:   run.Series[len(run.Series)-1],
+		Changepoints:   run.Changepoints,
+		Constraints:    run.Result.Constraints,
+		Forecast:       run.Points,
+	}
+	if run.Outliers != nil {
+		payload.Outliers = &OutlierPayload{
+			Method:    run.Outliers.Method,
+			Action:    run.Outliers.Action,
+			Threshold: run.Outliers.Threshold,
+			Indices:   nonNilInts(run.Outliers.Indices),
+			Original:  nonNilFloats(run.Outliers.Original),
+			Cleaned:   nonNilFloats(run.Outliers.Cleaned),
+		}
+	}
+	if run.Validation != nil {
+		v := toValidationPayload(*run.Validation)
+		v.metrics = run.Metrics
+		payload.Validation = &v
+	}
+	return payload
+}
+
+func toValidationPayload(m oracle.ValidationMetrics) ValidationPayload {
+	return ValidationPayload{
+		Count:        m.Count,
+		MAE:          m.MAE,
+		RMSE:         m.RMSE,
+		MAPE:         m.MAPE,
+		MAPEExcluded: m.MAPEExcluded,
+		SMAPE:        m.SMAPE,
+		MASE:         m.MASE,
+		RMSSE:        m.RMSSE,
+		SPL:          m.SPL,
+		R2:           m.R2,
+		Bias:         m.Bias,
+		ZeroCount:    m.ZeroCount,
+		ZeroMAE:      m.ZeroMAE,
+		NonzeroMAE:   m.NonzeroMAE,
+	}
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	plotRows     = 12
+	plotMinWidth = 40
+	plotDefault  = 80
+)
+
+// ANSI colors of the plot layers; index 0 is an empty cell.
+var plotColors = [...]string{"", "\x1b[90m", "\x1b[37m", "\x1b[36m"}
+
+const (
+	layerBand = iota + 1
+	layerHistory
+	layerForecast
+)
+
+// brailleCanvas is a grid of braille cells, each 2 dots wide and 4 high.
+// Every cell remembers the highest layer drawn into it for its color.
+type brailleCanvas struct {
+	cols, rows int
+	dots       [][]rune
+	layer      [][]int
+}
+
+func newBrailleCanvas(cols, rows int) *brailleCanvas {
+	c := &brailleCanvas{cols: cols, rows: rows, dots: make([][]rune, rows), layer: make([][]int, rows)}
+	for r := range c.dots {
+		c.dots[r] = make([]rune, cols)
+		c.layer[r] = make([]int, cols)
+	}
+	return c
+}
+
+// brailleBits holds the dot bit of each position in a cell, by [y][x].
+var brailleBits = [4][2]rune{{0x01, 0x08}, {0x02, 0x10}, {0x04, 0x20}, {0x40, 0x80}}
+
+func (c *brailleCanvas) set(x, y, layer int) {
+	if x < 0 || y < 0 || x >= c.cols*2 || y >= c.rows*4 {
+		return
+	}
+	row, col := y/4, x/2
+	c.dots[row][col] |= brailleBits[y%4][x%2]
+	c.layer[row][col] = max(c.layer[row][col], layer)
+}
+
+// line draws from (x0, y0) to (x1, y1) with Bresenham's algorithm; dotted
+// lines only set every other column.
+func (c *brailleCanvas) line(x0, y0, x1, y1, layer int, dotted bool) {
+	dx, dy := abs(x1-x0), -abs(y1-y0)
+	sx, sy := sign(x1-x0), sign(y1-y0)
+	err := dx + dy
+	for {
+		if !dotted || x0%2 == 0 {
+			c.set(x0, y0, layer)
+		}
+		if x0 == x1 && y0 == y1 {
+			return
+		}
+		if e2 := 2 * err; e2 >= dy {
+			err += dy
+			x0 += sx
+		} else {
+			err += dx
+			y0 += sy
+		}
+	}
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+func sign(v int) int {
+	switch {
+	case v > 0:
+		return 1
+	case v < 0:
+		return -1
+	}
+	return 0
+}
+
+// row renders one line of cells, coloring runs of the same layer.
+func (c *brailleCanvas) row(r int, color bool) string {
+	var b strings.Builder
+	current := 0
+	for col, bits := range c.dots[r] {
+		layer := c.layer[r][col]
+		if color && layer != current && bits != 0 {
+			if current != 0 {
+				b.WriteString("\x1b[0m")
+			}
+			b.WriteString(plotColors[layer])
+			current = layer
+		}
+		if bits == 0 {
+			b.WriteRune(' ')
+			continue
+		}
+		b.WriteRune(0x2800 + bits)
+	}
+	if color && current != 0 {
+		b.WriteString("\x1b[0m")
+	}
+	return b.String()
+}
+
+// plotOptions size and style the terminal chart. Width 0 uses the terminal
+// width; Tail is how many of the last observations to show.
+type plotOptions struct {
+	Width int
+	Tail  int
+	Color bool
+}
+
+// printPlot draws the tail of history, the forecast and its 95% band as a
+// braille chart with auto-scaled axes.
+func printPlot(w io.Writer, history []float64, points []ForecastPoint, opts plotOptions) {
+	if len(history) == 0 {
+		return
+	}
+	width := opts.Width
+	if width <= 0 {
+		width = terminalWidth()
+	}
+	width = max(width, plotMinWidth)
+	tail := len(history)
+	if opts.Tail > 0 {
+		tail = min(tail, opts.Tail)
+	}
+	shown := history[len(history)-tail:]
+
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, v := range shown {
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+	for _, p := range points {
+		lo, hi = math.Min(lo, math.Min(p.Low95, p.Prediction)), math.Max(hi, math.Max(p.High95, p.Prediction))
+	}
+	lo, hi = padRange(lo, hi, 0.05)
+
+	labels := niceTicks(lo, hi, plotRows/2)
+	labelWidth := 0
+	for _, t := range labels {
+		labelWidth = max(labelWidth, len(formatTick(t)))
+	}
+	cols := width - labelWidth - 2
+	canvas := newBrailleCanvas(cols, plotRows)
+
+	// x positions run over the shown history plus the forecast steps.
+	total := tail + len(points)
+	px := func(i int) int {
+		if total == 1 {
+			return 0
+		}
+		return int(math.Round(float64(i) / float64(total-1) * float64(cols*2-1)))
+	}
+	py := func(v float64) int {
+		return int(math.Round((hi - v) / (hi - lo) * float64(plotRows*4-1)))
+	}
+
+	if len(points) > 0 {
+		last := shown[tail-1]
+		lowX, lowY, highX, highY := px(tail-1), py(last), px(tail-1), py(last)
+		for k, p := range points {
+			x := px(tail + k)
+			canvas.line(lowX, lowY, x, py(p.Low95), layerBand, true)
+			canvas.line(highX, highY, x, py(p.High95), layerBand, true)
+			lowX, lowY, highX, highY = x, py(p.Low95), x, py(p.High95)
+		}
+	}
+	for i := 1; i < tail; i++ {
+		canvas.line(px(i-1), py(shown[i-1]), px(i), py(shown[i]), layerHistory, false)
+	}
+	if tail == 1 {
+		canvas.set(px(0), py(shown[0]), layerHistory)
+	}
+	prevX, prevY := px(tail-1), py(shown[tail-1])
+	for k, p := range points {
+		x, y := px(tail+k), py(p.Prediction)
+		canvas.line(prevX, prevY, x, y, layerForecast, false)
+		prevX, prevY = x, y
+	}
+
+	// Each tick label goes on the row its value falls in.
+	rowLabel := map[int]string{}
+	for _, t := range labels {
+		rowLabel[py(t)/4] = formatTick(t)
+	}
+	for r := 0; r < plotRows; r++ {
+		axis := "│"
+		label, ok := rowLabel[r]
+		if ok {
+			axis = "┤"
+		}
+		fmt.Fprintf(w, "%*s %s%s\n", labelWidth, label, axis, strings.TrimRight(canvas.row(r, opts.Color), " "))
+	}
+	fmt.Fprintf(w, "%*s └%s\n", labelWidth, "", strings.Repeat("─", cols))
+
+	// The x axis names the first shown period, the first forecast step and
+	// the last one; a label that would touch one already placed is dropped.
+	axis := []rune(strings.Repeat(" ", cols+1))
+	place := func(col int, text string) {
+		n := utf8.RuneCountInString(text)
+		col = min(max(col, 0), len(axis)-n)
+		for i := max(col-1, 0); i < min(col+n+1, len(axis)); i++ {
+			if axis[i] != ' ' {
+				return
+			}
+		}
+		copy(axis[col:], []rune(text))
+	}
+	place(0, strconv.Itoa(len(history)-tail))
+	if len(points) > 0 {
+		place(px(tail)/2+1, "t+1")
+		last := fmt.Sprintf("t+%d", len(points))
+		place(len(axis)-len(last), last)
+	} else {
+		last := strconv.Itoa(len(history) - 1)
+		place(len(axis)-len(last), last)
+	}
+	fmt.Fprintf(w, "%*s %s\n", labelWidth, "", strings.TrimRight(string(axis), " "))
+
+	legend := func(layer int, text string) string {
+		if !opts.Color {
+			return text
+		}
+		return plotColors[layer] + text + "\x1b[0m"
+	}
+	entries := []string{legend(layerHistory, "⣀⣀ history")}
+	if len(points) > 0 {
+		entries = append(entries, legend(layerForecast, "⣀⣀ forecast"), legend(layerBand, "⡀⡀ 95% band"))
+	}
+	fmt.Fprintf(w, "%*s  %s\n", labelWidth, "", strings.Join(entries, "  "))
+}
+
+// terminalWidth asks the terminal for its width, then falls back to
+// $COLUMNS and finally 80 columns.
+func terminalWidth() int {
+	if cols := ttyColumns(os.Stdout); cols > 0 {
+		return cols
+	}
+	if cols, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && cols > 0 {
+		return cols
+	}
+	return plotDefault
+}
+
+// colorEnabled reports whether the plot should use ANSI colors: not with
+// -no-color or $NO_COLOR, and only when stdout is a terminal.
+func colorEnabled(noColor bool) bool {
+	if noColor || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	info, err := os.Stdout.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
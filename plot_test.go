@@ -0,0 +1,42 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestPrintPlot(t *testing.T) {
+	history := linearSeries(30)
+	points := []ForecastPoint{
+		{Step: 1, Prediction: 31, Low95: 29, High95: 33},
+		{Step: 2, Prediction: 32, Low95: 29.5, High95: 34.5},
+	}
+
+	var b strings.Builder
+	printPlot(&b, history, points, plotOptions{Width: 50, Tail: 20})
+	out := b.String()
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	if len(lines) != plotRows+3 {
+		t.Fatalf("plot has %d lines, want %d:\n%s", len(lines), plotRows+3, out)
+	}
+	for _, line := range lines {
+		if n := utf8.RuneCountInString(line); n > 50 {
+			t.Fatalf("line is %d runes wide, want at most 50: %q", n, line)
+		}
+	}
+	if strings.Contains(out, "\x1b[") {
+		t.Fatalf("plot without color has escape codes:\n%s", out)
+	}
+	for _, want := range []string{"┤", "└", "10", "t+1", "95% band"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("plot missing %q:\n%s", want, out)
+		}
+	}
+
+	b.Reset()
+	printPlot(&b, history, points, plotOptions{Width: 50, Tail: 20, Color: true})
+	if !strings.Contains(b.String(), plotColors[layerForecast]) {
+		t.Fatalf("colored plot has no forecast color:\n%s", b.String())
+	}
+}
@@ -0,0 +1,221 @@
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"oracle/internal/oracle"
+)
+
+var progressFlagNames = []string{"progress", "epoch-log"}
+
+// progressFlags report network training epochs while they run: a progress
+// bar on stderr and a per-epoch log file.
+type progressFlags struct {
+	progress bool
+	epochLog string
+}
+
+func (p *progressFlags) register(fs *flag.FlagSet) {
+	fs.BoolVar(&p.progress, "progress", false, "show a training progress bar on stderr")
+	fs.StringVar(&p.epochLog, "epoch-log", "", "optional path to log every training epoch (.csv, or .ndjson/.jsonl)")
+}
+
+func (p progressFlags) validate() error {
+	if p.epochLog == "" {
+		return nil
+	}
+	_, err := epochLogFormat(p.epochLog)
+	return err
+}
+
+func epochLogFormat(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return "csv", nil
+	case ".ndjson", ".jsonl":
+		return "ndjson", nil
+	}
+	return "", fmt.Errorf("invalid -epoch-log %q: use a .csv, .ndjson or .jsonl file", path)
+}
+
+// run calls fn with the epoch hook installed on train, then ends the
+// progress line and closes the log.
+func (p progressFlags) run(train *oracle.TrainConfig, fn func() error) error {
+	finish, err := p.start(train)
+	if err != nil {
+		return err
+	}
+	err = fn()
+	if ferr := finish(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+// start installs the epoch hook on train. The returned function ends the
+// progress line and closes the log; call it once training is over.
+func (p progressFlags) start(train *oracle.TrainConfig) (func() error, error) {
+	if !p.progress && p.epochLog == "" {
+		return func() error { return nil }, nil
+	}
+	var (
+		bar *progressBar
+		log *epochLogger
+		err error
+	)
+	if p.progress {
+		bar = &progressBar{w: os.Stderr}
+	}
+	if p.epochLog != "" {
+		if log, err = newEpochLogger(p.epochLog); err != nil {
+			return nil, err
+		}
+	}
+
+	// An epoch number that does not follow the last one starts a new Train
+	// call: validation and the final refit are logged as separate fits. A
+	// fit resumed from a checkpoint starts past epoch 1.
+	fit, last, from := 0, 0, 0
+	train.OnEpoch = func(s oracle.EpochStats) bool {
+		if fit == 0 || s.Epoch != last+1 {
+			fit++
+			from = s.Epoch - 1
+		}
+		last = s.Epoch
+		if bar != nil {
+			bar.update(fit, from, s)
+		}
+		if log != nil {
+			log.write(fit, s)
+		}
+		return false
+	}
+	return func() error {
+		if bar != nil {
+			bar.finish()
+		}
+		if log != nil {
+			if err := log.close(); err != nil {
+				return fmt.Errorf("failed writing epoch log: %w", err)
+			}
+		}
+		return nil
+	}, nil
+}
+
+const (
+	progressWidth    = 30
+	progressInterval = 100 * time.Millisecond
+)
+
+// progressBar redraws one line in place, at most every progressInterval
+// and always on the last epoch of a fit.
+type progressBar struct {
+	w      io.Writer
+	drawn  time.Time
+	active bool
+}
+
+// update draws epoch s of a fit that started after epoch from; only the
+// epochs since then count towards the time left.
+func (b *progressBar) update(fit, from int, s oracle.EpochStats) {
+	last := s.Epoch == s.Epochs
+	if !last && time.Since(b.drawn) < progressInterval {
+		return
+	}
+	b.drawn = time.Now()
+	done := s.Epoch * progressWidth / s.Epochs
+	eta := time.Duration(float64(s.Elapsed) / float64(s.Epoch-from) * float64(s.Epochs-s.Epoch))
+	fmt.Fprintf(b.w, "\rfit %d [%s%s] %3d%% epoch %d/%d loss %.6f eta %s ",
+		fit, strings.Repeat("=", done), strings.Repeat(" ", progressWidth-done),
+		s.Epoch*100/s.Epochs, s.Epoch, s.Epochs, s.Loss, eta.Round(100*time.Millisecond))
+	b.active = true
+	if last {
+		b.finish()
+	}
+}
+
+// finish ends the current line, also when a fit stopped before its last
+// epoch.
+func (b *progressBar) finish() {
+	if b.active {
+		fmt.Fprintln(b.w)
+		b.active = false
+	}
+}
+
+// epochLogger writes one record per epoch; write errors surface on close.
+type epochLogger struct {
+	file *os.File
+	buf  *bufio.Writer
+	csv  *csv.Writer
+	json *json.Encoder
+	err  error
+}
+
+func newEpochLogger(path string) (*epochLogger, error) {
+	format, err := epochLogFormat(path)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create epoch log: %w", err)
+	}
+	l := &epochLogger{file: file, buf: bufio.NewWriter(file)}
+	if format == "csv" {
+		l.csv = csv.NewWriter(l.buf)
+		l.csv.Write([]string{"fit", "epoch", "epochs", "loss", "learning_rate", "elapsed_seconds"})
+	} else {
+		l.json = json.NewEncoder(l.buf)
+	}
+	return l, nil
+}
+
+type epochRecord struct {
+	Fit            int     `json:"fit"`
+	Epoch          int     `json:"epoch"`
+	Epochs         int     `json:"epochs"`
+	Loss           float64 `json:"loss"`
+	LearningRate   float64 `json:"learning_rate"`
+	ElapsedSeconds float64 `json:"elapsed_seconds"`
+}
+
+func (l *epochLogger) write(fit int, s oracle.EpochStats) {
+	r := epochRecord{Fit: fit, Epoch: s.Epoch, Epochs: s.Epochs, Loss: s.Loss, LearningRate: s.LearningRate, ElapsedSeconds: s.Elapsed.Seconds()}
+	if l.csv != nil {
+		l.csv.Write([]string{
+			strconv.Itoa(r.Fit), strconv.Itoa(r.Epoch), strconv.Itoa(r.Epochs),
+			formatValue(r.Loss), formatValue(r.LearningRate), strconv.FormatFloat(r.ElapsedSeconds, 'f', 6, 64),
+		})
+		return
+	}
+	if err := l.json.Encode(r); err != nil && l.err == nil {
+		l.err = err
+	}
+}
+
+func (l *epochLogger) close() error {
+	err := l.err
+	if l.csv != nil {
+		l.csv.Flush()
+		err = l.csv.Error()
+	}
+	if ferr := l.buf.Flush(); err == nil {
+		err = ferr
+	}
+	if cerr := l.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
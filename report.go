@@ -0,0 +1,233 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"oracle/internal/oracle"
+)
+
+const (
+	chartWidth  = 860
+	chartHeight = 320
+)
+
+// reportRow is one label/value line of a report table.
+type reportRow struct {
+	Label string
+	Value string
+}
+
+type reportSection struct {
+	Chart template.HTML
+	Note  string
+}
+
+type reportPage struct {
+	Title     string
+	Generated string
+	Summary   []reportRow
+	Charts    []reportSection
+	Forecast  []ForecastPoint
+	Metrics   []reportRow
+	Config    []reportRow
+}
+
+// writeReport renders run as one self-contained HTML file: inline SVG
+// charts and tables, no scripts or external resources. config is the
+// resolved run config shown as a table.
+func writeReport(path string, run *forecastRun, payload OutputPayload, config map[string]any) error {
+	page := reportPage{
+		Title:     "Oracle forecast report",
+		Generated: time.Now().Format(time.RFC3339),
+		Summary:   reportSummary(run, payload),
+		Forecast:  payload.Forecast,
+		Config:    flattenConfig("", config),
+	}
+	if v := payload.Validation; v != nil {
+		page.Metrics = append(page.Metrics, reportRow{"Holdout points", strconv.Itoa(v.Count)})
+		for _, m := range selectMetrics(v.metrics) {
+			if m.zeroOnly && v.ZeroCount == 0 {
+				continue
+			}
+			value := m.format(*v)
+			if m.percent {
+				value += "%"
+			}
+			page.Metrics = append(page.Metrics, reportRow{m.label, value})
+		}
+	}
+
+	page.Charts = append(page.Charts, reportSection{Chart: template.HTML(forecastChart(run).svg())})
+	if run.Validation != nil && len(run.Validation.Predicted) > 0 {
+		page.Charts = append(page.Charts, reportSection{Chart: template.HTML(holdoutChart(run).svg())})
+	}
+	if loss := run.Result.LossHistory; len(loss) > 0 {
+		page.Charts = append(page.Charts, reportSection{Chart: template.HTML(lossChart(loss).svg())})
+	} else {
+		page.Charts = append(page.Charts, reportSection{Note: "No training loss curve: the model was loaded or has no network."})
+	}
+	residuals, err := oracle.InSampleResiduals(run.Result, run.Series)
+	if err != nil {
+		page.Charts = append(page.Charts, reportSection{Note: fmt.Sprintf("No residual histogram: %v.", err)})
+	} else {
+		bins := max(5, min(30, int(math.Ceil(math.Sqrt(float64(len(residuals)))))))
+		hist := histogramChart{Title: "In-sample residuals", XLabel: "actual - one-step prediction", Width: chartWidth, Height: chartHeight, Values: residuals, Bins: bins, Color: "#6366f1"}
+		page.Charts = append(page.Charts, reportSection{Chart: template.HTML(hist.svg())})
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if err := reportTemplate.Execute(file, page); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+func reportSummary(run *forecastRun, payload OutputPayload) []reportRow {
+	rows := []reportRow{
+		{"Data points", strconv.Itoa(payload.DataPoints)},
+		{"Model", run.Result.ModelType()},
+		{"Lag", strconv.Itoa(payload.Lag)},
+	}
+	if h := hiddenSize(run.Result); h > 0 {
+		rows = append(rows, reportRow{"Hidden", strconv.Itoa(h)}, reportRow{"Epochs", strconv.Itoa(run.Result.Epochs)})
+	}
+	rows = append(rows,
+		reportRow{"Training MSE", fmt.Sprintf("%.6f", payload.TrainingMSE)},
+		reportRow{"Residual std dev", fmt.Sprintf("%.6f", payload.ResidualStdDev)},
+		reportRow{"Last observed", fmt.Sprintf("%.4f", payload.LastObserved)},
+	)
+	if payload.Constraints != nil {
+		rows = append(rows, reportRow{"Constraints", describeConstraints(payload.Constraints)})
+	}
+	if payload.Outliers != nil {
+		rows = append(rows, reportRow{"Outliers", fmt.Sprintf("%d (%s, %s)", len(payload.Outliers.Indices), payload.Outliers.Method, payload.Outliers.Action)})
+	}
+	if payload.Changepoints != nil {
+		rows = append(rows, reportRow{"Changepoints", fmt.Sprint(payload.Changepoints.Indices)})
+	}
+	if payload.ModelLoadedFrom != "" {
+		rows = append(rows, reportRow{"Model loaded", payload.ModelLoadedFrom})
+	}
+	if payload.ModelSavedTo != "" {
+		rows = append(rows, reportRow{"Model saved", payload.ModelSavedTo})
+	}
+	return rows
+}
+
+func holdoutChart(run *forecastRun) lineChart {
+	n, k := len(run.Series), run.Validation.Count
+	x := indexRange(n-k, n)
+	return lineChart{
+		Title:  "Holdout: one-step predictions vs actuals",
+		XLabel: "period",
+		Width:  chartWidth,
+		Height: chartHeight,
+		Lines: []chartLine{
+			{Name: "actual", Color: "#1f2937", X: x, Y: run.Series[n-k:]},
+			{Name: "predicted", Color: "#dc2626", X: x, Y: run.Validation.Predicted, Dashed: true},
+		},
+	}
+}
+
+// lossChart uses a log scale unless some epoch had zero loss.
+func lossChart(loss []float64) lineChart {
+	logY := true
+	for _, v := range loss {
+		logY = logY && v > 0
+	}
+	return lineChart{
+		Title:  "Training loss (standardized MSE)",
+		XLabel: "epoch",
+		Width:  chartWidth,
+		Height: chartHeight,
+		Lines:  []chartLine{{Name: "loss", Color: "#059669", X: indexRange(1, len(loss)+1), Y: loss}},
+		LogY:   logY,
+	}
+}
+
+func indexRange(from, to int) []float64 {
+	out := make([]float64, 0, to-from)
+	for i := from; i < to; i++ {
+		out = append(out, float64(i))
+	}
+	return out
+}
+
+// flattenConfig lists the nested config document as sorted dotted keys.
+func flattenConfig(prefix string, doc map[string]any) []reportRow {
+	keys := make([]string, 0, len(doc))
+	for k := range doc {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var rows []reportRow
+	for _, k := range keys {
+		if child, ok := doc[k].(map[string]any); ok {
+			rows = append(rows, flattenConfig(prefix+k+".", child)...)
+			continue
+		}
+		rows = append(rows, reportRow{prefix + k, fmt.Sprint(doc[k])})
+	}
+	return rows
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"f4": func(v float64) string { return fmt.Sprintf("%.4f", v) },
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: sans-serif; margin: 24px auto; max-width: 900px; color: #111827; }
+h1 { font-size: 22px; margin-bottom: 4px; }
+h2 { font-size: 17px; margin-top: 28px; border-bottom: 1px solid #e5e7eb; padding-bottom: 4px; }
+.meta { color: #6b7280; font-size: 13px; }
+table { border-collapse: collapse; font-size: 13px; }
+td, th { border: 1px solid #e5e7eb; padding: 4px 10px; text-align: left; }
+td.num, th.num { text-align: right; font-variant-numeric: tabular-nums; }
+svg { display: block; margin: 12px 0; max-width: 100%; height: auto; }
+.note { color: #6b7280; font-style: italic; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="meta">Generated {{.Generated}}</p>
+
+<h2>Summary</h2>
+<table>
+{{range .Summary}}<tr><th>{{.Label}}</th><td>{{.Value}}</td></tr>
+{{end}}</table>
+
+<h2>Charts</h2>
+{{range .Charts}}{{if .Chart}}{{.Chart}}{{else}}<p class="note">{{.Note}}</p>{{end}}
+{{end}}
+{{if .Forecast}}<h2>Forecast</h2>
+<table>
+<tr><th class="num">step</th><th class="num">prediction</th><th class="num">low 95</th><th class="num">high 95</th></tr>
+{{range .Forecast}}<tr><td class="num">t+{{.Step}}</td><td class="num">{{f4 .Prediction}}</td><td class="num">{{f4 .Low95}}</td><td class="num">{{f4 .High95}}</td></tr>
+{{end}}</table>
+{{end}}
+{{if .Metrics}}<h2>Validation metrics</h2>
+<table>
+{{range .Metrics}}<tr><th>{{.Label}}</th><td class="num">{{.Value}}</td></tr>
+{{end}}</table>
+{{end}}
+{{if .Config}}<h2>Configuration</h2>
+<table>
+{{range .Config}}<tr><th>{{.Label}}</th><td>{{.Value}}</td></tr>
+{{end}}</table>
+{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,578 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"oracle/internal/oracle"
+)
+
+type serveConfig struct {
+	addr            string
+	models          modelFlags
+	modelDir        string
+	modelPoll       time.Duration
+	maxBodyBytes    int64
+	maxPoints       int
+	maxSteps        int
+	maxEpochs       int
+	maxHidden       int
+	requestTimeout  time.Duration
+	shutdownTimeout time.Duration
+}
+
+// modelFlags collects repeated -serve-model name=path flags.
+type modelFlags []string
+
+func (m *modelFlags) String() string {
+	return strings.Join(*m, ",")
+}
+
+func (m *modelFlags) Set(value string) error {
+	name, path, ok := strings.Cut(value, "=")
+	if !ok || strings.TrimSpace(name) == "" || strings.TrimSpace(path) == "" {
+		return fmt.Errorf("expected name=path, got %q", value)
+	}
+	*m = append(*m, value)
+	return nil
+}
+
+type namedModel struct {
+	Name    string
+	Version string
+	Path    string
+	Result  *oracle.TrainResult
+}
+
+type ModelInfoPayload struct {
+	Name           string   `json:"name"`
+	Version        string   `json:"version,omitempty"`
+	Versions       []string `json:"versions,omitempty"`
+	Path           string   `json:"path"`
+	Model          string   `json:"model"`
+	Lag            int      `json:"lag"`
+	Hidden         int      `json:"hidden,omitempty"`
+	TrainingMSE    float64  `json:"training_mse"`
+	ResidualStdDev float64  `json:"residual_std_dev"`
+	Checksum       string   `json:"checksum,omitempty"`
+	ModifiedAt     string   `json:"modified_at,omitempty"`
+	LoadedAt       string   `json:"loaded_at,omitempty"`
+}
+
+type OutlierRequest struct {
+	Method    string  `json:"method"`
+	Action    string  `json:"action"`
+	Window    int     `json:"window"`
+	Threshold float64 `json:"threshold"`
+}
+
+type ChangepointRequest struct {
+	Method     string  `json:"method"`
+	Cost       string  `json:"cost"`
+	Penalty    float64 `json:"penalty"`
+	MinSegment int     `json:"min_segment"`
+}
+
+// ForecastRequest mirrors the CLI flags. Zero values fall back to the
+// defaults the server was started with, except Holdout where zero disables
+// validation. Ad-hoc forecasts are all counted under the "adhoc" metrics
+// label, so requests cannot add metric series.
+type ForecastRequest struct {
+	Series          []float64           `json:"series"`
+	Steps           int                 `json:"steps"`
+	Holdout         int                 `json:"holdout"`
+	Season          int                 `json:"season"`
+	Metrics         []string            `json:"metrics"`
+	Model           string              `json:"model"`
+	Lag             int                 `json:"lag"`
+	Hidden          int                 `json:"hidden"`
+	Epochs          int                 `json:"epochs"`
+	LearningRate    float64             `json:"learning_rate"`
+	Seed            *int64              `json:"seed"`
+	Constraints     *oracle.Constraints `json:"constraints"`
+	Outliers        *OutlierRequest     `json:"outliers"`
+	Changepoints    *ChangepointRequest `json:"changepoints"`
+	TrainAfterBreak bool                `json:"train_after_break"`
+}
+
+// PredictRequest pins a registry model version with Version; empty uses
+// the latest one.
+type PredictRequest struct {
+	Series  []float64 `json:"series"`
+	Steps   int       `json:"steps"`
+	Holdout int       `json:"holdout"`
+	Season  int       `json:"season"`
+	Metrics []string  `json:"metrics"`
+	Version string    `json:"version"`
+}
+
+type errorPayload struct {
+	Error string `json:"error"`
+}
+
+// server answers from two model sources: the fixed -serve-model set, which
+// takes precedence on name clashes, and the optional hot-reloaded registry.
+type server struct {
+	cfg      serveConfig
+	defaults forecastOptions
+	models   map[string]namedModel
+	registry *oracle.Registry
+	metrics  *metrics
+}
+
+// runServe serves until ctx ends, then shuts down gracefully.
+func runServe(ctx context.Context, cfg serveConfig, defaults forecastOptions) error {
+	srv, err := newServer(cfg, defaults)
+	if err != nil {
+		return err
+	}
+
+	httpServer := &http.Server{
+		Addr:              cfg.addr,
+		Handler:           srv.routes(),
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       cfg.requestTimeout,
+		// Leave room for the timeout handler to write its own response.
+		WriteTimeout: cfg.requestTimeout + 5*time.Second,
+		IdleTimeout:  2 * time.Minute,
+	}
+
+	if srv.registry != nil {
+		go srv.registry.Watch(ctx, cfg.modelPoll, func(err error) {
+			log.Printf("oracle: model registry: %v", err)
+		})
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		log.Printf("oracle: serving on %s", cfg.addr)
+		errCh <- httpServer.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	log.Printf("oracle: shutting down")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.shutdownTimeout)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil {
+		return err
+	}
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func newServer(cfg serveConfig, defaults forecastOptions) (*server, error) {
+	srv := &server{cfg: cfg, defaults: defaults, models: map[string]namedModel{}, metrics: newMetrics()}
+	for _, spec := range cfg.models {
+		name, path, _ := strings.Cut(spec, "=")
+		name = strings.TrimSpace(name)
+		if _, exists := srv.models[name]; exists {
+			return nil, fmt.Errorf("duplicate model name: %q", name)
+		}
+		result, err := oracle.LoadModel(path)
+		if err != nil {
+			return nil, fmt.Errorf("loading model %q from %s: %w", name, path, err)
+		}
+		srv.models[name] = namedModel{Name: name, Path: path, Result: result}
+	}
+
+	if cfg.modelDir != "" {
+		registry, err := oracle.OpenRegistry(cfg.modelDir)
+		if registry == nil {
+			return nil, fmt.Errorf("opening model registry: %w", err)
+		}
+		if err != nil {
+			log.Printf("oracle: model registry: %v", err)
+		}
+		srv.registry = registry
+	}
+	return srv, nil
+}
+
+func (s *server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /healthz", s.handleHealth)
+	mux.HandleFunc("GET /metrics", s.handleMetrics)
+	mux.HandleFunc("POST /v1/forecast", s.instrument("forecast", s.handleForecast))
+	mux.HandleFunc("GET /v1/models", s.instrument("list_models", s.handleListModels))
+	mux.HandleFunc("GET /v1/models/{name}", s.instrument("model_versions", s.handleModelVersions))
+	mux.HandleFunc("POST /v1/models/{name}/predict", s.instrument("predict", s.handlePredict))
+
+	// The timeout cancels the request context, which stops the handler's
+	// training at the next epoch; the client gets a 503 right away.
+	body, _ := json.Marshal(errorPayload{Error: "request timed out"})
+	return http.TimeoutHandler(mux, s.cfg.requestTimeout, string(body))
+}
+
+func (s *server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+type metricLabelsKey struct{}
+
+// requestLabels lets a handler name the model it served once it knows it.
+type requestLabels struct {
+	model string
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (s *server) instrument(endpoint string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		labels := &requestLabels{model: "none"}
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r.WithContext(context.WithValue(r.Context(), metricLabelsKey{}, labels)))
+		s.metrics.observeRequest(endpoint, labels.model, rec.status, time.Since(start))
+	}
+}
+
+func setMetricModel(r *http.Request, model string) {
+	if labels, ok := r.Context().Value(metricLabelsKey{}).(*requestLabels); ok {
+		labels.model = model
+	}
+}
+
+func (s *server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	// Loaded models never pass through training, so publish their stored
+	// fit statistics at scrape time; this also tracks registry reloads.
+	for _, m := range s.models {
+		s.metrics.set("oracle_model_training_mse", m.Result.MSE, "model", m.Name)
+		s.metrics.set("oracle_model_residual_std_dev", m.Result.ResidualStdDev, "model", m.Name)
+	}
+	if s.registry != nil {
+		for _, name := range s.registry.Names() {
+			if v, err := s.registry.Get(name, ""); err == nil {
+				s.metrics.set("oracle_model_training_mse", v.Result.MSE, "model", name)
+				s.metrics.set("oracle_model_residual_std_dev", v.Result.ResidualStdDev, "model", name)
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := s.metrics.writeTo(w); err != nil {
+		log.Printf("oracle: writing metrics failed: %v", err)
+	}
+}
+
+func (s *server) handleListModels(w http.ResponseWriter, r *http.Request) {
+	infos := make([]ModelInfoPayload, 0, len(s.models))
+	for _, m := range s.models {
+		infos = append(infos, staticModelInfo(m))
+	}
+	if s.registry != nil {
+		for _, name := range s.registry.Names() {
+			if _, shadowed := s.models[name]; shadowed {
+				continue
+			}
+			versions := s.registry.Versions(name)
+			info := versionInfo(versions[len(versions)-1])
+			for _, v := range versions {
+				info.Versions = append(info.Versions, v.Version)
+			}
+			infos = append(infos, info)
+		}
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	writeJSON(w, http.StatusOK, map[string][]ModelInfoPayload{"models": infos})
+}
+
+func (s *server) handleModelVersions(w http.ResponseWriter, r *http.Request) {
+	name := r.PathValue("name")
+	var infos []ModelInfoPayload
+	if m, ok := s.models[name]; ok {
+		infos = append(infos, staticModelInfo(m))
+	} else if s.registry != nil {
+		for _, v := range s.registry.Versions(name) {
+			infos = append(infos, versionInfo(v))
+		}
+	}
+	if len(infos) == 0 {
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown model: %q", name))
+		return
+	}
+	setMetricModel(r, name)
+	writeJSON(w, http.StatusOK, map[string][]ModelInfoPayload{"versions": infos})
+}
+
+// lookupModel resolves name (and an optional pinned version) against the
+// fixed models first and the registry second.
+func (s *server) lookupModel(name, version string) (namedModel, error) {
+	if m, ok := s.models[name]; ok {
+		if version != "" {
+			return namedModel{}, fmt.Errorf("%w: %q is not versioned", oracle.ErrModelNotFound, name)
+		}
+		return m, nil
+	}
+	if s.registry == nil {
+		return namedModel{}, fmt.Errorf("%w: %q", oracle.ErrModelNotFound, name)
+	}
+	v, err := s.registry.Get(name, version)
+	if err != nil {
+		return namedModel{}, err
+	}
+	return namedModel{Name: v.Name, Version: v.Version, Path: v.Path, Result: v.Result}, nil
+}
+
+func staticModelInfo(m namedModel) ModelInfoPayload {
+	return ModelInfoPayload{
+		Name:           m.Name,
+		Path:           m.Path,
+		Model:          m.Result.ModelType(),
+		Lag:            m.Result.Lag,
+		Hidden:         hiddenSize(m.Result),
+		TrainingMSE:    m.Result.MSE,
+		ResidualStdDev: m.Result.ResidualStdDev,
+	}
+}
+
+func versionInfo(v *oracle.ModelVersion) ModelInfoPayload {
+	return ModelInfoPayload{
+		Name:           v.Name,
+		Version:        v.Version,
+		Path:           v.Path,
+		Model:          v.Result.ModelType(),
+		Lag:            v.Result.Lag,
+		Hidden:         hiddenSize(v.Result),
+		TrainingMSE:    v.Result.MSE,
+		ResidualStdDev: v.Result.ResidualStdDev,
+		Checksum:       v.Checksum,
+		ModifiedAt:     v.ModTime.UTC().Format(time.RFC3339),
+		LoadedAt:       v.LoadedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+func (s *server) handleForecast(w http.ResponseWriter, r *http.Request) {
+	var req ForecastRequest
+	if !s.decode(w, r, &req) {
+		return
+	}
+	const model = "adhoc"
+	setMetricModel(r, model)
+
+	opts, err := s.forecastOptions(req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	run, err := runForecast(r.Context(), req.Series, opts)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	s.metrics.observeRun(model, run)
+	writeJSON(w, http.StatusOK, run.payload())
+}
+
+func (s *server) handlePredict(w http.ResponseWriter, r *http.Request) {
+	var req PredictRequest
+	if !s.decode(w, r, &req) {
+		return
+	}
+
+	// Resolve once so the whole request uses the same snapshot even if the
+	// registry swaps in a new version meanwhile.
+	model, err := s.lookupModel(r.PathValue("name"), req.Version)
+	if err != nil {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	// Label only known models so arbitrary paths cannot grow the metrics.
+	setMetricModel(r, model.Name)
+
+	opts := forecastOptions{Steps: req.Steps, Holdout: req.Holdout, Model: model.Result}
+	if opts.Steps == 0 {
+		opts.Steps = s.defaults.Steps
+	}
+	if err := s.validateCommon(req.Series, opts.Steps, opts.Holdout); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if err := applyEvaluation(&opts, req.Season, req.Metrics); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	run, err := runForecast(r.Context(), req.Series, opts)
+	if err != nil {
+		writeError(w, http.StatusUnprocessableEntity, err)
+		return
+	}
+	s.metrics.observeRun(model.Name, run)
+	payload := run.payload()
+	payload.ModelLoadedFrom = model.Name
+	if model.Version != "" {
+		payload.ModelLoadedFrom += "@" + model.Version
+	}
+	writeJSON(w, http.StatusOK, payload)
+}
+
+// decode reads a size-limited JSON body into dst and reports failures to
+// the client itself.
+func (s *server) decode(w http.ResponseWriter, r *http.Request, dst any) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, s.cfg.maxBodyBytes)
+	dec := json.NewDecoder(r.Body)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(dst); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			writeError(w, http.StatusRequestEntityTooLarge, fmt.Errorf("request body exceeds %d bytes", tooLarge.Limit))
+			return false
+		}
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid JSON body: %w", err))
+		return false
+	}
+	if dec.More() {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid JSON body: trailing data"))
+		return false
+	}
+	return true
+}
+
+func (s *server) forecastOptions(req ForecastRequest) (forecastOptions, error) {
+	opts := forecastOptions{
+		Steps:           req.Steps,
+		Holdout:         req.Holdout,
+		Train:           s.defaults.Train,
+		TrainAfterBreak: req.TrainAfterBreak,
+		Timeout:         s.defaults.Timeout,
+	}
+	if opts.Steps == 0 {
+		opts.Steps = s.defaults.Steps
+	}
+	if req.Model != "" {
+		opts.Train.Model = strings.ToLower(strings.TrimSpace(req.Model))
+	}
+	if req.Lag != 0 {
+		opts.Train.Lag = req.Lag
+	}
+	if req.Hidden != 0 {
+		opts.Train.Hidden = req.Hidden
+	}
+	if req.Epochs != 0 {
+		opts.Train.Epochs = req.Epochs
+	}
+	if req.LearningRate != 0 {
+		opts.Train.LearningRate = req.LearningRate
+	}
+	if req.Seed != nil {
+		opts.Train.Seed = *req.Seed
+	}
+	if req.Constraints != nil {
+		opts.Train.Constraints = req.Constraints
+	}
+	if req.Outliers != nil {
+		opts.Outliers = &oracle.OutlierConfig{
+			Method:    req.Outliers.Method,
+			Action:    req.Outliers.Action,
+			Window:    req.Outliers.Window,
+			Threshold: req.Outliers.Threshold,
+		}
+	}
+	if req.Changepoints != nil {
+		opts.Changepoints = &oracle.ChangepointConfig{
+			Method:     req.Changepoints.Method,
+			Cost:       req.Changepoints.Cost,
+			Penalty:    req.Changepoints.Penalty,
+			MinSegment: req.Changepoints.MinSegment,
+		}
+	}
+
+	if err := s.validateCommon(req.Series, opts.Steps, opts.Holdout); err != nil {
+		return opts, err
+	}
+	if err := applyEvaluation(&opts, req.Season, req.Metrics); err != nil {
+		return opts, err
+	}
+	if err := opts.Train.Constraints.Validate(); err != nil {
+		return opts, fmt.Errorf("invalid constraints: %w", err)
+	}
+	switch {
+	case opts.Train.Model != "" && !containsString(oracle.ModelTypes, opts.Train.Model):
+		return opts, fmt.Errorf("unknown model %q (use %s)", req.Model, strings.Join(oracle.ModelTypes, ", "))
+	case opts.Train.Lag < 0 || opts.Train.Hidden < 0 || opts.Train.LearningRate < 0:
+		return opts, fmt.Errorf("lag, hidden and learning_rate must not be negative")
+	case opts.Train.Epochs < 0 || opts.Train.Epochs > s.cfg.maxEpochs:
+		return opts, fmt.Errorf("epochs must be between 0 and %d", s.cfg.maxEpochs)
+	case opts.Train.Hidden > s.cfg.maxHidden:
+		return opts, fmt.Errorf("hidden must be between 0 and %d", s.cfg.maxHidden)
+	case math.IsNaN(opts.Train.LearningRate) || math.IsInf(opts.Train.LearningRate, 0):
+		return opts, fmt.Errorf("learning_rate must be finite")
+	case opts.Train.Lag >= len(req.Series):
+		return opts, fmt.Errorf("lag %d must be smaller than the series length %d", opts.Train.Lag, len(req.Series))
+	}
+	return opts, nil
+}
+
+// applyEvaluation sets the validation season (zero for the plain naive
+// forecast) and the reported metrics (all when empty).
+func applyEvaluation(opts *forecastOptions, season int, metrics []string) error {
+	if season < 0 {
+		return fmt.Errorf("season must not be negative")
+	}
+	selected, err := parseMetrics(strings.Join(metrics, ","))
+	if err != nil {
+		return err
+	}
+	opts.Season, opts.Metrics = season, selected
+	return nil
+}
+
+func (s *server) validateCommon(series []float64, steps, holdout int) error {
+	switch {
+	case len(series) == 0:
+		return fmt.Errorf("series must not be empty")
+	case len(series) > s.cfg.maxPoints:
+		return fmt.Errorf("series has %d points, limit is %d", len(series), s.cfg.maxPoints)
+	case steps < 1 || steps > s.cfg.maxSteps:
+		return fmt.Errorf("steps must be between 1 and %d", s.cfg.maxSteps)
+	case holdout < 0 || holdout >= len(series):
+		return fmt.Errorf("holdout must be between 0 and %d", len(series)-1)
+	}
+	return nil
+}
+
+// writeJSON encodes v before sending the status, so a value that cannot be
+// encoded (such as a NaN metric) becomes a 500 instead of a truncated 200.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(v); err != nil {
+		log.Printf("oracle: encoding response failed: %v", err)
+		status = http.StatusInternalServerError
+		body.Reset()
+		json.NewEncoder(&body).Encode(errorPayload{Error: fmt.Sprintf("encoding response failed: %v", err)})
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if _, err := w.Write(body.Bytes()); err != nil {
+		log.Printf("oracle: writing response failed: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, errorPayload{Error: err.Error()})
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"oracle/internal/oracle"
+)
+
+func testServeConfig() serveConfig {
+	return serveConfig{
+		maxBodyBytes:   1 << 16,
+		maxPoints:      500,
+		maxSteps:       20,
+		maxEpochs:      2000,
+		maxHidden:      64,
+		requestTimeout: 30 * time.Second,
+	}
+}
+
+func linearSeries(n int) []float64 {
+	series := make([]float64, n)
+	for i := range series {
+		series[i] = 10 + 0.5*float64(i)
+	}
+	return series
+}
+
+func TestServerForecast(t *testing.T) {
+	srv, err := newServer(testServeConfig(), forecastOptions{Steps: 3, Train: oracle.TrainConfig{Lag: 4, Hidden: 6, Epochs: 200, Seed: 1}})
+	if err != nil {
+		t.Fatalf("newServer failed: %v", err)
+	}
+
+	body, _ := json.Marshal(ForecastRequest{Series: linearSeries(30), Holdout: 4})
+	rec := httptest.NewRecorder()
+	srv.routes().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/forecast", strings.NewReader(string(body))))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+
+	var payload OutputPayload
+	if err := json.Unmarshal(rec.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(payload.Forecast) != 3 || payload.Lag != 4 || payload.Validation == nil {
+		t.Fatalf("unexpected payload: %+v", payload)
+	}
+}
+
+func TestServerRejectsInvalidRequests(t *testing.T) {
+	cfg := testServeConfig()
+	cfg.maxBodyBytes = 256
+	srv, err := newServer(cfg, forecastOptions{Steps: 3})
+	if err != nil {
+		t.Fatalf("newServer failed: %v", err)
+	}
+	handler := srv.routes()
+
+	cases := []struct {
+		name string
+		path string
+		body string
+		want int
+	}{
+		{"empty series", "/v1/forecast", `{"series":[]}`, http.StatusBadRequest},
+		{"unknown field", "/v1/forecast", `{"series":[1,2,3],"bogus":1}`, http.StatusBadRequest},
+		{"forecast name", "/v1/forecast", `{"name":"sales","series":[1,2,3]}`, http.StatusBadRequest},
+		{"too many steps", "/v1/forecast", `{"series":[1,2,3,4,5,6,7,8],"steps":50}`, http.StatusBadRequest},
+		{"too large", "/v1/forecast", `{"series":[` + strings.Repeat("1,", 200) + `1]}`, http.StatusRequestEntityTooLarge},
+		{"too many hidden units", "/v1/forecast", `{"series":[1,2,3,4,5,6,7,8],"lag":2,"hidden":10000000}`, http.StatusBadRequest},
+		{"unknown model", "/v1/models/missing/predict", `{"series":[1,2,3]}`, http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tc.path, strings.NewReader(tc.body)))
+		if rec.Code != tc.want {
+			t.Fatalf("%s: status = %d, want %d (body %s)", tc.name, rec.Code, tc.want, rec.Body)
+		}
+	}
+}
+
+func TestWriteJSONReportsEncodingFailure(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeJSON(rec, http.StatusOK, map[string]float64{"mae": math.NaN()})
+	if rec.Code != http.StatusInternalServerError || !strings.Contains(rec.Body.String(), `"error"`) {
+		t.Fatalf("status = %d, body = %s", rec.Code, rec.Body)
+	}
+}
+
+func TestServerPredictWithNamedModel(t *testing.T) {
+	series := linearSeries(40)
+	result, err := oracle.Train(series, oracle.TrainConfig{Lag: 5, Hidden: 6, Epochs: 300, Seed: 3})
+	if err != nil {
+		t.Fatalf("train failed: %v", err)
+	}
+	path := filepath.Join(t.TempDir(), "model.json")
+	if err := oracle.SaveModel(path, result); err != nil {
+		t.Fatalf("SaveModel failed: %v", err)
+	}
+
+	cfg := testServeConfig()
+	cfg.models = modelFlags{"linear=" + path}
+	srv, err := newServer(cfg, forecastOptions{Steps: 2})
+	if err != nil {
+		t.Fatalf("newServer failed: %v", err)
+	}
+	handler := srv.routes()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/models", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"name":"linear"`) {
+		t.Fatalf("list models: status = %d, body = %s", rec.Code, rec.Body)
+	}
+
+	body, _ := json.Marshal(PredictRequest{Series: series})
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/models/linear/predict", strings.NewReader(string(body))))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("predict: status = %d, body = %s", rec.Code, rec.Body)
+	}
+
+	var payload OutputPayload
+	if err := json.Unmarshal(rec.Body.Bytes(), &payload); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if payload.ModelLoadedFrom != "linear" || len(payload.Forecast) != 2 {
+		t.Fatalf("unexpected payload: %+v", payload)
+	}
+}
+
+func TestServerPredictPinnedRegistryVersion(t *testing.T) {
+	series := linearSeries(40)
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "demand"), 0o755); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	for version, lag := range map[string]int{"1": 3, "2": 5} {
+		result, err := oracle.Train(series, oracle.TrainConfig{Lag: lag, Hidden: 4, Epochs: 100, Seed: 1})
+		if err != nil {
+			t.Fatalf("train failed: %v", err)
+		}
+		if err := oracle.SaveModel(filepath.Join(dir, "demand", version+".json"), result); err != nil {
+			t.Fatalf("SaveModel failed: %v", err)
+		}
+	}
+
+	cfg := testServeConfig()
+	cfg.modelDir = dir
+	srv, err := newServer(cfg, forecastOptions{Steps: 1})
+	if err != nil {
+		t.Fatalf("newServer failed: %v", err)
+	}
+	handler := srv.routes()
+
+	for _, tc := range []struct {
+		version string
+		lag     int
+		status  int
+	}{
+		{"", 5, http.StatusOK},
+		{"1", 3, http.StatusOK},
+		{"9", 0, http.StatusNotFound},
+	} {
+		body, _ := json.Marshal(PredictRequest{Series: series, Version: tc.version})
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/models/demand/predict", strings.NewReader(string(body))))
+		if rec.Code != tc.status {
+			t.Fatalf("version %q: status = %d, want %d (body %s)", tc.version, rec.Code, tc.status, rec.Body)
+		}
+		if tc.status != http.StatusOK {
+			continue
+		}
+		var payload OutputPayload
+		if err := json.Unmarshal(rec.Body.Bytes(), &payload); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		if payload.Lag != tc.lag {
+			t.Fatalf("version %q: lag = %d, want %d", tc.version, payload.Lag, tc.lag)
+		}
+	}
+}
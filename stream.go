@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+
+	"oracle/internal/oracle"
+)
+
+type StreamPredictionPayload struct {
+	Index     int     `json:"index"`
+	Predicted float64 `json:"predicted"`
+	Low       float64 `json:"low"`
+	High      float64 `json:"high"`
+}
+
+type StreamMetricsPayload struct {
+	Count    int     `json:"count"`
+	MAE      float64 `json:"mae"`
+	RMSE     float64 `json:"rmse"`
+	Bias     float64 `json:"bias"`
+	Coverage float64 `json:"coverage"`
+}
+
+type StreamStepPayload struct {
+	Index     int                     `json:"index"`
+	Actual    float64                 `json:"actual"`
+	Predicted float64                 `json:"predicted"`
+	Low       float64                 `json:"low"`
+	High      float64                 `json:"high"`
+	Residual  float64                 `json:"residual"`
+	Covered   bool                    `json:"covered"`
+	Next      StreamPredictionPayload `json:"next"`
+	Metrics   StreamMetricsPayload    `json:"metrics"`
+}
+
+func toStreamPredictionPayload(p oracle.StreamPrediction) StreamPredictionPayload {
+	return StreamPredictionPayload{Index: p.Index, Predicted: p.Predicted, Low: p.Low, High: p.High}
+}
+
+func toStreamMetricsPayload(m oracle.StreamMetrics) StreamMetricsPayload {
+	return StreamMetricsPayload{Count: m.Count, MAE: m.MAE, RMSE: m.RMSE, Bias: m.Bias, Coverage: m.Coverage}
+}
+
+func toStreamStepPayload(s oracle.StreamStep) StreamStepPayload {
+	return StreamStepPayload{
+		Index:     s.Index,
+		Actual:    s.Actual,
+		Predicted: s.Predicted,
+		Low:       s.Low,
+		High:      s.High,
+		Residual:  s.Residual,
+		Covered:   s.Covered,
+		Next:      toStreamPredictionPayload(s.Next),
+		Metrics:   toStreamMetricsPayload(s.Metrics),
+	}
+}
+
+func runStreamCommand(ctx context.Context, args []string) error {
+	var (
+		dataPath, loadPath string
+		cfg                oracle.StreamConfig
+	)
+	fs := newFlagSet("stream")
+	fs.StringVar(&dataPath, "data", "", "optional history file to start from (default: the first lag values on stdin)")
+	fs.StringVar(&loadPath, "load-model", "", "path of the model JSON to use (required)")
+	fs.Float64Var(&cfg.Interval, "interval", 0.95, "coverage of the normal prediction interval")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if loadPath == "" {
+		return fmt.Errorf("stream requires -load-model")
+	}
+	if cfg.Interval <= 0 || cfg.Interval >= 1 {
+		return fmt.Errorf("invalid -interval: %v (must be between 0 and 1)", cfg.Interval)
+	}
+	result, err := loadModelIfRequested(loadPath)
+	if err != nil {
+		return err
+	}
+	var history []float64
+	if dataPath != "" {
+		if history, err = oracle.LoadSeriesFromFile(dataPath); err != nil {
+			return fmt.Errorf("failed to load data: %w", err)
+		}
+		if len(history) < result.Lag {
+			return fmt.Errorf("-data has %d points, the model needs at least %d (its lag)", len(history), result.Lag)
+		}
+	}
+
+	// stdout carries NDJSON only, so the summary goes to stderr.
+	fmt.Fprintln(os.Stderr, "Oracle - Stream")
+	fmt.Fprintf(os.Stderr, "Model            : %s (lag %d)\n", result.ModelType(), result.Lag)
+	fmt.Fprintf(os.Stderr, "Model loaded     : %s\n", loadPath)
+	fmt.Fprintf(os.Stderr, "Interval         : %.0f%%\n", 100*cfg.Interval)
+	if err := streamPredict(ctx, result, history, cfg, os.Stdin, os.Stdout, os.Stderr); err != nil {
+		return fmt.Errorf("streaming prediction failed: %w", err)
+	}
+	return nil
+}
+
+// streamPredict reads values line by line from r and, once the model has
+// lag values of history, writes one JSON object per value to w: the
+// prediction that was made for it, its residual, the prediction for the
+// next value and the running metrics. Lines are read by readValues;
+// progress notes and the final metrics go to log.
+func streamPredict(ctx context.Context, result *oracle.TrainResult, history []float64, cfg oracle.StreamConfig, r io.Reader, w, log io.Writer) error {
+	var predictor *oracle.StreamPredictor
+	start := func() error {
+		var err error
+		if predictor, err = oracle.NewStreamPredictor(result, history, cfg); err != nil {
+			return err
+		}
+		next := predictor.Next()
+		fmt.Fprintf(log, "Next prediction  : #%d -> %.4f [%.4f, %.4f]\n", next.Index, next.Predicted, next.Low, next.High)
+		return nil
+	}
+	if len(history) >= result.Lag {
+		if err := start(); err != nil {
+			return err
+		}
+	} else {
+		fmt.Fprintf(log, "Waiting for %d values of history on stdin\n", result.Lag-len(history))
+	}
+
+	enc := json.NewEncoder(w)
+	err := readValues(ctx, r, log, func(v float64) error {
+		if predictor == nil {
+			history = append(history, v)
+			if len(history) == result.Lag {
+				return start()
+			}
+			return nil
+		}
+		step, err := predictor.Observe(v)
+		if err != nil {
+			return err
+		}
+		return enc.Encode(toStreamStepPayload(step))
+	})
+	if err != nil {
+		return err
+	}
+	if predictor != nil {
+		m := predictor.Metrics()
+		fmt.Fprintf(log, "Scored values    : %d (MAE %.6f, RMSE %.6f, bias %.6f, coverage %.1f%%)\n", m.Count, m.MAE, m.RMSE, m.Bias, 100*m.Coverage)
+	}
+	return nil
+}
+
+// readValues calls fn with the value on each line of r until r ends, fn
+// fails or ctx is done. Blank lines are ignored; other lines that are not a
+// finite number are skipped with a note to log naming the line, so a
+// mistyped value does not silently shift what follows.
+func readValues(ctx context.Context, r io.Reader, log io.Writer, fn func(float64) error) error {
+	// Reading stdin blocks, so lines are read in the background to let an
+	// interrupt end the stream between values.
+	lines := make(chan string)
+	errs := make(chan error, 1)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-ctx.Done():
+				return
+			}
+		}
+		errs <- scanner.Err()
+	}()
+
+	for lineNo := 1; ; lineNo++ {
+		var line string
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("stream interrupted: %w", ctx.Err())
+		case l, ok := <-lines:
+			if !ok {
+				if ctx.Err() != nil {
+					return fmt.Errorf("stream interrupted: %w", ctx.Err())
+				}
+				return <-errs
+			}
+			line = strings.TrimSpace(l)
+		}
+		if line == "" {
+			continue
+		}
+		v, err := strconv.ParseFloat(line, 64)
+		if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+			fmt.Fprintf(log, "Skipped line %d: %q is not a finite number\n", lineNo, line)
+			continue
+		}
+		if err := fn(v); err != nil {
+			return err
+		}
+	}
+}
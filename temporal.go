@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"oracle/internal/oracle"
+)
+
+type TemporalLevelPayload struct {
+	Aggregation    int             `json:"aggregation"`
+	DataPoints     int             `json:"data_points"`
+	Lag            int             `json:"lag"`
+	TrainingMSE    float64         `json:"training_mse"`
+	ResidualStdDev float64         `json:"residual_std_dev"`
+	Forecast       []ForecastPoint `json:"forecast"`
+	BaseForecast   []ForecastPoint `json:"base_forecast"`
+}
+
+type TemporalOutputPayload struct {
+	DataPoints      int                    `json:"data_points"`
+	Dropped         int                    `json:"dropped"`
+	Steps           int                    `json:"steps"`
+	Reconciliation  string                 `json:"reconciliation"`
+	Levels          []TemporalLevelPayload `json:"levels"`
+	ForecastCSVPath string                 `json:"forecast_csv_path,omitempty"`
+}
+
+// parseLevels reads a comma-separated list of aggregation factors.
+func parseLevels(s string) ([]int, error) {
+	var levels []int
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		k, err := strconv.Atoi(part)
+		if err != nil || k <= 0 {
+			return nil, fmt.Errorf("invalid -levels entry %q (use positive integers such as 7,28)", part)
+		}
+		levels = append(levels, k)
+	}
+	return levels, nil
+}
+
+func runTemporalCommand(ctx context.Context, args []string) error {
+	var (
+		train                             trainFlags
+		prep                              prepFlags
+		conf                              configFlags
+		dataPath, format, outPath, levels string
+		method                            string
+		steps                             int
+	)
+	fs := newFlagSet("temporal")
+	conf.register(fs)
+	fs.StringVar(&dataPath, "data", "data/sample.csv", "path to time-series data file")
+	fs.StringVar(&format, "format", "text", "output format: text or json")
+	fs.StringVar(&outPath, "out", "", "optional CSV output path for the forecasts of every level")
+	fs.StringVar(&levels, "levels", "", "comma-separated aggregation factors in base periods, e.g. 7,28 (1 is always included)")
+	fs.StringVar(&method, "reconcile", oracle.ReconcileStructural, "reconciliation method: bottomup, topdown, ols, wls, structural or mint")
+	fs.IntVar(&steps, "steps", 1, "forecast horizon in periods of the largest level")
+	train.register(fs)
+	prep.register(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if err := conf.apply(fs, "temporal"); err != nil {
+		return err
+	}
+	set := flagsSet(fs)
+	if err := train.validate(set); err != nil {
+		return err
+	}
+	if err := prep.validate(set); err != nil {
+		return err
+	}
+	format, err := parseFormat(format)
+	if err != nil {
+		return err
+	}
+	if levels == "" {
+		return fmt.Errorf("temporal requires -levels")
+	}
+	factors, err := parseLevels(levels)
+	if err != nil {
+		return err
+	}
+	if err := rejectWith(set, "levels", "reconciled forecasts would no longer satisfy the constraints", constraintFlagNames...); err != nil {
+		return err
+	}
+	method = strings.ToLower(strings.TrimSpace(method))
+	if !containsString(reconcileMethods, method) {
+		return fmt.Errorf("invalid -reconcile: %q (use bottomup, topdown, ols, wls, structural or mint)", method)
+	}
+	if steps <= 0 {
+		return fmt.Errorf("invalid -steps: %d (must be positive)", steps)
+	}
+	if done, err := conf.dump(fs, "temporal", nil); err != nil || done {
+		return err
+	}
+
+	series, err := oracle.LoadSeriesFromFile(dataPath)
+	if err != nil {
+		return fmt.Errorf("failed to load data: %w", err)
+	}
+	opts := forecastOptions{Train: train.config(), Timeout: train.timeout}
+	prep.apply(&opts)
+	prepared, _, _, err := prepareSeries(series, opts)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := opts.trainingContext(ctx)
+	defer cancel()
+	result, err := oracle.ForecastTemporalContext(ctx, prepared, oracle.TemporalConfig{
+		Levels: factors,
+		Steps:  steps,
+		Method: method,
+		Train:  opts.Train,
+	})
+	if err != nil {
+		return fmt.Errorf("temporal forecast failed: %w", err)
+	}
+
+	payload := TemporalOutputPayload{
+		DataPoints:      len(prepared),
+		Dropped:         result.Offset,
+		Steps:           steps,
+		Reconciliation:  method,
+		ForecastCSVPath: outPath,
+	}
+	for _, level := range result.Levels {
+		payload.Levels = append(payload.Levels, TemporalLevelPayload{
+			Aggregation:    level.Aggregation,
+			DataPoints:     len(level.Series),
+			Lag:            level.Result.Lag,
+			TrainingMSE:    level.Result.MSE,
+			ResidualStdDev: level.Result.ResidualStdDev,
+			Forecast:       buildForecastPoints(level.Reconciled, level.Result.ResidualStdDev, nil),
+			BaseForecast:   buildForecastPoints(level.Base, level.Result.ResidualStdDev, nil),
+		})
+	}
+	if outPath != "" {
+		if err := writeTemporalCSV(outPath, payload.Levels); err != nil {
+			return fmt.Errorf("failed writing forecast CSV: %w", err)
+		}
+	}
+	if format == "json" {
+		return printJSON(payload)
+	}
+
+	fmt.Println("Oracle - Temporal Hierarchy Forecast")
+	fmt.Printf("Data points      : %d\n", payload.DataPoints)
+	if payload.Dropped > 0 {
+		fmt.Printf("Dropped          : %d leading points (incomplete period)\n", payload.Dropped)
+	}
+	fmt.Printf("Reconciliation   : %s\n", payload.Reconciliation)
+	for _, level := range payload.Levels {
+		fmt.Println()
+		fmt.Printf("Aggregation %d (%d periods, lag %d, residual std dev %.6f)\n", level.Aggregation, level.DataPoints, level.Lag, level.ResidualStdDev)
+		for i, p := range level.Forecast {
+			fmt.Printf("t+%d -> %.4f  (95%% range: %.4f .. %.4f)  base %.4f\n", p.Step, p.Prediction, p.Low95, p.High95, level.BaseForecast[i].Prediction)
+		}
+	}
+	if outPath != "" {
+		fmt.Printf("\nSaved forecast CSV: %s\n", outPath)
+	}
+	return nil
+}
+
+func writeTemporalCSV(path string, levels []TemporalLevelPayload) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	w := csv.NewWriter(file)
+	if err := w.Write([]string{"aggregation", "step", "prediction", "low_95", "high_95", "base_prediction"}); err != nil {
+		return err
+	}
+	for _, level := range levels {
+		for i, p := range level.Forecast {
+			row := []string{
+				strconv.Itoa(level.Aggregation),
+				strconv.Itoa(p.Step),
+				fmt.Sprintf("%.6f", p.Prediction),
+				fmt.Sprintf("%.6f", p.Low95),
+				fmt.Sprintf("%.6f", p.High95),
+				fmt.Sprintf("%.6f", level.BaseForecast[i].Prediction),
+			}
+			if err := w.Write(row); err != nil {
+				return err
+			}
+		}
+	}
+
+	w.Flush()
+	return w.Error()
+}
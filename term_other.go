@@ -0,0 +1,11 @@
+//go:build !linux && !darwin
+
+package main
+
+import "os"
+
+// ttyColumns cannot ask the terminal here; the caller falls back to
+// $COLUMNS.
+func ttyColumns(*os.File) int {
+	return 0
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"oracle/internal/oracle"
+)
+
+type UpdateOutputPayload struct {
+	DataPoints             int     `json:"data_points"`
+	NewPoints              int     `json:"new_points"`
+	Model                  string  `json:"model"`
+	Lag                    int     `json:"lag"`
+	Epochs                 int     `json:"epochs,omitempty"`
+	ScalerRefit            bool    `json:"scaler_refit"`
+	ScalerMean             float64 `json:"scaler_mean"`
+	ScalerStd              float64 `json:"scaler_std"`
+	TrainingMSE            float64 `json:"training_mse"`
+	ResidualStdDev         float64 `json:"residual_std_dev"`
+	PreviousResidualStdDev float64 `json:"previous_residual_std_dev"`
+	ModelLoadedFrom        string  `json:"model_loaded_from"`
+	ModelSavedTo           string  `json:"model_saved_to"`
+}
+
+func runUpdateCommand(ctx context.Context, args []string) error {
+	var (
+		dataPath, format   string
+		loadPath, savePath string
+		newPoints          int
+		cfg                oracle.UpdateConfig
+	)
+	fs := newFlagSet("update")
+	fs.StringVar(&dataPath, "data", "data/sample.csv", "path to the training data followed by the new observations")
+	fs.StringVar(&format, "format", "text", "output format: text or json")
+	fs.StringVar(&loadPath, "load-model", "", "path of the model JSON to update (required)")
+	fs.StringVar(&savePath, "save-model", "", "path for the updated model (default: the next version next to a numbered -load-model)")
+	fs.IntVar(&newPoints, "new", 0, "number of new observations at the end of -data (required)")
+	fs.IntVar(&cfg.Epochs, "epochs", 50, "fine-tuning epochs over the new points")
+	fs.Float64Var(&cfg.LearningRate, "lr", 0.008, "fine-tuning learning rate")
+	fs.Int64Var(&cfg.Seed, "seed", 42, "random seed")
+	fs.BoolVar(&cfg.RefitScaler, "refit-scaler", false, "refit the standardization on all of -data, adjusting the weights to match")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if loadPath == "" {
+		return fmt.Errorf("update requires -load-model")
+	}
+	if newPoints <= 0 {
+		return fmt.Errorf("update requires -new: the number of new observations (got %d)", newPoints)
+	}
+	if cfg.Epochs <= 0 || cfg.LearningRate <= 0 {
+		return fmt.Errorf("-epochs and -lr must be positive")
+	}
+	format, err := parseFormat(format)
+	if err != nil {
+		return err
+	}
+	if savePath, err = updateSavePath(loadPath, savePath); err != nil {
+		return err
+	}
+
+	series, err := oracle.LoadSeriesFromFile(dataPath)
+	if err != nil {
+		return fmt.Errorf("failed to load data: %w", err)
+	}
+	base, err := loadModelIfRequested(loadPath)
+	if err != nil {
+		return err
+	}
+	updated, err := oracle.UpdateContext(ctx, base, series, newPoints, cfg)
+	if err != nil {
+		return fmt.Errorf("update failed: %w", err)
+	}
+	if err := saveModelIfRequested(savePath, updated); err != nil {
+		return err
+	}
+
+	payload := UpdateOutputPayload{
+		DataPoints:             len(series),
+		NewPoints:              newPoints,
+		Model:                  updated.ModelType(),
+		Lag:                    updated.Lag,
+		Epochs:                 updated.Epochs,
+		ScalerRefit:            updated.Model != nil && cfg.RefitScaler,
+		ScalerMean:             updated.Scaler.Mean,
+		ScalerStd:              updated.Scaler.Std,
+		TrainingMSE:            updated.MSE,
+		ResidualStdDev:         updated.ResidualStdDev,
+		PreviousResidualStdDev: base.ResidualStdDev,
+		ModelLoadedFrom:        loadPath,
+		ModelSavedTo:           savePath,
+	}
+	if format == "json" {
+		return printJSON(payload)
+	}
+
+	fmt.Println("Oracle - Update")
+	fmt.Printf("Data points      : %d\n", payload.DataPoints)
+	fmt.Printf("New points       : %d\n", payload.NewPoints)
+	fmt.Printf("Model            : %s\n", payload.Model)
+	fmt.Printf("Lag              : %d\n", payload.Lag)
+	if updated.Model != nil {
+		fmt.Printf("Epochs           : %d\n", payload.Epochs)
+		scaler := "kept"
+		if payload.ScalerRefit {
+			scaler = "refit"
+		}
+		fmt.Printf("Scaler           : %s (mean %.6f, std %.6f)\n", scaler, payload.ScalerMean, payload.ScalerStd)
+	}
+	fmt.Printf("Training MSE     : %.6f\n", payload.TrainingMSE)
+	fmt.Printf("Residual Std Dev : %.6f (was %.6f)\n", payload.ResidualStdDev, payload.PreviousResidualStdDev)
+	fmt.Printf("Model loaded     : %s\n", payload.ModelLoadedFrom)
+	fmt.Printf("Model saved      : %s\n", payload.ModelSavedTo)
+	return nil
+}
+
+// updateSavePath picks where the updated model goes. It never overwrites
+// the loaded model: without -save-model, a numbered registry version such
+// as models/sales/3.json is followed by the next free number.
+func updateSavePath(loadPath, savePath string) (string, error) {
+	if savePath != "" {
+		if filepath.Clean(savePath) == filepath.Clean(loadPath) {
+			return "", fmt.Errorf("-save-model must differ from -load-model: the update is saved as a new version")
+		}
+		return savePath, nil
+	}
+	version := strings.TrimSuffix(filepath.Base(loadPath), filepath.Ext(loadPath))
+	if _, err := strconv.Atoi(version); err != nil {
+		return "", fmt.Errorf("update requires -save-model unless -load-model is a numbered version such as models/sales/3.json")
+	}
+	path, err := oracle.NextVersionPath(filepath.Dir(loadPath))
+	if err != nil {
+		return "", fmt.Errorf("finding the next model version: %w", err)
+	}
+	return path, nil
+}
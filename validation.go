@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// validationMetric is one selectable column of the validation output. The
+// zero-actual details are printed without the "Validation" prefix and left
+// out of the text output when no actual was zero.
+type validationMetric struct {
+	name     string
+	label    string
+	digits   int
+	percent  bool
+	zeroOnly bool
+	value    func(v ValidationPayload) float64
+}
+
+var validationMetrics = []validationMetric{
+	{name: "mae", label: "MAE", digits: 6, value: func(v ValidationPayload) float64 { return v.MAE }},
+	{name: "rmse", label: "RMSE", digits: 6, value: func(v ValidationPayload) float64 { return v.RMSE }},
+	{name: "mape", label: "MAPE", digits: 4, percent: true, value: func(v ValidationPayload) float64 { return v.MAPE }},
+	{name: "mape_excluded", label: "MAPE excluded", zeroOnly: true, value: func(v ValidationPayload) float64 { return float64(v.MAPEExcluded) }},
+	{name: "smape", label: "sMAPE", digits: 4, percent: true, value: func(v ValidationPayload) float64 { return v.SMAPE }},
+	{name: "mase", label: "MASE", digits: 6, value: func(v ValidationPayload) float64 { return v.MASE }},
+	{name: "rmsse", label: "RMSSE", digits: 6, value: func(v ValidationPayload) float64 { return v.RMSSE }},
+	{name: "spl", label: "SPL", digits: 6, value: func(v ValidationPayload) float64 { return v.SPL }},
+	{name: "r2", label: "R2", digits: 6, value: func(v ValidationPayload) float64 { return v.R2 }},
+	{name: "bias", label: "Bias", digits: 6, value: func(v ValidationPayload) float64 { return v.Bias }},
+	{name: "zero_count", label: "Zero actuals", zeroOnly: true, value: func(v ValidationPayload) float64 { return float64(v.ZeroCount) }},
+	{name: "zero_mae", label: "Zero MAE", digits: 6, zeroOnly: true, value: func(v ValidationPayload) float64 { return v.ZeroMAE }},
+	{name: "nonzero_mae", label: "Non-zero MAE", digits: 6, zeroOnly: true, value: func(v ValidationPayload) float64 { return v.NonzeroMAE }},
+}
+
+func validationMetricNames() []string {
+	names := make([]string, len(validationMetrics))
+	for i, m := range validationMetrics {
+		names[i] = m.name
+	}
+	return names
+}
+
+// parseMetrics reads a comma-separated list of metric names in the given
+// order; an empty list selects every metric.
+func parseMetrics(s string) ([]string, error) {
+	valid := validationMetricNames()
+	var names []string
+	for _, part := range strings.Split(s, ",") {
+		name := strings.ToLower(strings.TrimSpace(part))
+		if name == "" || containsString(names, name) {
+			continue
+		}
+		if !containsString(valid, name) {
+			return nil, fmt.Errorf("unknown metric %q (use %s)", strings.TrimSpace(part), strings.Join(valid, ", "))
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// selectMetrics returns the table entries for names, or all for none.
+func selectMetrics(names []string) []validationMetric {
+	if len(names) == 0 {
+		return validationMetrics
+	}
+	var selected []validationMetric
+	for _, name := range names {
+		for _, m := range validationMetrics {
+			if m.name == name {
+				selected = append(selected, m)
+			}
+		}
+	}
+	return selected
+}
+
+func (m validationMetric) format(v ValidationPayload) string {
+	return fmt.Sprintf("%.*f", m.digits, m.value(v))
+}
+
+func (v ValidationPayload) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `{"count":%d`, v.Count)
+	for _, m := range selectMetrics(v.metrics) {
+		value, err := json.Marshal(m.value(v))
+		if err != nil {
+			return nil, fmt.Errorf("metric %s: %w", m.name, err)
+		}
+		fmt.Fprintf(&b, `,%q:%s`, m.name, value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+func printValidationText(w io.Writer, v *ValidationPayload) {
+	if v == nil {
+		return
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Holdout points   : %d\n", v.Count)
+	printMetricsText(w, "Validation", *v)
+}
+
+// printMetricsText writes one aligned line per selected metric, labelled
+// with prefix.
+func printMetricsText(w io.Writer, prefix string, v ValidationPayload) {
+	for _, m := range selectMetrics(v.metrics) {
+		label := prefix + " " + m.label
+		if m.zeroOnly {
+			if v.ZeroCount == 0 {
+				continue
+			}
+			label = m.label
+		}
+		value := m.format(v)
+		if m.percent {
+			value += "%"
+		}
+		fmt.Fprintf(w, "%-17s: %s\n", label, value)
+	}
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestValidationPayloadSelection(t *testing.T) {
+	metrics, err := parseMetrics(" RMSE, mae,rmse,,zero_mae")
+	if err != nil {
+		t.Fatalf("parseMetrics failed: %v", err)
+	}
+	v := ValidationPayload{Count: 4, MAE: 1.5, RMSE: 2, MASE: 0.5, metrics: metrics}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if want := `{"count":4,"rmse":2,"mae":1.5,"zero_mae":0}`; string(data) != want {
+		t.Fatalf("JSON = %s, want %s", data, want)
+	}
+
+	var b strings.Builder
+	printMetricsText(&b, "Overall", v)
+	if want := "Overall RMSE     : 2.000000\nOverall MAE      : 1.500000\n"; b.String() != want {
+		t.Fatalf("text = %q, want %q", b.String(), want)
+	}
+
+	v.metrics = nil
+	data, _ = json.Marshal(v)
+	var all map[string]float64
+	if err := json.Unmarshal(data, &all); err != nil || len(all) != len(validationMetrics)+1 {
+		t.Fatalf("unselected payload = %s, want count and every metric", data)
+	}
+
+	if _, err := parseMetrics("mae,wape"); err == nil || !strings.Contains(err.Error(), `unknown metric "wape"`) {
+		t.Fatalf("parseMetrics error = %v", err)
+	}
+}